@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/thornzero/barkeep/internal/app"
+	"github.com/thornzero/barkeep/internal/config"
+	"github.com/thornzero/barkeep/internal/services"
+)
+
+// calibrateCommand measures the analog Up/Down buttons and saves their
+// pressed windows to the configuration
+func calibrateCommand(args []string) int {
+	fs, configPath := newFlagSet("calibrate")
+	simulate := fs.Bool("simulate-hardware", false, "calibrate against an in-memory simulator")
+	duration := fs.Duration("duration", 3*time.Second, "how long to sample each button state")
+	only := fs.String("button", "", "calibrate only this button (Up or Down)")
+	assumeYes := fs.Bool("yes", false, "save the thresholds without asking")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	buttons := services.AnalogButtons
+	if *only != "" {
+		button, err := services.ParseButton(*only)
+		if _, analog := services.AnalogButtonChannel(button); err != nil || !analog {
+			fmt.Fprintf(os.Stderr, "barkeep: %q is not an analog button; use Up or Down\n", *only)
+			return 2
+		}
+		buttons = []services.Button{button}
+	}
+
+	cfg, ok := loadConfig(*configPath)
+	if !ok {
+		return 1
+	}
+
+	// Open the hardware even if the configuration leaves it disabled
+	hardware := cfg.Hardware
+	if *simulate {
+		hardware.Simulate = true
+	} else {
+		hardware.Enabled = true
+	}
+
+	megaInd, stack, simulator := app.OpenMegaInd(hardware)
+	if megaInd == nil {
+		fmt.Fprintln(os.Stderr, "barkeep: no MegaInd card available to calibrate")
+		return 1
+	}
+	defer stack.Close()
+	defer megaInd.Dispose()
+
+	in := bufio.NewReader(os.Stdin)
+	thresholds := make(map[string]config.AnalogThresholdConfig)
+	for _, button := range buttons {
+		threshold, err := calibrateButton(megaInd, simulator, button, *duration, in)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "barkeep: failed to calibrate %s: %v\n", button, err)
+			return 1
+		}
+		thresholds[button.String()] = config.AnalogThresholdConfig(threshold)
+	}
+
+	fmt.Println()
+	for _, button := range buttons {
+		t := thresholds[button.String()]
+		fmt.Printf("%-5s pressed between %.2f and %.2f (hysteresis %.2f)\n", button, t.Lower, t.Upper, t.Hysteresis)
+	}
+
+	if !*assumeYes && !confirm(in, fmt.Sprintf("Save to %s?", *configPath)) {
+		fmt.Println("Not saved.")
+		return 0
+	}
+
+	if err := config.SaveAnalogThresholds(*configPath, thresholds); err != nil {
+		fmt.Fprintf(os.Stderr, "barkeep: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Saved to %s\n", *configPath)
+	return 0
+}
+
+// calibrateButton samples a button released and then held. With a
+// simulator, the button is pressed on the user's behalf.
+func calibrateButton(megaInd services.MegaInd, simulator *services.SimulatedMegaInd, button services.Button, duration time.Duration, in *bufio.Reader) (services.AnalogThreshold, error) {
+	channel, _ := services.AnalogButtonChannel(button)
+	fmt.Printf("\n%s button (analog input %d)\n", button, channel)
+
+	prompt(in, fmt.Sprintf("Leave %s released and press Enter", button))
+	idle, err := services.SampleAnalogInput(megaInd, channel, duration, liveReading)
+	if err != nil {
+		return services.AnalogThreshold{}, err
+	}
+	fmt.Printf("\r  idle:    %s\n", idle)
+
+	prompt(in, fmt.Sprintf("Hold %s down and press Enter", button))
+	if simulator != nil {
+		simulator.PressButton(button)
+		defer simulator.ReleaseButton(button)
+	}
+	pressed, err := services.SampleAnalogInput(megaInd, channel, duration, liveReading)
+	if err != nil {
+		return services.AnalogThreshold{}, err
+	}
+	fmt.Printf("\r  pressed: %s\n", pressed)
+
+	return services.CalibrateAnalogThreshold(idle, pressed)
+}
+
+// liveReading overwrites the current line with the latest reading
+func liveReading(value uint16) {
+	fmt.Printf("\r  reading: %-6d", value)
+}
+
+// prompt prints a message and waits for Enter
+func prompt(in *bufio.Reader, message string) {
+	fmt.Printf("%s... ", message)
+	in.ReadString('\n')
+}
+
+// confirm asks a yes/no question, defaulting to yes
+func confirm(in *bufio.Reader, question string) bool {
+	fmt.Printf("%s [Y/n] ", question)
+	answer, _ := in.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "" || answer == "y" || answer == "yes"
+}
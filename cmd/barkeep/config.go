@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// configCommand prints the effective configuration as JSON
+func configCommand(args []string) int {
+	fs, configPath := newFlagSet("config")
+	showPath := fs.Bool("path", false, "print the configuration file path instead")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *showPath {
+		fmt.Println(*configPath)
+		return 0
+	}
+
+	cfg, ok := loadConfig(*configPath)
+	if !ok {
+		return 1
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "barkeep: %v\n", err)
+		return 1
+	}
+
+	fmt.Println(string(data))
+	return 0
+}
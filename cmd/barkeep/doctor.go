@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/thornzero/barkeep/internal/config"
+	"github.com/thornzero/barkeep/internal/services"
+)
+
+// checkResult is the outcome of a single doctor check
+type checkResult int
+
+const (
+	checkOK checkResult = iota
+	checkSkipped
+	checkFailed
+)
+
+func (r checkResult) String() string {
+	switch r {
+	case checkOK:
+		return "[ OK ]"
+	case checkSkipped:
+		return "[SKIP]"
+	default:
+		return "[FAIL]"
+	}
+}
+
+// doctorCommand checks that the environment can run Barkeep
+func doctorCommand(args []string) int {
+	fs, configPath := newFlagSet("doctor")
+	probeHardware := fs.Bool("hardware", false, "probe the MegaInd cards even if disabled in the configuration")
+	simulate := fs.Bool("simulate-hardware", false, "skip probing the MegaInd cards because the hardware is simulated")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	failed := false
+	report := func(result checkResult, name, detail string) {
+		if result == checkFailed {
+			failed = true
+		}
+		fmt.Printf("%s %-16s %s\n", result, name, detail)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		report(checkFailed, "Config", err.Error())
+		return 1
+	}
+	if _, err := os.Stat(*configPath); err != nil {
+		report(checkOK, "Config", "using defaults ("+*configPath+" not found)")
+	} else {
+		report(checkOK, "Config", *configPath)
+	}
+
+	// Asset paths
+	paths := []struct {
+		name string
+		path string
+	}{
+		{"Assets", cfg.AssetsDirectory},
+		{"Sound effects", cfg.Audio.SFXDirectory},
+		{"Music library", cfg.Audio.MusicDirectory},
+	}
+	for _, p := range paths {
+		path := config.ExpandPath(p.path)
+		if info, err := os.Stat(path); err != nil {
+			report(checkFailed, p.name, err.Error())
+		} else if !info.IsDir() {
+			report(checkFailed, p.name, path+" is not a directory")
+		} else {
+			report(checkOK, p.name, path)
+		}
+	}
+
+	// Audio output
+	if err := services.ProbeAudio(); err != nil {
+		report(checkFailed, "Audio output", err.Error())
+	} else {
+		report(checkOK, "Audio output", "speaker initialized at 44.1kHz")
+	}
+
+	// MegaInd hardware
+	switch {
+	case *simulate || cfg.Hardware.Simulate:
+		report(checkSkipped, "MegaInd card", "hardware is simulated")
+	case !cfg.Hardware.Enabled && !*probeHardware:
+		report(checkSkipped, "MegaInd card", "hardware disabled in configuration")
+	default:
+		probeCards(cfg.Hardware, report)
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// probeCards reports the configured MegaInd cards, or the cards found by
+// probing every stack address when none are configured
+func probeCards(cfg config.HardwareConfig, report func(checkResult, string, string)) {
+	buses := cfg.BusNames()
+
+	if len(cfg.Cards) > 0 {
+		for _, card := range cfg.Cards {
+			bus := card.Bus
+			if bus == "" {
+				bus = buses[0]
+			}
+			name := "Card " + card.Name
+			if err := services.ProbeMegaInd(bus, card.Stack); err != nil {
+				report(checkFailed, name, err.Error())
+			} else {
+				report(checkOK, name, fmt.Sprintf("stack %d (0x%02X) on I2C bus %q", card.Stack, services.StackAddress(card.Stack), bus))
+			}
+		}
+		return
+	}
+
+	cards, err := services.DiscoverMegaIndCards(buses)
+	if err != nil {
+		report(checkFailed, "MegaInd card", err.Error())
+		return
+	}
+	if len(cards) == 0 {
+		report(checkFailed, "MegaInd card", fmt.Sprintf("no cards found on I2C buses %q", buses))
+		return
+	}
+	for _, card := range cards {
+		report(checkOK, "MegaInd card", fmt.Sprintf("stack %d (0x%02X) on I2C bus %q", card.Stack(), services.StackAddress(card.Stack()), card.Bus()))
+		card.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thornzero/barkeep/internal/config"
+)
+
+// version is overridden at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// command describes a barkeep subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+func commands() []command {
+	return []command{
+		{name: "run", summary: "Start the Barkeep TUI (default)", run: runCommand},
+		{name: "doctor", summary: "Check hardware, audio and asset paths", run: doctorCommand},
+		{name: "calibrate", summary: "Calibrate the analog Up/Down buttons", run: calibrateCommand},
+		{name: "config", summary: "Print the effective configuration", run: configCommand},
+		{name: "version", summary: "Print version information", run: versionCommand},
+	}
+}
+
+func main() {
+	os.Exit(execute(os.Args[1:]))
+}
+
+// execute dispatches to the requested subcommand and returns the exit code
+func execute(args []string) int {
+	if len(args) == 0 {
+		return runCommand(nil)
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return 0
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "barkeep: unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	return 2
+}
+
+// usage prints the list of subcommands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: barkeep <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'barkeep <command> -h' for command flags.")
+}
+
+// newFlagSet creates a flag set with the flags shared by all subcommands
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("barkeep "+name, flag.ContinueOnError)
+	configPath := fs.String("config", config.DefaultPath(), "path to the configuration file")
+	return fs, configPath
+}
+
+// loadConfig loads the configuration and reports errors to stderr
+func loadConfig(path string) (*config.Config, bool) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "barkeep: %v\n", err)
+		return nil, false
+	}
+	return cfg, true
+}
+
+// versionCommand prints the build version
+func versionCommand(args []string) int {
+	fs := flag.NewFlagSet("barkeep version", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	fmt.Printf("barkeep %s\n", version)
+	return 0
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/thornzero/barkeep/internal/app"
+	"github.com/thornzero/barkeep/internal/config"
+)
+
+// runCommand starts the TUI
+func runCommand(args []string) int {
+	fs, configPath := newFlagSet("run")
+	simulate := fs.Bool("simulate-hardware", false, "run the MegaInd controller against an in-memory simulator")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, ok := loadConfig(*configPath)
+	if !ok {
+		return 1
+	}
+	if *simulate {
+		cfg.Hardware.Simulate = true
+	}
+
+	// The TUI owns the terminal, so log output goes to a file
+	if cfg.LogFile != "" {
+		logFile, err := tea.LogToFile(config.ExpandPath(cfg.LogFile), "barkeep")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "barkeep: %v\n", err)
+			return 1
+		}
+		defer logFile.Close()
+	}
+
+	zone.NewGlobal()
+	defer zone.Close()
+
+	model, err := app.NewModel(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "barkeep: failed to start: %v\n", err)
+		return 1
+	}
+	defer func() {
+		if err := model.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "barkeep: cleanup failed: %v\n", err)
+		}
+	}()
+
+	program := tea.NewProgram(
+		model,
+		tea.WithAltScreen(),
+		tea.WithMouseCellMotion(),
+	)
+
+	if _, err := program.Run(); err != nil {
+		if errors.Is(err, tea.ErrInterrupted) {
+			return 130
+		}
+		fmt.Fprintf(os.Stderr, "barkeep: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
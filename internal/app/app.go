@@ -1,17 +1,24 @@
 package app
 
 import (
+	"log"
+	"time"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	zone "github.com/lrstanley/bubblezone"
 	"github.com/thornzero/barkeep/internal/components/header"
 	"github.com/thornzero/barkeep/internal/components/navigation"
 	"github.com/thornzero/barkeep/internal/components/statusbar"
+	"github.com/thornzero/barkeep/internal/config"
+	"github.com/thornzero/barkeep/internal/input"
 	"github.com/thornzero/barkeep/internal/screens/atmosphere"
 	"github.com/thornzero/barkeep/internal/screens/entertainment"
 	"github.com/thornzero/barkeep/internal/screens/food"
 	"github.com/thornzero/barkeep/internal/screens/home"
 	"github.com/thornzero/barkeep/internal/screens/settings"
+	"github.com/thornzero/barkeep/internal/services"
+	"github.com/thornzero/barkeep/internal/sound"
 )
 
 // Model represents the main application state
@@ -37,14 +44,17 @@ type Model struct {
 	atmosphereScreen    *atmosphere.Model
 	settingsScreen      *settings.Model
 
+	// Physical button bridge, nil without MegaInd hardware
+	buttons *input.Bridge
+
 	// User state
 	currentUser string
 }
 
 // NewModel creates a new application model with dependency injection
-func NewModel() (*Model, error) {
+func NewModel(cfg *config.Config) (*Model, error) {
 	// Initialize dependencies
-	deps, err := NewDependencies()
+	deps, err := NewDependencies(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -68,15 +78,42 @@ func NewModel() (*Model, error) {
 
 	entertainmentScreen := entertainment.NewModel(deps.AudioManager, deps.ThemeProvider)
 	entertainmentScreen.SetSize(initialWidth-22-6, initialHeight-6) // Account for nav and borders
+	entertainmentScreen.SetMusicDirectory(config.ExpandPath(deps.Config.Audio.MusicDirectory))
+	if deps.Library != nil {
+		entertainmentScreen.SetLibrary(deps.Library)
+	}
+	if deps.Playlists != nil {
+		entertainmentScreen.SetPlaylistStore(deps.Playlists)
+	}
 
 	foodScreen := food.NewModel(deps.ThemeProvider)
 	foodScreen.SetSize(initialWidth-22-6, initialHeight-6) // Account for nav and borders
 
 	atmosphereScreen := atmosphere.NewModel(deps.ThemeProvider)
 	atmosphereScreen.SetSize(initialWidth-22-6, initialHeight-6) // Account for nav and borders
+	atmosphereScreen.SetThermal(deps.Thermal)
+
+	// Bridge physical buttons into the UI
+	var buttons *input.Bridge
+	// suspender stays a nil interface, not a nil *Bridge, without hardware
+	var suspender settings.ButtonSuspender
+	if deps.MegaInd != nil {
+		bindings, err := input.ParseBindings(deps.Config.Hardware.ButtonActions)
+		if err != nil {
+			log.Printf("Invalid button mapping, using defaults: %v", err)
+			bindings, _ = input.ParseBindings(config.Default().Hardware.ButtonActions)
+		}
+		buttons = input.NewBridge(deps.MegaInd, bindings)
+		suspender = buttons
+		statusBarComp.SetPhysicalButtons(buttonHints(buttons))
+		statusBarComp.SetHardwareHealth(deps.MegaInd.Health)
+	}
 
 	settingsScreen := settings.NewModel(deps.ThemeProvider)
 	settingsScreen.SetSize(initialWidth-22-6, initialHeight-6) // Account for nav and borders
+	settingsScreen.AddPanel(settings.NewMixerPanel(deps.AudioManager, deps.ThemeProvider))
+	settingsScreen.AddPanel(settings.NewEqualizerPanel(deps.AudioManager, deps.ThemeProvider))
+	settingsScreen.AddPanel(settings.NewCalibrationPanel(deps.MegaInd, deps.HardwareSimulator, suspender, deps.Config, deps.ThemeProvider))
 
 	return &Model{
 		deps:          deps,
@@ -93,12 +130,35 @@ func NewModel() (*Model, error) {
 		foodScreen:          foodScreen,
 		atmosphereScreen:    atmosphereScreen,
 		settingsScreen:      settingsScreen,
+		buttons:             buttons,
 	}, nil
 }
 
+// buttonHints converts the live button mapping into status bar hints
+func buttonHints(bridge *input.Bridge) []statusbar.PhysicalButton {
+	var hints []statusbar.PhysicalButton
+	for _, hint := range bridge.Hints() {
+		hints = append(hints, statusbar.PhysicalButton{
+			Label:       hint.Label,
+			Description: hint.Action.Label(),
+			Available:   true,
+			Media:       hint.Action.Kind() == input.TransportKind,
+		})
+	}
+	return hints
+}
+
 // Init initializes the application model
 func (m *Model) Init() tea.Cmd {
-	return nil
+	cmds := []tea.Cmd{
+		m.header.Init(),
+		m.statusBar.Init(),
+		m.entertainmentScreen.Init(),
+	}
+	if m.buttons != nil {
+		cmds = append(cmds, m.buttons.Listen())
+	}
+	return tea.Batch(cmds...)
 }
 
 // Update handles messages and updates the application state
@@ -123,6 +183,13 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.settingsScreen.SetSize(contentWidth, contentHeight)
 
 	case tea.KeyMsg:
+		// Text entry on the entertainment screen takes every key
+		if m.currentScreen == navigation.EntertainmentScreen && m.entertainmentScreen.Capturing() {
+			var cmd tea.Cmd
+			m.entertainmentScreen, cmd = m.entertainmentScreen.Update(msg)
+			return m, cmd
+		}
+
 		// Handle global keys first
 		cmd := m.handleGlobalKeys(msg)
 		if cmd != nil {
@@ -145,15 +212,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Check for screen changes and update header/status bar
 		selectedScreen := m.navigation.GetSelectedScreen()
 		if selectedScreen != m.currentScreen {
-			m.currentScreen = selectedScreen
-
-			// Update header with new screen title
-			if screenInfo, exists := m.navigation.GetScreenInfo(selectedScreen); exists {
-				m.header.SetScreenTitle(screenInfo.Title)
-			}
-
-			// Update status bar with new screen context
-			m.statusBar.SetCurrentScreen(selectedScreen)
+			m.setCurrentScreen(selectedScreen)
 		}
 
 		// Update header and status bar components
@@ -201,6 +260,28 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				cmds = append(cmds, settingsCmd)
 			}
 		}
+
+	case input.ButtonMsg:
+		if cmd := m.handleButton(msg); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+		cmds = append(cmds, m.buttons.Listen())
+
+	case sound.Msg:
+		m.playUISound(msg.Event)
+
+	default:
+		// Forward component messages such as timer ticks
+		var headerCmd, statusCmd, entertainmentCmd, settingsCmd tea.Cmd
+		m.header, headerCmd = m.header.Update(msg)
+		m.statusBar, statusCmd = m.statusBar.Update(msg)
+		m.entertainmentScreen, entertainmentCmd = m.entertainmentScreen.Update(msg)
+		m.settingsScreen, settingsCmd = m.settingsScreen.Update(msg)
+		for _, cmd := range []tea.Cmd{headerCmd, statusCmd, entertainmentCmd, settingsCmd} {
+			if cmd != nil {
+				cmds = append(cmds, cmd)
+			}
+		}
 	}
 
 	if len(cmds) > 0 {
@@ -209,6 +290,99 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// setCurrentScreen switches screens and updates the header and status bar
+func (m *Model) setCurrentScreen(screen navigation.Screen) {
+	if screen != m.currentScreen {
+		m.playUISound(services.UINavigate)
+	}
+	m.currentScreen = screen
+
+	// Update header with new screen title
+	if screenInfo, exists := m.navigation.GetScreenInfo(screen); exists {
+		m.header.SetScreenTitle(screenInfo.Title)
+	}
+
+	// Update status bar with new screen context
+	m.statusBar.SetCurrentScreen(screen)
+}
+
+// playUISound plays the sound theme's sound for a UI event
+func (m *Model) playUISound(event services.UISound) {
+	if m.deps.AudioManager == nil {
+		return
+	}
+	if err := m.deps.AudioManager.PlayUISound(event); err != nil {
+		log.Printf("Failed to play %s sound: %v", event, err)
+	}
+}
+
+// handleButton performs the action mapped to a physical button event
+func (m *Model) handleButton(msg input.ButtonMsg) tea.Cmd {
+	switch msg.Action.Kind() {
+	case input.NavigationKind:
+		// Navigation actions behave exactly like their keyboard equivalents
+		if key, ok := msg.Action.KeyMsg(); ok {
+			_, cmd := m.Update(key)
+			return cmd
+		}
+
+	case input.ScreenKind:
+		screens := map[input.Action]navigation.Screen{
+			input.ActionScreenHome:          navigation.HomeScreen,
+			input.ActionScreenFood:          navigation.FoodAndDrinkScreen,
+			input.ActionScreenAtmosphere:    navigation.AtmosphereScreen,
+			input.ActionScreenEntertainment: navigation.EntertainmentScreen,
+			input.ActionScreenSettings:      navigation.SettingsScreen,
+		}
+		if screen, ok := screens[msg.Action]; ok {
+			// Navigation indices follow the Screen order
+			m.navigation.NavigateToScreen(int(screen))
+			m.setCurrentScreen(screen)
+		}
+
+	case input.TransportKind:
+		if err := m.handleTransport(msg.Action); err != nil {
+			m.SetStatusMessage(err.Error())
+			m.playUISound(services.UIError)
+		}
+	}
+
+	return nil
+}
+
+// seekStep is how far the seek actions move playback
+const seekStep = 10 * time.Second
+
+// handleTransport applies a playback control action
+func (m *Model) handleTransport(action input.Action) error {
+	audio := m.deps.AudioManager
+	if audio == nil {
+		return nil
+	}
+
+	switch action {
+	case input.ActionPlayPause:
+		if audio.GetStatus().IsPlaying {
+			return audio.Pause()
+		}
+		return audio.Play()
+	case input.ActionNext:
+		return audio.Next()
+	case input.ActionPrevious:
+		return audio.Previous()
+	case input.ActionVolumeUp:
+		audio.SetVolume(audio.GetStatus().Volume + services.FaderStep)
+	case input.ActionVolumeDown:
+		audio.SetVolume(audio.GetStatus().Volume - services.FaderStep)
+	case input.ActionSeekForward:
+		return audio.SeekRelative(seekStep)
+	case input.ActionSeekBack:
+		return audio.SeekRelative(-seekStep)
+	}
+
+	return nil
+}
+
 // handleGlobalKeys processes global application keys
 func (m *Model) handleGlobalKeys(msg tea.KeyMsg) tea.Cmd {
 	// Handle exit confirmation dialog
@@ -220,6 +394,7 @@ func (m *Model) handleGlobalKeys(msg tea.KeyMsg) tea.Cmd {
 		case "n", "N", "esc":
 			m.showExitConfirm = false
 			m.SetStatusMessage("Cancelled exit")
+			m.playUISound(services.UIBack)
 		}
 		return nil
 	}
@@ -231,11 +406,17 @@ func (m *Model) handleGlobalKeys(msg tea.KeyMsg) tea.Cmd {
 	case "q":
 		m.showExitConfirm = true
 		m.SetStatusMessage("Really quit? (y/n)")
+		m.playUISound(services.UISelect)
 
 	case "esc":
+		// An open settings panel uses esc to close itself
+		if m.currentScreen == navigation.SettingsScreen && m.settingsScreen.Capturing() {
+			return nil
+		}
 		if m.currentScreen != navigation.HomeScreen {
 			m.currentScreen = navigation.HomeScreen
 			m.navigation.NavigateToScreen(0)
+			m.playUISound(services.UIBack)
 		}
 	}
 
@@ -339,6 +520,9 @@ func (m *Model) ClearStatusMessage() {
 
 // Close cleans up the application
 func (m *Model) Close() error {
+	if m.buttons != nil {
+		m.buttons.Close()
+	}
 	if m.deps != nil {
 		return m.deps.Close()
 	}
@@ -1,35 +1,411 @@
 package app
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/thornzero/barkeep/internal/config"
 	"github.com/thornzero/barkeep/internal/services"
 	"github.com/thornzero/barkeep/internal/theme"
 )
 
 // Dependencies contains all the services and dependencies for the application
 type Dependencies struct {
+	Config        *config.Config
 	AudioManager  services.AudioServiceInterface
+	Library       services.LibraryServiceInterface
+	Playlists     *services.PlaylistStore
 	ThemeProvider theme.Provider
+
+	// MegaInd is nil when hardware is disabled or unavailable
+	MegaInd services.MegaInd
+	// MegaIndStack holds every card and the named I/O, and closes them,
+	// including the card MegaInd runs on; nil like MegaInd
+	MegaIndStack *services.MegaIndStack
+	// HardwareSimulator simulates the button card when running with
+	// simulated hardware
+	HardwareSimulator *services.SimulatedMegaInd
+
+	// Thermal drives the fan; nil when thermal control is disabled
+	Thermal *services.ThermalController
+	// ThermalSimulator is set when the thermal source is simulated
+	ThermalSimulator *services.SimulatedTemperatureSource
 }
 
 // NewDependencies creates a new dependency container
-func NewDependencies() (*Dependencies, error) {
+func NewDependencies(cfg *config.Config) (*Dependencies, error) {
+	if cfg == nil {
+		cfg = config.Default()
+	}
+
 	// Initialize audio service
 	audioManager := services.NewAudioManager()
+	audioManager.SetMusicDirectory(config.ExpandPath(cfg.Audio.MusicDirectory))
+	audioManager.SetSFXDirectory(config.ExpandPath(cfg.Audio.SFXDirectory))
+	mixer, err := services.LoadMixerLevels(cfg.MixerPath())
+	if err != nil {
+		log.Printf("Some mixer levels were reset: %v", err)
+	}
+	if err := audioManager.SetMixer(mixer); err != nil {
+		log.Printf("Ignoring mixer levels: %v", err)
+	}
+	audioManager.SetMixerFile(cfg.MixerPath())
+	transition := services.Transition{
+		Mode:     services.TransitionMode(cfg.Audio.Transition),
+		Duration: time.Duration(cfg.Audio.CrossfadeMS) * time.Millisecond,
+		Curve:    services.FadeCurve(cfg.Audio.CrossfadeCurve),
+	}
+	if err := audioManager.SetTransition(transition); err != nil {
+		log.Printf("Ignoring audio transition: %v", err)
+	}
+	ducking := services.Ducking{
+		Depth:   cfg.Audio.DuckDB,
+		Attack:  time.Duration(cfg.Audio.DuckAttackMS) * time.Millisecond,
+		Release: time.Duration(cfg.Audio.DuckReleaseMS) * time.Millisecond,
+	}
+	if err := audioManager.SetDucking(ducking); err != nil {
+		log.Printf("Ignoring announcement ducking: %v", err)
+	}
+	if cfg.Audio.SoundTheme != "" {
+		if soundTheme, err := services.LoadSoundTheme(config.ExpandPath(cfg.Audio.SoundTheme)); err != nil {
+			log.Printf("UI sounds disabled: %v", err)
+		} else if err := audioManager.SetSoundTheme(soundTheme); err != nil {
+			log.Printf("Some UI sounds are unavailable: %v", err)
+		}
+	}
+	if normalization, err := services.ParseNormalizationMode(cfg.Audio.Normalization); err != nil {
+		log.Printf("Ignoring audio normalization: %v", err)
+	} else if err := audioManager.SetNormalization(normalization, cfg.Audio.PreampDB); err != nil {
+		log.Printf("Ignoring audio normalization: %v", err)
+	}
+	if err := audioManager.SetEffects(loadEffects(cfg)); err != nil {
+		log.Printf("Ignoring audio effects: %v", err)
+	}
+	audioManager.SetEffectsFile(cfg.EffectsPath())
+	if history, err := services.LoadPlayHistory(cfg.HistoryPath()); err != nil {
+		log.Printf("Starting with an empty play history: %v", err)
+		audioManager.SetPlayHistory(services.NewPlayHistory())
+	} else {
+		audioManager.SetPlayHistory(history)
+	}
+	audioManager.SetSmartShuffleWindow(time.Duration(cfg.Audio.SmartShuffleHours) * time.Hour)
+	if shuffle, err := services.ParseShuffleMode(cfg.Audio.Shuffle); err != nil {
+		log.Printf("Ignoring audio shuffle: %v", err)
+	} else {
+		audioManager.SetShuffleMode(shuffle)
+	}
+
+	// Pick up the queue where the last run left off
+	audioManager.SetQueueFile(cfg.QueuePath())
+	if cfg.Audio.RestoreQueue {
+		if err := audioManager.RestoreQueue(); err != nil {
+			log.Printf("Queue not fully restored: %v", err)
+		}
+	}
+
+	// Open the music library from its catalog and bring it up to date in
+	// the background, watching first so no change is missed
+	library := services.NewLibrary(cfg.LibraryPaths(), cfg.LibraryPath())
+	if err := library.Load(); err != nil {
+		log.Printf("Rebuilding music library: %v", err)
+	}
+	audioManager.SetTrackGrouping(library.Grouping)
+	audioManager.SetGainLookup(library.Gains)
+	go func() {
+		if cfg.Audio.WatchLibrary {
+			if err := library.Watch(); err != nil {
+				log.Printf("Music library will not update live: %v", err)
+			}
+		}
+		result, err := library.Scan()
+		if errors.Is(err, services.ErrLibraryClosed) {
+			log.Printf("Music library scan stopped: %s", result)
+			return
+		}
+		if err != nil {
+			log.Printf("Music library scan failed: %v", err)
+			return
+		}
+		log.Printf("Music library scanned: %s", result)
+	}()
 
 	// Initialize theme provider
 	themeProvider := theme.NewProvider()
-	themeProvider.SetTheme("InkCrimsonDark")
+	themeProvider.SetTheme(theme.ThemeName(cfg.Theme))
+
+	// Initialize hardware
+	megaInd, stack, simulator := OpenMegaInd(cfg.Hardware)
+	thermal, thermalSimulator := newThermalController(cfg.Thermal, megaInd, stack)
+	if thermal != nil {
+		thermal.Start()
+	}
 
 	return &Dependencies{
-		AudioManager:  audioManager,
-		ThemeProvider: themeProvider,
+		Config:            cfg,
+		AudioManager:      audioManager,
+		Library:           library,
+		Playlists:         services.NewPlaylistStore(cfg.PlaylistsPath()),
+		ThemeProvider:     themeProvider,
+		MegaInd:           megaInd,
+		MegaIndStack:      stack,
+		HardwareSimulator: simulator,
+		Thermal:           thermal,
+		ThermalSimulator:  thermalSimulator,
 	}, nil
 }
 
+// OpenMegaInd opens the MegaInd card stack selected by the hardware
+// configuration and starts the controller on the button card. Hardware
+// failures are logged rather than returned so the UI still starts without
+// the cards.
+func OpenMegaInd(cfg config.HardwareConfig) (services.MegaInd, *services.MegaIndStack, *services.SimulatedMegaInd) {
+	if !cfg.Simulate && !cfg.Enabled {
+		return nil, nil, nil
+	}
+
+	stack, simulators := openMegaIndStack(cfg)
+	cards := stack.Cards()
+	if len(cards) == 0 {
+		log.Printf("MegaInd hardware unavailable: no cards found on I2C buses %v", cfg.BusNames())
+		stack.Close()
+		return nil, nil, nil
+	}
+
+	for _, io := range cfg.IO {
+		point := services.IOPoint{Name: io.Name, Card: io.Card, Kind: services.IOKind(io.Kind), Channel: io.Channel}
+		if err := stack.Define(point); err != nil {
+			log.Printf("Ignoring I/O configuration: %v", err)
+		}
+	}
+
+	buttonCard := cfg.ButtonCard
+	if buttonCard == "" {
+		buttonCard = cards[0]
+	}
+	card, ok := stack.Card(buttonCard)
+	if !ok {
+		log.Printf("MegaInd button card %q not found", buttonCard)
+		stack.Close()
+		return nil, nil, nil
+	}
+
+	controller := services.NewMegaIndController()
+	configureButtons(controller, cfg)
+	if err := controller.AttachCard(card); err != nil {
+		log.Printf("Failed to start MegaInd controller: %v", err)
+		stack.Close()
+		return nil, nil, nil
+	}
+
+	log.Printf("MegaInd controller running on card %q (stack %d, bus %q)", buttonCard, card.Stack(), card.Bus())
+	return controller, stack, simulators[buttonCard]
+}
+
+// openMegaIndStack opens the configured cards, or discovers them when none
+// are configured. With simulated hardware every card is backed by its own
+// simulator, returned by card name.
+func openMegaIndStack(cfg config.HardwareConfig) (*services.MegaIndStack, map[string]*services.SimulatedMegaInd) {
+	stack := services.NewMegaIndStack()
+	simulators := make(map[string]*services.SimulatedMegaInd)
+	buses := cfg.BusNames()
+
+	addCard := func(name string, card *services.MegaIndCard) {
+		if err := stack.AddCard(name, card); err != nil {
+			log.Printf("Ignoring MegaInd card: %v", err)
+			card.Close()
+		}
+	}
+
+	switch {
+	case cfg.Simulate:
+		cards := cfg.Cards
+		if len(cards) == 0 {
+			cards = []config.CardConfig{{Name: discoveredCardName(buses[0], 0, false)}}
+		}
+		for _, cardCfg := range cards {
+			simulator := services.NewSimulatedMegaInd()
+			simulators[cardCfg.Name] = simulator
+			addCard(cardCfg.Name, services.NewMegaIndCard(simulator, "simulated", cardCfg.Stack))
+		}
+
+	case len(cfg.Cards) > 0:
+		for _, cardCfg := range cfg.Cards {
+			bus := cardCfg.Bus
+			if bus == "" {
+				bus = buses[0]
+			}
+			card, err := services.OpenMegaIndCard(bus, cardCfg.Stack)
+			if err != nil {
+				log.Printf("MegaInd card %q unavailable: %v", cardCfg.Name, err)
+				continue
+			}
+			addCard(cardCfg.Name, card)
+		}
+
+	default:
+		cards, err := services.DiscoverMegaIndCards(buses)
+		if err != nil {
+			log.Printf("Failed to search for MegaInd cards: %v", err)
+		}
+		for _, card := range cards {
+			addCard(discoveredCardName(card.Bus(), card.Stack(), len(buses) > 1), card)
+		}
+	}
+
+	return stack, simulators
+}
+
+// loadEffects reads the saved music effects. Until the effects file is
+// first saved, the obsolete audio.limiter key still switches the limiter.
+func loadEffects(cfg *config.Config) services.Effects {
+	effects, err := services.LoadEffects(cfg.EffectsPath())
+	if err != nil {
+		log.Printf("Using default audio effects: %v", err)
+	}
+
+	if cfg.Audio.Limiter != nil {
+		log.Printf("audio.limiter is obsolete; the limiter is now switched in Settings › Equalizer and saved in %s", cfg.EffectsPath())
+		if _, err := os.Stat(cfg.EffectsPath()); errors.Is(err, os.ErrNotExist) {
+			effects.Limiter = *cfg.Audio.Limiter
+		}
+	}
+	return effects
+}
+
+// discoveredCardName names a card found by probing
+func discoveredCardName(bus string, stack int, qualify bool) string {
+	if qualify {
+		return fmt.Sprintf("%s/stack%d", bus, stack)
+	}
+	return fmt.Sprintf("stack%d", stack)
+}
+
+// newThermalController creates the fan controller selected by the thermal
+// configuration. Problems are logged and leave thermal control disabled.
+func newThermalController(cfg config.ThermalConfig, megaInd services.MegaInd, stack *services.MegaIndStack) (*services.ThermalController, *services.SimulatedTemperatureSource) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if megaInd == nil {
+		log.Println("Thermal control disabled: the fan is driven by the MegaInd card, which is unavailable")
+		return nil, nil
+	}
+
+	var source services.TemperatureSource
+	var simulator *services.SimulatedTemperatureSource
+	switch cfg.Source {
+	case "megaind":
+		if cfg.Card == "" {
+			source = services.MegaIndTemperatureSource("MegaInd", megaInd)
+			break
+		}
+		card, ok := stack.Card(cfg.Card)
+		if !ok {
+			log.Printf("Thermal control disabled: unknown MegaInd card %q", cfg.Card)
+			return nil, nil
+		}
+		source = services.MegaIndTemperatureSource("MegaInd "+cfg.Card, card)
+	case "file":
+		source = &services.FileTemperatureSource{Path: cfg.Path}
+	case "simulated":
+		simulator = services.NewSimulatedTemperatureSource(40)
+		source = simulator
+	default:
+		log.Printf("Thermal control disabled: unknown source %q", cfg.Source)
+		return nil, nil
+	}
+
+	settings := services.ThermalSettings{
+		Mode:         services.ThermalMode(cfg.Mode),
+		Target:       cfg.TargetC,
+		Kp:           cfg.Kp,
+		Ki:           cfg.Ki,
+		Kd:           cfg.Kd,
+		MinSpeed:     cfg.MinSpeed,
+		KickSpeed:    cfg.KickSpeed,
+		KickDuration: time.Duration(cfg.KickMS) * time.Millisecond,
+		Hysteresis:   cfg.HysteresisC,
+		Interval:     time.Duration(cfg.IntervalMS) * time.Millisecond,
+	}
+	for _, point := range cfg.Curve {
+		settings.Curve = append(settings.Curve, services.FanCurvePoint{Temperature: point.TempC, Speed: point.Speed})
+	}
+
+	controller, err := services.NewThermalController(source, megaInd, settings)
+	if err != nil {
+		log.Printf("Thermal control disabled: %v", err)
+		return nil, nil
+	}
+	return controller, simulator
+}
+
+// configureButtons applies button timing and analog thresholds from the
+// configuration, logging and skipping invalid entries
+func configureButtons(controller *services.MegaIndController, cfg config.HardwareConfig) {
+	for name, timingCfg := range cfg.Buttons {
+		button, err := services.ParseButton(name)
+		if err != nil {
+			log.Printf("Ignoring button timing: %v", err)
+			continue
+		}
+
+		timing := services.DefaultButtonTiming()
+		if timingCfg.DebounceMS > 0 {
+			timing.Debounce = time.Duration(timingCfg.DebounceMS) * time.Millisecond
+		}
+		if timingCfg.LongPressMS > 0 {
+			timing.LongPress = time.Duration(timingCfg.LongPressMS) * time.Millisecond
+		}
+		if timingCfg.DoublePressMS > 0 {
+			timing.DoublePress = time.Duration(timingCfg.DoublePressMS) * time.Millisecond
+		}
+		if timingCfg.RepeatDelayMS > 0 {
+			timing.RepeatDelay = time.Duration(timingCfg.RepeatDelayMS) * time.Millisecond
+		}
+		if timingCfg.RepeatIntervalMS > 0 {
+			timing.RepeatInterval = time.Duration(timingCfg.RepeatIntervalMS) * time.Millisecond
+		}
+
+		if err := controller.SetButtonTiming(button, timing); err != nil {
+			log.Printf("Ignoring timing for button %s: %v", button, err)
+		}
+	}
+
+	for _, button := range services.AnalogButtons {
+		channel, _ := services.AnalogButtonChannel(button)
+		threshold := services.DefaultAnalogThreshold()
+		threshold.Hysteresis = cfg.AnalogHysteresis
+		if calibrated, ok := cfg.AnalogThresholds[button.String()]; ok {
+			threshold = services.AnalogThreshold(calibrated)
+		}
+		if err := controller.SetAnalogThreshold(channel, threshold); err != nil {
+			log.Printf("Ignoring analog threshold for button %s: %v", button, err)
+		}
+	}
+}
+
 // Close cleans up all dependencies
 func (d *Dependencies) Close() error {
+	var errs []error
+
+	if d.Thermal != nil {
+		d.Thermal.Stop()
+	}
+	if d.MegaInd != nil {
+		errs = append(errs, d.MegaInd.Dispose())
+	}
+	if d.MegaIndStack != nil {
+		errs = append(errs, d.MegaIndStack.Close())
+	}
+	if d.Library != nil {
+		errs = append(errs, d.Library.Close())
+	}
 	if d.AudioManager != nil {
-		return d.AudioManager.Close()
+		errs = append(errs, d.AudioManager.Close())
 	}
-	return nil
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thornzero/barkeep/internal/config"
+)
+
+func TestLoadEffectsHonoursObsoleteLimiterKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"audio": {"limiter": false}}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// Without an effects file the old key switches the limiter
+	if loadEffects(cfg).Limiter {
+		t.Error("limiter on, want audio.limiter to turn it off")
+	}
+
+	// Once the effects are saved they win
+	if err := os.WriteFile(cfg.EffectsPath(), []byte(`{"limiter": true}`), 0o644); err != nil {
+		t.Fatalf("write effects: %v", err)
+	}
+	if !loadEffects(cfg).Limiter {
+		t.Error("limiter off, want the saved effects to turn it on")
+	}
+}
@@ -87,6 +87,7 @@ func (m *Model) Update(msg tea.Msg) (*Model, tea.Cmd) {
 	case animationTickMsg:
 		if m.animating {
 			m.currentFrame = (m.currentFrame + 1) % len(m.logoFrames)
+			m.lastUpdate = time.Now()
 			return m, m.tick()
 		}
 	}
@@ -5,6 +5,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/thornzero/barkeep/internal/components/navigation"
+	"github.com/thornzero/barkeep/internal/services"
 	"github.com/thornzero/barkeep/internal/theme"
 )
 
@@ -13,6 +14,8 @@ type PhysicalButton struct {
 	Label       string
 	Description string
 	Available   bool
+	// Media marks playback controls, which are only relevant on media screens
+	Media bool
 }
 
 // Model represents the status bar component state
@@ -28,6 +31,9 @@ type Model struct {
 	showTime        bool
 	showButtons     bool
 
+	// Hardware health, polled on every render; nil hides the indicator
+	hardwareHealth func() services.MegaIndHealth
+
 	// Time tracking
 	currentTime time.Time
 
@@ -37,28 +43,13 @@ type Model struct {
 
 // NewModel creates a new status bar component
 func NewModel(themeProvider theme.Provider) *Model {
-	// Define physical buttons based on hardware setup
-	physicalButtons := []PhysicalButton{
-		{Label: "F1", Description: "Home", Available: true},
-		{Label: "F2", Description: "Menu", Available: true},
-		{Label: "F3", Description: "Back", Available: true},
-		{Label: "F4", Description: "Select", Available: true},
-		{Label: "PgUp", Description: "Up", Available: true},   // Added navigation keys
-		{Label: "PgDn", Description: "Down", Available: true}, // Added navigation keys
-		{Label: "⏯", Description: "Play/Pause", Available: true},
-		{Label: "⏭", Description: "Next", Available: true},
-		{Label: "⏮", Description: "Previous", Available: true},
-		{Label: "🔊", Description: "Volume", Available: true},
-	}
-
 	return &Model{
-		width:           80,
-		height:          1,
-		physicalButtons: physicalButtons,
-		showTime:        true,
-		showButtons:     true,
-		currentTime:     time.Now(),
-		themeProvider:   themeProvider,
+		width:         80,
+		height:        1,
+		showTime:      true,
+		showButtons:   true,
+		currentTime:   time.Now(),
+		themeProvider: themeProvider,
 	}
 }
 
@@ -94,29 +85,25 @@ func (m *Model) SetShowButtons(show bool) {
 	m.showButtons = show
 }
 
+// SetPhysicalButtons replaces the button hints, e.g. from the live button mapping
+func (m *Model) SetPhysicalButtons(buttons []PhysicalButton) {
+	m.physicalButtons = make([]PhysicalButton, len(buttons))
+	copy(m.physicalButtons, buttons)
+	m.updateButtonAvailability()
+}
+
+// SetHardwareHealth sets the source of the hardware health indicator
+func (m *Model) SetHardwareHealth(health func() services.MegaIndHealth) {
+	m.hardwareHealth = health
+}
+
 // updateButtonAvailability updates which buttons are available based on current screen
 func (m *Model) updateButtonAvailability() {
-	// Reset all to available first
-	for i := range m.physicalButtons {
-		m.physicalButtons[i].Available = true
-	}
+	// Media buttons are only relevant in entertainment
+	mediaAvailable := m.currentScreen == navigation.EntertainmentScreen
 
-	// Context-sensitive availability based on screen
-	switch m.currentScreen {
-	case navigation.EntertainmentScreen:
-		// All media buttons available in entertainment
-		for i, button := range m.physicalButtons {
-			if button.Label == "⏯" || button.Label == "⏭" || button.Label == "⏮" || button.Label == "🔊" {
-				m.physicalButtons[i].Available = true
-			}
-		}
-	case navigation.HomeScreen, navigation.FoodAndDrinkScreen, navigation.AtmosphereScreen, navigation.SettingsScreen:
-		// Media buttons not as relevant in these screens
-		for i, button := range m.physicalButtons {
-			if button.Label == "⏯" || button.Label == "⏭" || button.Label == "⏮" {
-				m.physicalButtons[i].Available = false
-			}
-		}
+	for i, button := range m.physicalButtons {
+		m.physicalButtons[i].Available = !button.Media || mediaAvailable
 	}
 }
 
@@ -35,7 +35,12 @@ func (m *Model) View() string {
 		}
 	}
 
-	// Priority 3: Time and date (if enabled)
+	// Priority 3: Hardware health (if hardware is present)
+	if m.hardwareHealth != nil {
+		sections = append(sections, m.renderHardwareHealth())
+	}
+
+	// Priority 4: Time and date (if enabled)
 	if m.showTime {
 		timeDisplay := m.renderTimeDisplay()
 		sections = append(sections, timeDisplay)
@@ -98,7 +103,7 @@ func (m *Model) renderButtonHelp() string {
 	}
 
 	var buttonTexts []string
-	maxButtons := 4 // Limit to prevent overcrowding
+	maxButtons := 6 // Limit to prevent overcrowding
 	buttonCount := 0
 
 	for _, button := range m.physicalButtons {
@@ -116,6 +121,27 @@ func (m *Model) renderButtonHelp() string {
 	return strings.Join(buttonTexts, " ")
 }
 
+// renderHardwareHealth creates the hardware health indicator
+func (m *Model) renderHardwareHealth() string {
+	theme := m.themeProvider.GetTheme()
+
+	health := m.hardwareHealth()
+	style := lipgloss.NewStyle()
+	icon := "●"
+	switch health {
+	case services.HealthOK:
+		style = style.Foreground(theme.Utility.SuccessTag)
+	case services.HealthDegraded:
+		style = style.Foreground(theme.Bases.Tertiary)
+		icon = "▲"
+	default:
+		style = style.Foreground(theme.Surfaces.Error).Bold(true)
+		icon = "✖"
+	}
+
+	return style.Render(icon + " HW " + health.String())
+}
+
 // renderTimeDisplay creates the time and date display
 func (m *Model) renderTimeDisplay() string {
 	if !m.showTime {
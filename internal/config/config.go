@@ -0,0 +1,457 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config holds the application configuration
+type Config struct {
+	Theme           string         `json:"theme"`
+	LogFile         string         `json:"log_file"`
+	AssetsDirectory string         `json:"assets_directory"`
+	Audio           AudioConfig    `json:"audio"`
+	Hardware        HardwareConfig `json:"hardware"`
+	Thermal         ThermalConfig  `json:"thermal"`
+
+	// path is the file the configuration was loaded from
+	path string
+}
+
+// AudioConfig holds audio playback settings
+type AudioConfig struct {
+	MusicDirectory string `json:"music_directory"`
+	SFXDirectory   string `json:"sfx_directory"`
+	// Transition joins queued tracks: "gapless" or "crossfade"
+	Transition string `json:"transition"`
+	// CrossfadeMS and CrossfadeCurve ("linear", "equal_power") shape crossfades
+	CrossfadeMS    int    `json:"crossfade_ms"`
+	CrossfadeCurve string `json:"crossfade_curve"`
+	// Shuffle is "off", "random" or "smart"
+	Shuffle string `json:"shuffle"`
+	// SmartShuffleHours is how long smart shuffle holds back a played track
+	SmartShuffleHours int `json:"smart_shuffle_hours"`
+	// HistoryFile records plays for smart shuffle; empty means history.json
+	// next to the configuration file
+	HistoryFile string `json:"history_file,omitempty"`
+	// LibraryRoots are scanned into the music library; empty means the
+	// music directory
+	LibraryRoots []string `json:"library_roots,omitempty"`
+	// LibraryFile holds the library catalog; empty means library.json next
+	// to the configuration file
+	LibraryFile string `json:"library_file,omitempty"`
+	// WatchLibrary picks up files added to or removed from the library
+	// roots while running
+	WatchLibrary bool `json:"watch_library"`
+	// PlaylistsDirectory holds saved playlists as M3U8 files; empty means
+	// a playlists directory next to the configuration file
+	PlaylistsDirectory string `json:"playlists_directory,omitempty"`
+	// QueueFile keeps the queue and position; empty means queue.json next
+	// to the configuration file
+	QueueFile string `json:"queue_file,omitempty"`
+	// RestoreQueue reloads the saved queue at startup
+	RestoreQueue bool `json:"restore_queue"`
+	// MixerFile keeps the bus levels, mutes and limits; empty means
+	// mixer.json next to the configuration file
+	MixerFile string `json:"mixer_file,omitempty"`
+	// DuckDB is how far music drops under announcements, reached over
+	// DuckAttackMS and recovered over DuckReleaseMS
+	DuckDB        float64 `json:"duck_db"`
+	DuckAttackMS  int     `json:"duck_attack_ms"`
+	DuckReleaseMS int     `json:"duck_release_ms"`
+	// SoundTheme maps UI events to sounds; empty silences the interface
+	SoundTheme string `json:"sound_theme"`
+	// Normalization applies ReplayGain: "track", "album" or "off". Tracks
+	// without tags are measured when the library is scanned.
+	Normalization string `json:"normalization"`
+	// PreampDB is added to every normalization gain
+	PreampDB float64 `json:"preamp_db,omitempty"`
+	// EffectsFile keeps the music EQ, filter and limiter settings; empty
+	// means effects.json next to the configuration file
+	EffectsFile string `json:"effects_file,omitempty"`
+	// Limiter is obsolete; the limiter is switched in the effects file. It
+	// is still honoured until that file is first saved.
+	Limiter *bool `json:"limiter,omitempty"`
+}
+
+// HardwareConfig holds settings for the MegaInd automation card
+type HardwareConfig struct {
+	Enabled  bool `json:"enabled"`
+	Simulate bool `json:"simulate"`
+	// I2CBus is the bus number searched when Buses is empty
+	I2CBus int `json:"i2c_bus"`
+	// Buses lists the I2C buses to search for cards, as bus numbers ("1")
+	// or device paths ("/dev/i2c-1")
+	Buses []string `json:"buses,omitempty"`
+
+	// Cards names the cards in the stack. When empty, every stack address
+	// on every bus is probed and the cards found are named "stack0" to
+	// "stack7", prefixed with "<bus>/" when several buses are searched.
+	Cards []CardConfig `json:"cards,omitempty"`
+	// ButtonCard names the card wired to the buttons, button LEDs and fan;
+	// empty means the first card
+	ButtonCard string `json:"button_card,omitempty"`
+	// IO gives logical names to card channels
+	IO []IOConfig `json:"io,omitempty"`
+
+	// Buttons overrides edge detection timing, keyed by button name
+	// (A, B, X, Y, Up, Down)
+	Buttons map[string]ButtonTimingConfig `json:"buttons,omitempty"`
+	// AnalogHysteresis widens the Up/Down pressed window once pressed
+	AnalogHysteresis float64 `json:"analog_hysteresis"`
+	// AnalogThresholds holds calibrated pressed windows keyed by button
+	// name (Up, Down); buttons without one use the built-in window
+	AnalogThresholds map[string]AnalogThresholdConfig `json:"analog_thresholds,omitempty"`
+	// ButtonActions maps physical button events to application actions
+	ButtonActions []ButtonActionConfig `json:"button_actions"`
+}
+
+// ThermalConfig holds closed-loop fan control settings
+type ThermalConfig struct {
+	Enabled bool `json:"enabled"`
+	// Source is megaind (the card's onboard sensor), file or simulated
+	Source string `json:"source"`
+	// Card names the MegaInd card to read; empty means the button card
+	Card string `json:"card,omitempty"`
+	// Path is the sysfs thermal zone or 1-Wire w1_slave file to read
+	Path string `json:"path,omitempty"`
+
+	// Mode is curve or pid
+	Mode  string           `json:"mode"`
+	Curve []FanCurveConfig `json:"curve"`
+
+	TargetC float64 `json:"target_c"`
+	Kp      float64 `json:"kp"`
+	Ki      float64 `json:"ki"`
+	Kd      float64 `json:"kd"`
+
+	MinSpeed    int     `json:"min_speed"`
+	KickSpeed   int     `json:"kick_speed"`
+	KickMS      int     `json:"kick_ms"`
+	HysteresisC float64 `json:"hysteresis_c"`
+	IntervalMS  int     `json:"interval_ms"`
+}
+
+// FanCurveConfig is a point on the fan curve
+type FanCurveConfig struct {
+	TempC float64 `json:"temp_c"`
+	Speed int     `json:"speed"`
+}
+
+// CardConfig identifies a MegaInd card by bus and stack level
+type CardConfig struct {
+	Name string `json:"name"`
+	// Bus defaults to the first configured bus
+	Bus string `json:"bus,omitempty"`
+	// Stack is the card's stack level (0-7), set by its address jumpers
+	Stack int `json:"stack"`
+}
+
+// IOConfig binds a logical name to a card channel
+type IOConfig struct {
+	Name string `json:"name"`
+	Card string `json:"card"`
+	// Kind is open_drain, analog_out, opto_in, opto_count or current_in
+	Kind    string `json:"kind"`
+	Channel int    `json:"channel"`
+}
+
+// BusNames returns the I2C buses to search for cards
+func (h HardwareConfig) BusNames() []string {
+	if len(h.Buses) > 0 {
+		return h.Buses
+	}
+	return []string{strconv.Itoa(h.I2CBus)}
+}
+
+// ButtonActionConfig maps a physical button event to an application action
+type ButtonActionConfig struct {
+	Button string `json:"button"`
+	// On is the triggering event: pressed (default), released, long_press,
+	// double_press or repeat
+	On     string `json:"on,omitempty"`
+	Action string `json:"action"`
+}
+
+// AnalogThresholdConfig is the raw AIN window, in millivolts, that counts
+// as a pressed analog button
+type AnalogThresholdConfig struct {
+	Lower      float64 `json:"lower"`
+	Upper      float64 `json:"upper"`
+	Hysteresis float64 `json:"hysteresis"`
+}
+
+// ButtonTimingConfig holds per-button timing in milliseconds. Zero fields
+// keep the built-in default.
+type ButtonTimingConfig struct {
+	DebounceMS       int `json:"debounce_ms,omitempty"`
+	LongPressMS      int `json:"long_press_ms,omitempty"`
+	DoublePressMS    int `json:"double_press_ms,omitempty"`
+	RepeatDelayMS    int `json:"repeat_delay_ms,omitempty"`
+	RepeatIntervalMS int `json:"repeat_interval_ms,omitempty"`
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		Theme:           "InkCrimsonDark",
+		LogFile:         filepath.Join(os.TempDir(), "barkeep.log"),
+		AssetsDirectory: "assets",
+		Audio: AudioConfig{
+			MusicDirectory:    "$HOME/Music",
+			SFXDirectory:      "assets/sounds",
+			Transition:        "gapless",
+			CrossfadeMS:       4000,
+			CrossfadeCurve:    "equal_power",
+			Shuffle:           "off",
+			SmartShuffleHours: 4,
+			WatchLibrary:      true,
+			RestoreQueue:      true,
+			DuckDB:            12,
+			DuckAttackMS:      300,
+			DuckReleaseMS:     800,
+			SoundTheme:        "assets/sound_themes/default.json",
+			Normalization:     "track",
+		},
+		Hardware: HardwareConfig{
+			Enabled:          false,
+			I2CBus:           1, // Typical for Raspberry Pi
+			AnalogHysteresis: 5,
+			ButtonActions: []ButtonActionConfig{
+				{Button: "A", Action: "select"},
+				{Button: "B", Action: "back"},
+				{Button: "B", On: "long_press", Action: "screen_home"},
+				{Button: "X", Action: "play_pause"},
+				{Button: "Y", Action: "next"},
+				{Button: "Y", On: "long_press", Action: "previous"},
+				{Button: "Up", Action: "up"},
+				{Button: "Up", On: "long_press", Action: "seek_forward"},
+				{Button: "Down", Action: "down"},
+				{Button: "Down", On: "long_press", Action: "seek_back"},
+			},
+		},
+		Thermal: ThermalConfig{
+			Enabled: false,
+			Source:  "megaind",
+			Path:    "/sys/class/thermal/thermal_zone0/temp",
+			Mode:    "curve",
+			Curve: []FanCurveConfig{
+				{TempC: 35, Speed: 0},
+				{TempC: 45, Speed: 40},
+				{TempC: 60, Speed: 100},
+			},
+			TargetC:     45,
+			Kp:          8,
+			Ki:          0.2,
+			MinSpeed:    25,
+			KickSpeed:   100,
+			KickMS:      1000,
+			HysteresisC: 2,
+			IntervalMS:  2000,
+		},
+	}
+}
+
+// DefaultPath returns the location of the user's configuration file
+func DefaultPath() string {
+	if path := os.Getenv("BARKEEP_CONFIG"); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "barkeep.json"
+	}
+	return filepath.Join(dir, "barkeep", "config.json")
+}
+
+// Load reads the configuration file at path on top of the defaults.
+// A missing file is not an error; the defaults are returned instead.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	cfg.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Path returns the file the configuration was loaded from, or the default
+// location for a configuration that was not loaded
+func (c *Config) Path() string {
+	if c.path == "" {
+		return DefaultPath()
+	}
+	return c.path
+}
+
+// HistoryPath returns the play history file
+func (c *Config) HistoryPath() string {
+	if c.Audio.HistoryFile != "" {
+		return ExpandPath(c.Audio.HistoryFile)
+	}
+	return filepath.Join(filepath.Dir(c.Path()), "history.json")
+}
+
+// LibraryPaths returns the expanded library roots
+func (c *Config) LibraryPaths() []string {
+	roots := c.Audio.LibraryRoots
+	if len(roots) == 0 {
+		roots = []string{c.Audio.MusicDirectory}
+	}
+
+	paths := make([]string, len(roots))
+	for i, root := range roots {
+		paths[i] = ExpandPath(root)
+	}
+	return paths
+}
+
+// LibraryPath returns the library catalog file
+func (c *Config) LibraryPath() string {
+	if c.Audio.LibraryFile != "" {
+		return ExpandPath(c.Audio.LibraryFile)
+	}
+	return filepath.Join(filepath.Dir(c.Path()), "library.json")
+}
+
+// PlaylistsPath returns the saved playlists directory
+func (c *Config) PlaylistsPath() string {
+	if c.Audio.PlaylistsDirectory != "" {
+		return ExpandPath(c.Audio.PlaylistsDirectory)
+	}
+	return filepath.Join(filepath.Dir(c.Path()), "playlists")
+}
+
+// QueuePath returns the saved queue file
+func (c *Config) QueuePath() string {
+	if c.Audio.QueueFile != "" {
+		return ExpandPath(c.Audio.QueueFile)
+	}
+	return filepath.Join(filepath.Dir(c.Path()), "queue.json")
+}
+
+// MixerPath returns the saved mixer levels file
+func (c *Config) MixerPath() string {
+	if c.Audio.MixerFile != "" {
+		return ExpandPath(c.Audio.MixerFile)
+	}
+	return filepath.Join(filepath.Dir(c.Path()), "mixer.json")
+}
+
+// EffectsPath returns the saved music effects file
+func (c *Config) EffectsPath() string {
+	if c.Audio.EffectsFile != "" {
+		return ExpandPath(c.Audio.EffectsFile)
+	}
+	return filepath.Join(filepath.Dir(c.Path()), "effects.json")
+}
+
+// Save writes the configuration to path, creating parent directories
+func (c *Config) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write config %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// SaveAnalogThresholds merges calibrated analog button thresholds into the
+// configuration file at path. Only hardware.analog_thresholds changes; the
+// rest of the file is kept as written, so defaults are not filled in.
+func SaveAnalogThresholds(path string, thresholds map[string]AnalogThresholdConfig) error {
+	var file map[string]json.RawMessage
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read config %s: %w", path, err)
+	default:
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+	if file == nil {
+		file = make(map[string]json.RawMessage)
+	}
+
+	var hardware map[string]json.RawMessage
+	if raw, ok := file["hardware"]; ok {
+		if err := json.Unmarshal(raw, &hardware); err != nil {
+			return fmt.Errorf("failed to parse hardware settings in %s: %w", path, err)
+		}
+	}
+	if hardware == nil {
+		hardware = make(map[string]json.RawMessage)
+	}
+
+	saved := make(map[string]AnalogThresholdConfig)
+	if raw, ok := hardware["analog_thresholds"]; ok {
+		if err := json.Unmarshal(raw, &saved); err != nil {
+			return fmt.Errorf("failed to parse analog thresholds in %s: %w", path, err)
+		}
+	}
+	if saved == nil {
+		saved = make(map[string]AnalogThresholdConfig)
+	}
+	for name, threshold := range thresholds {
+		saved[name] = threshold
+	}
+
+	if hardware["analog_thresholds"], err = json.Marshal(saved); err != nil {
+		return fmt.Errorf("failed to encode analog thresholds: %w", err)
+	}
+	if file["hardware"], err = json.Marshal(hardware); err != nil {
+		return fmt.Errorf("failed to encode hardware settings: %w", err)
+	}
+	if data, err = json.MarshalIndent(file, "", "  "); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write config %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// ExpandPath expands environment variables and a leading "~" in path
+func ExpandPath(path string) string {
+	path = os.ExpandEnv(path)
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+
+	return path
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAnalogThresholdsKeepsTheRestOfTheFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	written := `{
+  "theme": "dark",
+  "hardware": {
+    "enabled": true,
+    "analog_thresholds": {
+      "Up": {"lower": 1, "upper": 2, "hysteresis": 0.5}
+    }
+  },
+  "custom": {"kept": [1, 2.50]}
+}`
+	if err := os.WriteFile(path, []byte(written), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	down := AnalogThresholdConfig{Lower: 98.5, Upper: 151.5, Hysteresis: 3.5}
+	if err := SaveAnalogThresholds(path, map[string]AnalogThresholdConfig{"Down": down}); err != nil {
+		t.Fatalf("SaveAnalogThresholds: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]json.RawMessage
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("saved file is not JSON: %v", err)
+	}
+	if len(saved) != 3 {
+		t.Errorf("saved keys = %d, want only theme, hardware and custom", len(saved))
+	}
+	var custom bytes.Buffer
+	if err := json.Compact(&custom, saved["custom"]); err != nil || custom.String() != `{"kept":[1,2.50]}` {
+		t.Errorf("custom = %s, want it unchanged", saved["custom"])
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Hardware.Enabled || cfg.Theme != "dark" {
+		t.Errorf("other settings lost: enabled %v, theme %q", cfg.Hardware.Enabled, cfg.Theme)
+	}
+	if got := cfg.Hardware.AnalogThresholds["Down"]; got != down {
+		t.Errorf("Down = %+v, want %+v", got, down)
+	}
+	if got := cfg.Hardware.AnalogThresholds["Up"]; got.Upper != 2 {
+		t.Errorf("Up = %+v, want the earlier calibration kept", got)
+	}
+}
+
+func TestSaveAnalogThresholdsCreatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "barkeep", "config.json")
+	up := AnalogThresholdConfig{Lower: 100, Upper: 150, Hysteresis: 2}
+	if err := SaveAnalogThresholds(path, map[string]AnalogThresholdConfig{"Up": up}); err != nil {
+		t.Fatalf("SaveAnalogThresholds: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("saved file: %v", err)
+	}
+	if len(saved) != 1 || len(saved["hardware"]) != 1 {
+		t.Errorf("saved %s, want only hardware.analog_thresholds", data)
+	}
+}
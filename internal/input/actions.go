@@ -0,0 +1,119 @@
+package input
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Action is something a physical button can be mapped to
+type Action string
+
+// ActionKind groups actions by how the application handles them
+type ActionKind int
+
+const (
+	// NavigationKind actions are delivered to the UI as key presses
+	NavigationKind ActionKind = iota
+	// ScreenKind actions switch directly to a screen
+	ScreenKind
+	// TransportKind actions control music playback on any screen
+	TransportKind
+)
+
+// Navigation actions
+const (
+	ActionUp       Action = "up"
+	ActionDown     Action = "down"
+	ActionLeft     Action = "left"
+	ActionRight    Action = "right"
+	ActionSelect   Action = "select"
+	ActionBack     Action = "back"
+	ActionFocusNav Action = "focus_nav"
+	ActionPageUp   Action = "page_up"
+	ActionPageDown Action = "page_down"
+)
+
+// Screen actions
+const (
+	ActionScreenHome          Action = "screen_home"
+	ActionScreenFood          Action = "screen_food"
+	ActionScreenAtmosphere    Action = "screen_atmosphere"
+	ActionScreenEntertainment Action = "screen_entertainment"
+	ActionScreenSettings      Action = "screen_settings"
+)
+
+// Transport actions
+const (
+	ActionPlayPause   Action = "play_pause"
+	ActionNext        Action = "next"
+	ActionPrevious    Action = "previous"
+	ActionVolumeUp    Action = "volume_up"
+	ActionVolumeDown  Action = "volume_down"
+	ActionSeekForward Action = "seek_forward"
+	ActionSeekBack    Action = "seek_back"
+)
+
+// actionInfo describes how an action is presented and handled
+type actionInfo struct {
+	label string
+	kind  ActionKind
+	key   tea.KeyType
+}
+
+var actions = map[Action]actionInfo{
+	ActionUp:       {label: "Up", kind: NavigationKind, key: tea.KeyUp},
+	ActionDown:     {label: "Down", kind: NavigationKind, key: tea.KeyDown},
+	ActionLeft:     {label: "Left", kind: NavigationKind, key: tea.KeyLeft},
+	ActionRight:    {label: "Right", kind: NavigationKind, key: tea.KeyRight},
+	ActionSelect:   {label: "Select", kind: NavigationKind, key: tea.KeyEnter},
+	ActionBack:     {label: "Back", kind: NavigationKind, key: tea.KeyEsc},
+	ActionFocusNav: {label: "Menu", kind: NavigationKind, key: tea.KeyTab},
+	ActionPageUp:   {label: "Prev Screen", kind: NavigationKind, key: tea.KeyPgUp},
+	ActionPageDown: {label: "Next Screen", kind: NavigationKind, key: tea.KeyPgDown},
+
+	ActionScreenHome:          {label: "Home", kind: ScreenKind},
+	ActionScreenFood:          {label: "Food & Drink", kind: ScreenKind},
+	ActionScreenAtmosphere:    {label: "Atmosphere", kind: ScreenKind},
+	ActionScreenEntertainment: {label: "Entertainment", kind: ScreenKind},
+	ActionScreenSettings:      {label: "Settings", kind: ScreenKind},
+
+	ActionPlayPause:   {label: "Play/Pause", kind: TransportKind},
+	ActionNext:        {label: "Next", kind: TransportKind},
+	ActionPrevious:    {label: "Previous", kind: TransportKind},
+	ActionVolumeUp:    {label: "Vol+", kind: TransportKind},
+	ActionVolumeDown:  {label: "Vol-", kind: TransportKind},
+	ActionSeekForward: {label: "+10s", kind: TransportKind},
+	ActionSeekBack:    {label: "-10s", kind: TransportKind},
+}
+
+// ParseAction validates an action name
+func ParseAction(name string) (Action, error) {
+	action := Action(name)
+	if _, ok := actions[action]; !ok {
+		return "", fmt.Errorf("unknown button action: %q", name)
+	}
+	return action, nil
+}
+
+// Label returns the short description shown in button hints
+func (a Action) Label() string {
+	if info, ok := actions[a]; ok {
+		return info.label
+	}
+	return string(a)
+}
+
+// Kind returns how the application handles the action
+func (a Action) Kind() ActionKind {
+	return actions[a].kind
+}
+
+// KeyMsg returns the key press a navigation action stands for
+func (a Action) KeyMsg() (tea.KeyMsg, bool) {
+	info, ok := actions[a]
+	if !ok || info.kind != NavigationKind {
+		return tea.KeyMsg{}, false
+	}
+	return tea.KeyMsg{Type: info.key}, true
+}
@@ -0,0 +1,153 @@
+package input
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thornzero/barkeep/internal/config"
+	"github.com/thornzero/barkeep/internal/services"
+)
+
+// Binding maps a physical button event to an action
+type Binding struct {
+	Button  services.Button
+	Trigger services.ButtonEventType
+	Action  Action
+}
+
+// ButtonMsg is delivered to the application when a mapped button event occurs
+type ButtonMsg struct {
+	Event  services.ButtonEvent
+	Action Action
+}
+
+// Hint describes a button binding for the status bar
+type Hint struct {
+	Button services.Button
+	Label  string
+	Action Action
+}
+
+// triggers maps configuration names to button event types
+var triggers = map[string]services.ButtonEventType{
+	"pressed":      services.ButtonPressed,
+	"released":     services.ButtonReleased,
+	"long_press":   services.ButtonLongPress,
+	"double_press": services.ButtonDoublePress,
+	"repeat":       services.ButtonRepeat,
+}
+
+// ParseBindings converts configured button actions into bindings
+func ParseBindings(cfg []config.ButtonActionConfig) ([]Binding, error) {
+	bindings := make([]Binding, 0, len(cfg))
+
+	for _, entry := range cfg {
+		button, err := services.ParseButton(entry.Button)
+		if err != nil {
+			return nil, err
+		}
+
+		trigger := services.ButtonPressed
+		if entry.On != "" {
+			var ok bool
+			trigger, ok = triggers[strings.ToLower(entry.On)]
+			if !ok {
+				return nil, fmt.Errorf("unknown button event %q for button %s", entry.On, button)
+			}
+		}
+
+		action, err := ParseAction(entry.Action)
+		if err != nil {
+			return nil, err
+		}
+
+		bindings = append(bindings, Binding{Button: button, Trigger: trigger, Action: action})
+	}
+
+	return bindings, nil
+}
+
+// ButtonLabel returns the label printed on a physical button
+func ButtonLabel(button services.Button) string {
+	switch button {
+	case services.ButtonUp:
+		return "▲"
+	case services.ButtonDown:
+		return "▼"
+	default:
+		return button.String()
+	}
+}
+
+// Bridge subscribes to MegaInd button events and turns mapped events into
+// Bubble Tea messages
+type Bridge struct {
+	bindings    []Binding
+	events      <-chan services.ButtonEvent
+	unsubscribe func()
+	// suspended drops events instead of turning them into actions
+	suspended atomic.Bool
+}
+
+// NewBridge subscribes to the controller's button events
+func NewBridge(controller services.MegaInd, bindings []Binding) *Bridge {
+	events, unsubscribe := controller.Subscribe()
+	return &Bridge{
+		bindings:    bindings,
+		events:      events,
+		unsubscribe: unsubscribe,
+	}
+}
+
+// Listen returns a command that waits for the next mapped button event.
+// It must be re-issued after every ButtonMsg.
+func (b *Bridge) Listen() tea.Cmd {
+	return func() tea.Msg {
+		for event := range b.events {
+			if b.suspended.Load() {
+				continue
+			}
+			if action, ok := b.lookup(event); ok {
+				return ButtonMsg{Event: event, Action: action}
+			}
+		}
+		return nil
+	}
+}
+
+// lookup finds the action bound to an event
+func (b *Bridge) lookup(event services.ButtonEvent) (Action, bool) {
+	for _, binding := range b.bindings {
+		if binding.Button == event.Button && binding.Trigger == event.Type {
+			return binding.Action, true
+		}
+	}
+	return "", false
+}
+
+// Hints returns the actions bound to a single press of each button
+func (b *Bridge) Hints() []Hint {
+	var hints []Hint
+	for _, button := range services.AllButtons {
+		for _, binding := range b.bindings {
+			if binding.Button == button && binding.Trigger == services.ButtonPressed {
+				hints = append(hints, Hint{Button: button, Label: ButtonLabel(button), Action: binding.Action})
+				break
+			}
+		}
+	}
+	return hints
+}
+
+// SetSuspended stops or resumes turning button events into actions. Events
+// that arrive while suspended are dropped.
+func (b *Bridge) SetSuspended(suspended bool) {
+	b.suspended.Store(suspended)
+}
+
+// Close stops receiving button events
+func (b *Bridge) Close() {
+	b.unsubscribe()
+}
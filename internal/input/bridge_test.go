@@ -0,0 +1,46 @@
+package input
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thornzero/barkeep/internal/services"
+)
+
+func TestSuspendedBridgeDropsButtonEvents(t *testing.T) {
+	simulator := services.NewSimulatedMegaInd()
+	controller := services.NewMegaIndController()
+	if err := controller.Attach(simulator); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	defer controller.Dispose()
+
+	bridge := NewBridge(controller, []Binding{
+		{Button: services.ButtonUp, Trigger: services.ButtonPressed, Action: ActionUp},
+		{Button: services.ButtonA, Trigger: services.ButtonPressed, Action: ActionSelect},
+	})
+	defer bridge.Close()
+
+	msgs := make(chan any, 1)
+	go func() { msgs <- bridge.Listen()() }()
+
+	// A press while suspended, as during calibration, does nothing
+	bridge.SetSuspended(true)
+	simulator.PressButton(services.ButtonUp)
+	select {
+	case msg := <-msgs:
+		t.Fatalf("suspended bridge delivered %v", msg)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	bridge.SetSuspended(false)
+	simulator.PressButton(services.ButtonA)
+	select {
+	case msg := <-msgs:
+		if button, ok := msg.(ButtonMsg); !ok || button.Action != ActionSelect {
+			t.Errorf("message after resuming = %v, want the select action", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no action after resuming")
+	}
+}
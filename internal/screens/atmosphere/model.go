@@ -1,6 +1,9 @@
 package atmosphere
 
 import (
+	"fmt"
+	"strings"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/thornzero/barkeep/internal/services"
@@ -18,6 +21,7 @@ type Model struct {
 
 	// Dependencies
 	themeProvider theme.Provider
+	thermal       *services.ThermalController
 }
 
 // NewModel creates a new atmosphere screen model
@@ -48,6 +52,11 @@ func (m *Model) SetSize(width, height int) {
 	m.height = height
 }
 
+// SetThermal shows the status of the thermal controller; nil hides it
+func (m *Model) SetThermal(thermal *services.ThermalController) {
+	m.thermal = thermal
+}
+
 // Update handles messages and updates the atmosphere screen state
 func (m *Model) Update(msg tea.Msg) (*Model, tea.Cmd) {
 	switch msg := msg.(type) {
@@ -71,5 +80,35 @@ func (m *Model) View() string {
 	// Wrap the content text to fit
 	wrappedContent := services.Txt.WrapText(m.content, contentWidth)
 
+	if m.thermal != nil {
+		wrappedContent = lipgloss.JoinVertical(lipgloss.Left, m.renderThermal(), "", wrappedContent)
+	}
+
 	return styles.BodyStyle.Render(wrappedContent)
 }
+
+// renderThermal renders the fan controller status
+func (m *Model) renderThermal() string {
+	styles := m.themeProvider.GetStyles()
+	status := m.thermal.Status()
+
+	// Fan speed gauge, one cell per 10%
+	filled := status.Speed / 10
+	gauge := strings.Repeat("█", filled) + strings.Repeat("░", 10-filled)
+	fan := fmt.Sprintf("Fan:         %s %3d%%", gauge, status.Speed)
+	if status.Kicking {
+		fan += " (spin-up)"
+	}
+
+	lines := []string{
+		styles.SubHeadingStyle.Render("Cooling"),
+		fmt.Sprintf("Temperature: %.1f°C", status.Temperature),
+		fan,
+		fmt.Sprintf("Control:     %s from %s", status.Mode, status.Source),
+	}
+	if status.Err != nil {
+		lines = append(lines, styles.ErrorStyle.Render("Error: "+status.Err.Error()))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
@@ -0,0 +1,237 @@
+package jukebox
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thornzero/barkeep/internal/services"
+)
+
+// BrowseMode selects how the music pane lists the collection
+type BrowseMode int
+
+const (
+	BrowseFolders BrowseMode = iota
+	BrowseArtists
+	BrowseAlbums
+	BrowseGenres
+)
+
+func (b BrowseMode) String() string {
+	switch b {
+	case BrowseArtists:
+		return "Artists"
+	case BrowseAlbums:
+		return "Albums"
+	case BrowseGenres:
+		return "Genres"
+	default:
+		return "Folders"
+	}
+}
+
+// Icon returns the pane title icon for the mode
+func (b BrowseMode) Icon() string {
+	switch b {
+	case BrowseArtists:
+		return "🎤"
+	case BrowseAlbums:
+		return "💿"
+	case BrowseGenres:
+		return "🏷️"
+	default:
+		return "📁"
+	}
+}
+
+// browseLevel is a position in the library views; the zero value is the
+// top of the current mode
+type browseLevel struct {
+	artist string
+	album  *services.LibraryAlbum
+	genre  string
+}
+
+// libraryChangedMsg is sent when the library catalog changes
+type libraryChangedMsg struct{}
+
+// SetLibrary sets the catalog used for tags and the library views
+func (m *Model) SetLibrary(library services.LibraryServiceInterface) {
+	m.library = library
+	m.reloadBrowser()
+}
+
+// listenLibrary waits for the next catalog change; it must be re-issued
+// after each libraryChangedMsg
+func (m *Model) listenLibrary() tea.Cmd {
+	if m.library == nil {
+		return nil
+	}
+
+	changes := m.library.Changes()
+	return func() tea.Msg {
+		<-changes
+		return libraryChangedMsg{}
+	}
+}
+
+// cycleBrowseMode switches to the next browse mode at its top level
+func (m *Model) cycleBrowseMode() {
+	if m.library == nil {
+		return
+	}
+	m.browseMode = (m.browseMode + 1) % (BrowseGenres + 1)
+	m.browse = browseLevel{}
+	m.reloadBrowser()
+	m.directoryList.ResetSelected()
+}
+
+// reloadBrowser refreshes the music pane for the current mode and level,
+// keeping the cursor on the item it was on
+func (m *Model) reloadBrowser() {
+	selected, hadSelection := m.directoryList.SelectedItem().(FileItem)
+
+	if m.browseMode == BrowseFolders || m.library == nil {
+		m.loadDirectory(m.currentDir)
+	} else {
+		m.directoryList.SetItems(m.browseItems())
+	}
+
+	if hadSelection {
+		for i, item := range m.directoryList.Items() {
+			if item.(FileItem).key() == selected.key() {
+				m.directoryList.Select(i)
+				break
+			}
+		}
+	}
+}
+
+// browseItems lists the entries at the current library level
+func (m *Model) browseItems() []list.Item {
+	var items []list.Item
+	group := func(name string, level browseLevel) {
+		items = append(items, FileItem{name: name, isDir: true, level: &level})
+	}
+	tracks := func(tracks []services.LibraryTrack, label func(services.LibraryTrack) string) {
+		for _, track := range tracks {
+			items = append(items, FileItem{name: label(track), path: track.Path, isAudio: true})
+		}
+	}
+
+	level := m.browse
+	switch m.browseMode {
+	case BrowseArtists:
+		switch {
+		case level.album != nil:
+			group("..", browseLevel{artist: level.artist})
+			tracks(m.library.AlbumTracks(*level.album), albumTrackLabel)
+		case level.artist != "":
+			group("..", browseLevel{})
+			for _, album := range m.library.Albums(level.artist) {
+				group(album.Title, browseLevel{artist: level.artist, album: &album})
+			}
+		default:
+			for _, artist := range m.library.Artists() {
+				group(artist, browseLevel{artist: artist})
+			}
+		}
+
+	case BrowseAlbums:
+		if level.album != nil {
+			group("..", browseLevel{})
+			tracks(m.library.AlbumTracks(*level.album), albumTrackLabel)
+		} else {
+			for _, album := range m.library.Albums("") {
+				group(album.Title+" — "+album.Artist, browseLevel{album: &album})
+			}
+		}
+
+	case BrowseGenres:
+		if level.genre != "" {
+			group("..", browseLevel{})
+			tracks(m.library.GenreTracks(level.genre), trackLabel)
+		} else {
+			for _, genre := range m.library.Genres() {
+				group(genre, browseLevel{genre: genre})
+			}
+		}
+	}
+
+	return items
+}
+
+// levelTracks returns every track under a library level
+func (m *Model) levelTracks(level browseLevel) []services.LibraryTrack {
+	switch {
+	case level.album != nil:
+		return m.library.AlbumTracks(*level.album)
+	case level.artist != "":
+		var tracks []services.LibraryTrack
+		for _, album := range m.library.Albums(level.artist) {
+			tracks = append(tracks, m.library.AlbumTracks(album)...)
+		}
+		return tracks
+	case level.genre != "":
+		return m.library.GenreTracks(level.genre)
+	default:
+		return nil
+	}
+}
+
+// browseLocation describes the current position in the music pane
+func (m *Model) browseLocation() string {
+	if m.browseMode == BrowseFolders {
+		return "Path: " + m.currentDir
+	}
+
+	crumbs := []string{m.browseMode.String()}
+	if m.browse.artist != "" {
+		crumbs = append(crumbs, m.browse.artist)
+	}
+	if m.browse.album != nil {
+		crumbs = append(crumbs, m.browse.album.Title)
+	}
+	if m.browse.genre != "" {
+		crumbs = append(crumbs, m.browse.genre)
+	}
+	return strings.Join(crumbs, " › ")
+}
+
+// trackLabel names a track by title and artist
+func trackLabel(track services.LibraryTrack) string {
+	return track.DisplayTitle() + " — " + track.DisplayArtist()
+}
+
+// albumTrackLabel names a track within its album by number and title
+func albumTrackLabel(track services.LibraryTrack) string {
+	label := track.DisplayTitle()
+	if track.Track > 0 {
+		label = fmt.Sprintf("%02d. %s", track.Track, label)
+	}
+	if track.Duration > 0 {
+		label += " (" + formatDuration(track.Duration) + ")"
+	}
+	return label
+}
+
+// playlistItem builds a queue entry, using the track's tags when it is in
+// the library
+func (m *Model) playlistItem(path, name string, index int) PlaylistItem {
+	item := PlaylistItem{name: name, path: path, index: index}
+	if m.library == nil {
+		return item
+	}
+
+	if track, ok := m.library.Track(path); ok {
+		if track.Title != "" {
+			item.name = trackLabel(track)
+		}
+		if track.Duration > 0 {
+			item.duration = formatDuration(track.Duration)
+		}
+	}
+	return item
+}
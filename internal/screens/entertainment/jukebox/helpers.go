@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thornzero/barkeep/internal/services"
+	"github.com/thornzero/barkeep/internal/sound"
 )
 
 // loadDirectory loads files from the specified directory
@@ -39,10 +41,14 @@ func (m *Model) loadDirectory(path string) {
 				isDir: entry.IsDir(),
 			}
 
-			// Check if it's an audio file
+			// Check if it's an audio file, naming it by its tags if known
 			if !entry.IsDir() {
-				ext := strings.ToLower(filepath.Ext(entry.Name()))
-				item.isAudio = ext == ".mp3" || ext == ".wav" || ext == ".flac" || ext == ".ogg"
+				item.isAudio = services.AudioDecoders.Supports(entry.Name())
+				if m.library != nil {
+					if track, ok := m.library.Track(item.path); ok && track.Title != "" {
+						item.name = trackLabel(track)
+					}
+				}
 			}
 
 			items = append(items, item)
@@ -53,6 +59,18 @@ func (m *Model) loadDirectory(path string) {
 	m.currentDir = path
 }
 
+// playTrack loads and plays a file, showing why if it cannot be played
+func (m *Model) playTrack(path string) tea.Cmd {
+	if err := m.audioManager.LoadTrack(path); err != nil {
+		m.loadError = fmt.Sprintf("Cannot play %s: %v", filepath.Base(path), err)
+		return sound.Emit(services.UIError)
+	}
+	m.loadError = ""
+	m.audioManager.Play()
+	m.nowPlayingTrack = path
+	return nil
+}
+
 // handleDirectorySelection handles selection in the directory pane
 func (m *Model) handleDirectorySelection() tea.Cmd {
 	selected := m.directoryList.SelectedItem()
@@ -62,17 +80,21 @@ func (m *Model) handleDirectorySelection() tea.Cmd {
 
 	fileItem := selected.(FileItem)
 
-	if fileItem.isDir {
+	if fileItem.level != nil {
+		// Open a library group
+		m.browse = *fileItem.level
+		m.reloadBrowser()
+		m.directoryList.ResetSelected()
+	} else if fileItem.isDir {
 		// Navigate to directory
 		m.loadDirectory(fileItem.path)
+	} else if isPlaylistFile(fileItem.path) {
+		// Play a playlist file in place of the queue
+		return m.openPlaylistFile(fileItem.path)
 	} else if fileItem.isAudio {
 		// Load and play the audio file
 		if m.audioManager != nil {
-			err := m.audioManager.LoadTrack(fileItem.path)
-			if err == nil {
-				m.audioManager.Play()
-				m.nowPlayingTrack = fileItem.path
-			}
+			return m.playTrack(fileItem.path)
 		}
 	}
 
@@ -90,11 +112,7 @@ func (m *Model) handlePlaylistSelection() tea.Cmd {
 
 	// Load and play the selected track
 	if m.audioManager != nil {
-		err := m.audioManager.LoadTrack(playlistItem.path)
-		if err == nil {
-			m.audioManager.Play()
-			m.nowPlayingTrack = playlistItem.path
-		}
+		return m.playTrack(playlistItem.path)
 	}
 
 	return nil
@@ -108,56 +126,99 @@ func (m *Model) addToPlaylist() tea.Cmd {
 	}
 
 	fileItem := selected.(FileItem)
-	if !fileItem.isAudio {
+
+	// Library groups add every track under them
+	var paths []string
+	switch {
+	case fileItem.level != nil && fileItem.name != "..":
+		for _, track := range m.levelTracks(*fileItem.level) {
+			paths = append(paths, track.Path)
+		}
+	case fileItem.isAudio:
+		paths = []string{fileItem.path}
+	}
+	if len(paths) == 0 {
 		return nil
 	}
 
 	// Add to playlist
 	items := m.playlist.Items()
-	newItem := PlaylistItem{
-		name:  fileItem.name,
-		path:  fileItem.path,
-		index: len(items),
+	for _, path := range paths {
+		items = append(items, m.playlistItem(path, filepath.Base(path), len(items)))
 	}
-
-	items = append(items, newItem)
 	m.playlist.SetItems(items)
 
 	// Also add to audio manager playlist
 	if m.audioManager != nil {
-		m.audioManager.AddToPlaylist([]string{fileItem.path})
+		m.audioManager.AddToPlaylist(paths)
 	}
 
 	return nil
 }
 
-// removeFromPlaylist removes the current selection from the playlist
-func (m *Model) removeFromPlaylist() tea.Cmd {
-	selected := m.playlist.SelectedItem()
-	if selected == nil {
+// editQueue applies a queue pane edit to the selected entry, keeping the
+// cursor on an entry that was moved up or down
+func (m *Model) editQueue(key string) tea.Cmd {
+	if m.audioManager == nil {
 		return nil
 	}
 
-	// Remove from playlist
-	items := m.playlist.Items()
-	selectedIndex := m.playlist.Index()
+	count := len(m.playlist.Items())
+	if count == 0 && key != "u" {
+		return nil
+	}
 
-	if selectedIndex >= 0 && selectedIndex < len(items) {
-		// Remove the item
-		items = append(items[:selectedIndex], items[selectedIndex+1:]...)
-		m.playlist.SetItems(items)
+	index := m.playlist.Index()
+	cursor := index
+	name := ""
+	if item, ok := m.playlist.SelectedItem().(PlaylistItem); ok {
+		name = item.name
+	}
 
-		// Update audio manager playlist
-		if m.audioManager != nil {
-			var tracks []string
-			for _, item := range items {
-				if playlistItem, ok := item.(PlaylistItem); ok {
-					tracks = append(tracks, playlistItem.path)
-				}
-			}
-			m.audioManager.SetPlaylist(tracks)
+	var err error
+	m.queueStatus = ""
+	switch key {
+	case "K", "shift+up":
+		cursor = max(index-1, 0)
+		err = m.audioManager.MoveInPlaylist(index, cursor)
+	case "J", "shift+down":
+		cursor = min(index+1, count-1)
+		err = m.audioManager.MoveInPlaylist(index, cursor)
+	case "N":
+		if err = m.audioManager.MoveToNext(index); err == nil {
+			m.queueStatus = "Playing next: " + name
+		}
+	case "A":
+		if err = m.audioManager.MoveAfterAlbum(index); err == nil {
+			m.queueStatus = "Playing after this album: " + name
 		}
+	case "d":
+		err = m.audioManager.RemoveFromPlaylist(index)
+	case "c":
+		m.audioManager.ClearPlaylist()
+		m.queueStatus = "Queue cleared (u to undo)"
+	case "D":
+		removed := m.audioManager.RemoveDuplicates()
+		m.queueStatus = fmt.Sprintf("Removed %d duplicates", removed)
+	case "u":
+		if err = m.audioManager.UndoPlaylistEdit(); err == nil {
+			m.queueStatus = "Undone"
+		}
+	}
+	if err != nil {
+		m.queueStatus = err.Error()
 	}
 
+	m.syncPlaylist()
+	m.playlist.Select(min(cursor, max(len(m.playlist.Items())-1, 0)))
+	if err != nil {
+		return sound.Emit(services.UIError)
+	}
 	return nil
 }
+
+// formatDuration formats a track time as m:ss
+func formatDuration(d time.Duration) string {
+	seconds := int(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
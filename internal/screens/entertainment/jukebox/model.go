@@ -25,11 +25,21 @@ type FileItem struct {
 	path    string
 	isDir   bool
 	isAudio bool
+	// level is set on library groups and opens that level when selected
+	level *browseLevel
 }
 
 // Implement the list.Item interface
 func (f FileItem) FilterValue() string { return f.name }
 
+// key identifies an item across reloads
+func (f FileItem) key() string {
+	if f.level != nil || f.path == "" {
+		return f.name
+	}
+	return f.path
+}
+
 // PlaylistItem represents a track in the playlist
 type PlaylistItem struct {
 	name     string
@@ -55,18 +65,33 @@ type Model struct {
 	musicDirectory string
 	directoryList  list.Model
 	currentDir     string
+	browseMode     BrowseMode
+	browse         browseLevel
 
 	// Playlist
-	playlist list.Model
+	playlist    list.Model
+	queueStatus string
+
+	// Catalog search
+	search searchOverlay
+
+	// Saved playlists
+	playlists *services.PlaylistStore
+	manager   playlistManager
 
 	// Now playing
 	nowPlayingTrack string
 	playbackStatus  string
-	progress        string
+	loadError       string
+	position        time.Duration
+	duration        time.Duration
 	volume          float64
+	muted           bool
+	shuffle         services.ShuffleMode
 
 	// Dependencies
 	audioManager  services.AudioServiceInterface
+	library       services.LibraryServiceInterface
 	themeProvider theme.Provider
 
 	// Status updates
@@ -87,11 +112,19 @@ func NewModel(audioManager services.AudioServiceInterface, themeProvider theme.P
 	playlistList.SetShowStatusBar(false)
 	playlistList.SetFilteringEnabled(false)
 
+	// Page with the arrow and page keys only; letters are jukebox commands
+	for _, keys := range []*list.KeyMap{&dirList.KeyMap, &playlistList.KeyMap} {
+		keys.PrevPage.SetKeys("left", "pgup")
+		keys.NextPage.SetKeys("right", "pgdown")
+	}
+
 	jb := &Model{
 		activePane:     DirectoryPane,
 		musicDirectory: os.ExpandEnv("$HOME/Music"),
 		directoryList:  dirList,
 		playlist:       playlistList,
+		search:         newSearchOverlay(),
+		manager:        newPlaylistManager(),
 		currentDir:     os.ExpandEnv("$HOME/Music"),
 		volume:         1.0,
 		audioManager:   audioManager,
@@ -116,11 +149,19 @@ func (m *Model) SetSize(width, height int) {
 
 	m.directoryList.SetSize(listWidth, listHeight)
 	m.playlist.SetSize(listWidth, listHeight)
+	m.search.input.Width = max(m.width-12, 10)
+	m.manager.input.Width = max(m.width-30, 10)
+}
+
+// SetMusicDirectory sets the root directory of the music browser
+func (m *Model) SetMusicDirectory(dir string) {
+	m.musicDirectory = dir
+	m.loadDirectory(dir)
 }
 
 // Init initializes the jukebox model
 func (m *Model) Init() tea.Cmd {
-	return m.startStatusUpdates()
+	return tea.Batch(m.startStatusUpdates(), m.listenAudioEvents(), m.listenLibrary())
 }
 
 // Update handles messages and updates the jukebox state
@@ -132,6 +173,14 @@ func (m *Model) Update(msg tea.Msg) (*Model, tea.Cmd) {
 		m.SetSize(msg.Width-4, msg.Height-6) // Account for padding
 
 	case tea.KeyMsg:
+		// The search overlay takes every key while open
+		if m.search.Active() {
+			return m, m.handleSearchKey(msg)
+		}
+		if m.manager.open {
+			return m, m.handleManagerKey(msg)
+		}
+
 		cmd := m.handleKeyPress(msg)
 		if cmd != nil {
 			cmds = append(cmds, cmd)
@@ -140,6 +189,31 @@ func (m *Model) Update(msg tea.Msg) (*Model, tea.Cmd) {
 	case statusUpdateMsg:
 		m.updateStatus()
 		cmds = append(cmds, m.statusUpdateCmd())
+
+	case audioEventMsg:
+		m.updateStatus()
+		if msg.event.Type == services.QueueChanged {
+			m.syncPlaylist()
+		}
+		cmds = append(cmds, m.listenAudioEvents())
+
+	case libraryChangedMsg:
+		m.reloadBrowser()
+		m.syncPlaylist()
+		cmds = append(cmds, m.listenLibrary())
+	}
+
+	// Keep the search and prompt cursors blinking
+	if _, isKey := msg.(tea.KeyMsg); !isKey {
+		var cmd tea.Cmd
+		if m.search.Active() {
+			m.search.input, cmd = m.search.input.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+		if m.manager.input.Focused() {
+			m.manager.input, cmd = m.manager.input.Update(msg)
+			cmds = append(cmds, cmd)
+		}
 	}
 
 	// Update the active pane
@@ -198,12 +272,16 @@ func (m *Model) handleKeyPress(msg tea.KeyMsg) tea.Cmd {
 			return m.addToPlaylist()
 		}
 
-	case "d":
-		// Remove from playlist
+	case "d", "K", "J", "shift+up", "shift+down", "N", "A", "c", "D":
+		// Edit the queue around the selected entry
 		if m.activePane == PlaylistPane {
-			return m.removeFromPlaylist()
+			return m.editQueue(msg.String())
 		}
 
+	case "u":
+		// Undo the last queue change
+		return m.editQueue("u")
+
 	case "n":
 		// Next track
 		if m.audioManager != nil {
@@ -216,28 +294,42 @@ func (m *Model) handleKeyPress(msg tea.KeyMsg) tea.Cmd {
 			m.audioManager.Previous()
 		}
 
-	case "+", "=":
-		// Volume up
-		if m.volume < 1.0 {
-			m.volume += 0.1
-			if m.volume > 1.0 {
-				m.volume = 1.0
-			}
-			if m.audioManager != nil {
-				m.audioManager.SetVolume(m.volume)
-			}
+	case "/":
+		return m.openSearch()
+
+	case "m":
+		// Manage saved playlists
+		return m.openManager()
+
+	case "b":
+		// Browse by folder, artist, album or genre
+		m.cycleBrowseMode()
+
+	case "s":
+		// Cycle shuffle: off → random → smart
+		if m.audioManager != nil {
+			m.audioManager.SetShuffleMode((m.audioManager.GetShuffleMode() + 1) % (services.ShuffleSmart + 1))
+			m.updateStatus()
 		}
 
-	case "-":
-		// Volume down
-		if m.volume > 0.0 {
-			m.volume -= 0.1
-			if m.volume < 0.0 {
-				m.volume = 0.0
-			}
-			if m.audioManager != nil {
-				m.audioManager.SetVolume(m.volume)
+	case "[", "]":
+		return m.seek(msg.String() == "]")
+
+	case "left", "right":
+		// Arrow keys scrub while the controls pane is focused
+		if m.activePane == ControlsPane {
+			return m.seek(msg.String() == "right")
+		}
+
+	case "+", "=", "-":
+		// Move the master fader a step; the mixer keeps it within its limits
+		if m.audioManager != nil {
+			step := services.FaderStep
+			if msg.String() == "-" {
+				step = -step
 			}
+			m.audioManager.SetVolume(m.volume + step)
+			m.updateStatus()
 		}
 
 	case "h", "?":
@@ -247,6 +339,25 @@ func (m *Model) handleKeyPress(msg tea.KeyMsg) tea.Cmd {
 	return nil
 }
 
+// seekStep is how far one scrub key moves playback
+const seekStep = 10 * time.Second
+
+// seek scrubs the current track forwards or backwards by seekStep
+func (m *Model) seek(forward bool) tea.Cmd {
+	if m.audioManager == nil {
+		return nil
+	}
+
+	offset := -seekStep
+	if forward {
+		offset = seekStep
+	}
+	if err := m.audioManager.SeekRelative(offset); err == nil {
+		m.updateStatus()
+	}
+	return nil
+}
+
 // Status update functionality
 type statusUpdateMsg struct{}
 
@@ -260,11 +371,37 @@ func (m *Model) statusUpdateCmd() tea.Cmd {
 	})
 }
 
+// audioEventMsg carries a playback event from the audio manager
+type audioEventMsg struct {
+	event services.AudioEvent
+}
+
+// listenAudioEvents waits for the next playback event; it must be re-issued
+// after each audioEventMsg
+func (m *Model) listenAudioEvents() tea.Cmd {
+	if m.audioManager == nil {
+		return nil
+	}
+
+	events := m.audioManager.GetStatusChannel()
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			return nil
+		}
+		return audioEventMsg{event: event}
+	}
+}
+
 func (m *Model) updateStatus() {
 	if m.audioManager != nil {
 		status := m.audioManager.GetStatus()
 		m.nowPlayingTrack = status.CurrentTrack
 		m.volume = status.Volume
+		m.muted = status.Muted
+		m.position = status.Position
+		m.duration = status.Duration
+		m.shuffle = status.Shuffle
 
 		if status.IsPlaying {
 			m.playbackStatus = "Playing"
@@ -0,0 +1,324 @@
+package jukebox
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thornzero/barkeep/internal/services"
+	"github.com/thornzero/barkeep/internal/sound"
+)
+
+// managerPrompt is the text the playlist manager is asking for
+type managerPrompt int
+
+const (
+	promptNone managerPrompt = iota
+	promptSave
+	promptImport
+	promptExport
+)
+
+// playlistManager lists the saved playlists and asks for names and paths
+// when saving, importing and exporting
+type playlistManager struct {
+	open   bool
+	names  []string
+	cursor int
+	prompt managerPrompt
+	input  textinput.Model
+	status string
+	// deleting is the playlist waiting for a second press to delete
+	deleting string
+}
+
+// newPlaylistManager creates a closed manager
+func newPlaylistManager() playlistManager {
+	input := textinput.New()
+	input.CharLimit = 255
+	return playlistManager{input: input}
+}
+
+// Selected returns the highlighted playlist name
+func (p *playlistManager) Selected() (string, bool) {
+	if p.cursor >= len(p.names) {
+		return "", false
+	}
+	return p.names[p.cursor], true
+}
+
+// ask shows a prompt with an initial value
+func (p *playlistManager) ask(prompt managerPrompt, label, value string) tea.Cmd {
+	p.prompt = prompt
+	p.input.Prompt = label + ": "
+	p.input.SetValue(value)
+	p.input.CursorEnd()
+	return p.input.Focus()
+}
+
+// dismiss hides the prompt
+func (p *playlistManager) dismiss() {
+	p.prompt = promptNone
+	p.input.Blur()
+}
+
+// fail shows why an action failed and plays the error sound
+func (p *playlistManager) fail(err error) tea.Cmd {
+	p.status = err.Error()
+	return sound.Emit(services.UIError)
+}
+
+// succeed confirms an action and plays the success sound
+func (p *playlistManager) succeed(status string) tea.Cmd {
+	p.status = status
+	return sound.Emit(services.UISuccess)
+}
+
+// SetPlaylistStore sets where named playlists are saved
+func (m *Model) SetPlaylistStore(store *services.PlaylistStore) {
+	m.playlists = store
+}
+
+// openManager shows the playlist manager
+func (m *Model) openManager() tea.Cmd {
+	if m.playlists == nil {
+		return nil
+	}
+	m.manager.open = true
+	m.manager.status = ""
+	m.manager.deleting = ""
+	m.reloadManager()
+	return sound.Emit(services.UISelect)
+}
+
+// reloadManager lists the saved playlists, keeping the cursor on the
+// selected name where it still exists
+func (m *Model) reloadManager() {
+	selected, _ := m.manager.Selected()
+	names, err := m.playlists.Names()
+	if err != nil {
+		m.manager.status = err.Error()
+	}
+	m.manager.names = names
+	m.manager.cursor = max(0, min(m.manager.cursor, len(names)-1))
+	if i := slices.Index(names, selected); i >= 0 {
+		m.manager.cursor = i
+	}
+}
+
+// handleManagerKey handles keys while the playlist manager is open
+func (m *Model) handleManagerKey(msg tea.KeyMsg) tea.Cmd {
+	if m.manager.prompt != promptNone {
+		return m.handleManagerPrompt(msg)
+	}
+
+	key := msg.String()
+	if key != "d" {
+		m.manager.deleting = ""
+	}
+
+	selected, hasSelection := m.manager.Selected()
+	switch key {
+	case "esc", "m":
+		m.manager.open = false
+		return sound.Emit(services.UIBack)
+
+	case "up":
+		if m.manager.cursor > 0 {
+			m.manager.cursor--
+		}
+
+	case "down":
+		if m.manager.cursor < len(m.manager.names)-1 {
+			m.manager.cursor++
+		}
+
+	case "enter", "a":
+		if hasSelection {
+			return m.loadSavedPlaylist(selected, key == "enter")
+		}
+
+	case "s":
+		return m.manager.ask(promptSave, "Save queue as", selected)
+
+	case "d":
+		if !hasSelection {
+			break
+		}
+		if m.manager.deleting != selected {
+			m.manager.deleting = selected
+			m.manager.status = fmt.Sprintf("Press d again to delete %q", selected)
+			break
+		}
+		m.manager.deleting = ""
+		if err := m.playlists.Delete(selected); err != nil {
+			return m.manager.fail(err)
+		}
+		m.reloadManager()
+		return m.manager.succeed(fmt.Sprintf("Deleted %q", selected))
+
+	case "i":
+		return m.manager.ask(promptImport, "Import file", m.musicDirectory+string(filepath.Separator))
+
+	case "e":
+		if hasSelection {
+			return m.manager.ask(promptExport, "Export to", filepath.Join(m.musicDirectory, selected+".m3u8"))
+		}
+	}
+	return nil
+}
+
+// handleManagerPrompt edits and confirms the manager's prompt
+func (m *Model) handleManagerPrompt(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.manager.dismiss()
+		return sound.Emit(services.UIBack)
+
+	case "enter":
+		value := strings.TrimSpace(m.manager.input.Value())
+		prompt := m.manager.prompt
+		m.manager.dismiss()
+		if value == "" {
+			return nil
+		}
+
+		switch prompt {
+		case promptSave:
+			return m.saveQueueAs(value)
+		case promptImport:
+			return m.importPlaylist(value)
+		case promptExport:
+			if selected, ok := m.manager.Selected(); ok {
+				return m.exportPlaylist(selected, value)
+			}
+		}
+		return nil
+	}
+
+	var cmd tea.Cmd
+	m.manager.input, cmd = m.manager.input.Update(msg)
+	return cmd
+}
+
+// loadSavedPlaylist replaces the queue with a saved playlist and plays it,
+// or appends it to the queue
+func (m *Model) loadSavedPlaylist(name string, replace bool) tea.Cmd {
+	playlist, err := m.playlists.Load(name)
+	if err == nil {
+		err = m.queuePlaylist(playlist, replace)
+	}
+	if err != nil {
+		return m.manager.fail(err)
+	}
+	m.manager.open = false
+	return sound.Emit(services.UISelect)
+}
+
+// queuePlaylist replaces the queue with a playlist and starts playing it,
+// or appends the playlist to the queue
+func (m *Model) queuePlaylist(playlist *services.PlaylistFile, replace bool) error {
+	paths := playlist.Paths()
+	if len(paths) == 0 {
+		return fmt.Errorf("%q has no playable tracks", playlist.Name)
+	}
+	if m.audioManager == nil {
+		return nil
+	}
+
+	if replace {
+		m.audioManager.SetPlaylist(paths)
+		m.playTrack(paths[0])
+	} else {
+		m.audioManager.AddToPlaylist(paths)
+	}
+	m.syncPlaylist()
+	return nil
+}
+
+// saveQueueAs saves the queue as a named playlist
+func (m *Model) saveQueueAs(name string) tea.Cmd {
+	if m.audioManager == nil {
+		return nil
+	}
+
+	playlist := m.queueAsPlaylist(name)
+	if len(playlist.Entries) == 0 {
+		return m.manager.fail(fmt.Errorf("the queue is empty"))
+	}
+	if err := m.playlists.Save(playlist); err != nil {
+		return m.manager.fail(err)
+	}
+	m.reloadManager()
+	if i := slices.Index(m.manager.names, playlist.Name); i >= 0 {
+		m.manager.cursor = i
+	}
+	return m.manager.succeed(fmt.Sprintf("Saved %d tracks as %q", len(playlist.Entries), playlist.Name))
+}
+
+// importPlaylist copies an M3U, PLS or XSPF file into the saved playlists
+func (m *Model) importPlaylist(path string) tea.Cmd {
+	playlist, err := services.ReadPlaylistFile(path)
+	if err == nil {
+		err = m.playlists.Save(playlist)
+	}
+	if err != nil {
+		return m.manager.fail(err)
+	}
+	m.reloadManager()
+	return m.manager.succeed(fmt.Sprintf("Imported %d tracks as %q", len(playlist.Entries), playlist.Name))
+}
+
+// exportPlaylist writes a saved playlist to a file whose extension picks
+// the format
+func (m *Model) exportPlaylist(name, path string) tea.Cmd {
+	playlist, err := m.playlists.Load(name)
+	if err == nil {
+		err = services.WritePlaylistFile(path, playlist)
+	}
+	if err != nil {
+		return m.manager.fail(err)
+	}
+	return m.manager.succeed(fmt.Sprintf("Exported %q to %s", name, path))
+}
+
+// queueAsPlaylist builds a playlist from the queue, with titles and lengths
+// from the library where known
+func (m *Model) queueAsPlaylist(name string) *services.PlaylistFile {
+	playlist := &services.PlaylistFile{Name: strings.TrimSpace(name)}
+	for _, path := range m.audioManager.GetPlaylist() {
+		entry := services.PlaylistEntry{Path: path}
+		if m.library != nil {
+			if track, ok := m.library.Track(path); ok {
+				if track.Title != "" {
+					entry.Title = track.DisplayArtist() + " - " + track.Title
+				}
+				entry.Duration = track.Duration
+			}
+		}
+		playlist.Entries = append(playlist.Entries, entry)
+	}
+	return playlist
+}
+
+// openPlaylistFile replaces the queue with a playlist file picked in the
+// folder browser
+func (m *Model) openPlaylistFile(path string) tea.Cmd {
+	playlist, err := services.ReadPlaylistFile(path)
+	if err == nil {
+		err = m.queuePlaylist(playlist, true)
+	}
+	if err != nil {
+		m.loadError = err.Error()
+		return sound.Emit(services.UIError)
+	}
+	return sound.Emit(services.UISelect)
+}
+
+// isPlaylistFile reports whether a file is in a supported playlist format
+func isPlaylistFile(path string) bool {
+	return slices.Contains(services.PlaylistFormats, strings.ToLower(filepath.Ext(path)))
+}
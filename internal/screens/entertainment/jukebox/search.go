@@ -0,0 +1,194 @@
+package jukebox
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sahilm/fuzzy"
+	"github.com/thornzero/barkeep/internal/services"
+	"github.com/thornzero/barkeep/internal/sound"
+)
+
+// searchResultLimit caps how many matches the overlay lists
+const searchResultLimit = 50
+
+// searchSource adapts catalog tracks to fuzzy matching on title, artist and
+// album
+type searchSource []services.LibraryTrack
+
+func (s searchSource) String(i int) string {
+	track := s[i]
+	if track.Artist == "" && track.Album == "" {
+		// Untagged files are found by their Artist/Album folders instead
+		artist, album := services.GroupByDirectory(track.Path)
+		return track.DisplayTitle() + " " + artist + " " + filepath.Base(album)
+	}
+	return track.DisplayTitle() + " " + track.DisplayArtist() + " " + track.Album
+}
+
+func (s searchSource) Len() int {
+	return len(s)
+}
+
+// searchOverlay finds catalog tracks by fuzzy matching as the query is typed
+type searchOverlay struct {
+	input   textinput.Model
+	tracks  searchSource
+	results []services.LibraryTrack
+	cursor  int
+	status  string
+}
+
+// newSearchOverlay creates a closed overlay
+func newSearchOverlay() searchOverlay {
+	input := textinput.New()
+	input.Placeholder = "title, artist or album"
+	input.Prompt = "🔍 "
+	input.CharLimit = 100
+	return searchOverlay{input: input}
+}
+
+// Open starts a new search over the catalog
+func (s *searchOverlay) Open(tracks []services.LibraryTrack) tea.Cmd {
+	s.tracks = tracks
+	s.input.SetValue("")
+	s.status = ""
+	s.search()
+	return s.input.Focus()
+}
+
+// Close ends the search
+func (s *searchOverlay) Close() {
+	s.input.Blur()
+	s.tracks = nil
+	s.results = nil
+}
+
+// Active reports whether the overlay is open
+func (s *searchOverlay) Active() bool {
+	return s.input.Focused()
+}
+
+// Selected returns the highlighted result
+func (s *searchOverlay) Selected() (services.LibraryTrack, bool) {
+	if s.cursor >= len(s.results) {
+		return services.LibraryTrack{}, false
+	}
+	return s.results[s.cursor], true
+}
+
+// Update edits the query and moves through the results
+func (s *searchOverlay) Update(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "up":
+		if s.cursor > 0 {
+			s.cursor--
+		}
+		return nil
+	case "down":
+		if s.cursor < len(s.results)-1 {
+			s.cursor++
+		}
+		return nil
+	}
+
+	query := s.input.Value()
+	var cmd tea.Cmd
+	s.input, cmd = s.input.Update(msg)
+	if s.input.Value() != query {
+		s.search()
+	}
+	return cmd
+}
+
+// search ranks the catalog against the query; an empty query lists the
+// catalog in order
+func (s *searchOverlay) search() {
+	s.cursor = 0
+	s.results = s.results[:0]
+
+	query := strings.TrimSpace(s.input.Value())
+	if query == "" {
+		s.results = append(s.results, s.tracks[:min(len(s.tracks), searchResultLimit)]...)
+		return
+	}
+
+	for _, match := range fuzzy.FindFrom(query, s.tracks) {
+		s.results = append(s.results, s.tracks[match.Index])
+		if len(s.results) == searchResultLimit {
+			break
+		}
+	}
+}
+
+// openSearch shows the search overlay
+func (m *Model) openSearch() tea.Cmd {
+	if m.library == nil {
+		return nil
+	}
+	return tea.Batch(m.search.Open(m.library.Tracks()), sound.Emit(services.UISelect))
+}
+
+// handleSearchKey handles keys while the search overlay is open
+func (m *Model) handleSearchKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.search.Close()
+		return sound.Emit(services.UIBack)
+
+	case "enter", "ctrl+n", "tab":
+		track, ok := m.search.Selected()
+		if !ok || m.audioManager == nil {
+			return nil
+		}
+
+		switch msg.String() {
+		case "enter":
+			if err := m.audioManager.PlayNow(track.Path); err != nil {
+				m.search.status = fmt.Sprintf("Cannot play %s: %v", track.DisplayTitle(), err)
+				return sound.Emit(services.UIError)
+			}
+			m.loadError = ""
+			m.search.Close()
+			m.syncPlaylist()
+			return sound.Emit(services.UISelect)
+		case "ctrl+n":
+			m.audioManager.PlayNext([]string{track.Path})
+			m.search.status = "Playing next: " + trackLabel(track)
+		case "tab":
+			m.audioManager.AddToPlaylist([]string{track.Path})
+			m.search.status = "Queued: " + trackLabel(track)
+		}
+		m.syncPlaylist()
+		return sound.Emit(services.UISuccess)
+	}
+
+	return m.search.Update(msg)
+}
+
+// Capturing reports whether the jukebox is taking text input or showing an
+// overlay, so global keys should be passed through to it
+func (m *Model) Capturing() bool {
+	return m.search.Active() || m.manager.open
+}
+
+// syncPlaylist rebuilds the queue pane from the audio manager's playlist,
+// keeping the cursor where it was
+func (m *Model) syncPlaylist() {
+	if m.audioManager == nil {
+		return
+	}
+
+	cursor := m.playlist.Index()
+	paths := m.audioManager.GetPlaylist()
+	items := make([]list.Item, 0, len(paths))
+	for i, path := range paths {
+		items = append(items, m.playlistItem(path, filepath.Base(path), i))
+	}
+	m.playlist.SetItems(items)
+	m.playlist.Select(min(cursor, max(len(items)-1, 0)))
+}
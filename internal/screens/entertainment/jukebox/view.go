@@ -1,8 +1,10 @@
 package jukebox
 
 import (
-	"fmt"
 	"path/filepath"
+	"strings"
+
+	"github.com/thornzero/barkeep/internal/services"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -13,6 +15,13 @@ func (m *Model) View() string {
 		return "Loading jukebox..."
 	}
 
+	if m.search.Active() {
+		return m.renderSearch()
+	}
+	if m.manager.open {
+		return m.renderManager()
+	}
+
 	// Calculate layout
 	listWidth := m.width / 3
 	controlsWidth := m.width - (listWidth * 2) - 4
@@ -49,8 +58,8 @@ func (m *Model) renderDirectoryPane(width int) string {
 		style = style.BorderForeground(theme.Bases.Primary)
 	}
 
-	title := styles.SubHeadingStyle.Render("📁 Music Directory")
-	currentPath := styles.BodyStyle.Render("Path: " + m.currentDir)
+	title := styles.SubHeadingStyle.Render(m.browseMode.Icon() + " Music " + m.browseMode.String())
+	currentPath := styles.BodyStyle.Render(m.browseLocation())
 
 	content := lipgloss.JoinVertical(
 		lipgloss.Left,
@@ -80,6 +89,9 @@ func (m *Model) renderPlaylistPane(width int) string {
 		title,
 		m.playlist.View(),
 	)
+	if m.queueStatus != "" {
+		content = lipgloss.JoinVertical(lipgloss.Left, content, styles.BodyStyle.Render(m.queueStatus))
+	}
 
 	return style.Render(content)
 }
@@ -106,16 +118,34 @@ func (m *Model) renderControlsPane(width int) string {
 	}
 
 	status := styles.BodyStyle.Render(m.playbackStatus)
-	volumeDisplay := styles.BodyStyle.Render(fmt.Sprintf("Volume: %.0f%%", m.volume*100))
+	if m.nowPlayingTrack != "" {
+		status += "\n" + styles.BodyStyle.Render(m.renderProgress(width-4))
+	}
+	if m.loadError != "" {
+		status += "\n" + styles.ErrorStyle.Render(m.loadError)
+	}
+	volumeDisplay := styles.BodyStyle.Render("Volume: " + services.FormatLevel(m.volume, m.muted))
+	shuffleDisplay := styles.BodyStyle.Render("Shuffle: " + m.shuffle.String())
+	if m.shuffle != services.ShuffleOff {
+		shuffleDisplay = styles.BodyStyle.Render("🔀 Shuffle: " + m.shuffle.String())
+	}
 
 	// Controls help
 	controls := styles.BodyStyle.Render(
 		"Controls:\n" +
 			"Space: Play/Pause\n" +
 			"n: Next  p: Previous\n" +
+			"[/]: -10s/+10s\n" +
 			"+/-: Volume\n" +
+			"s: Shuffle (off/random/smart)\n" +
 			"a: Add to playlist\n" +
+			"b: Browse folders/artists/albums/genres\n" +
+			"/: Search the library\n" +
+			"m: Saved playlists\n" +
 			"d: Remove from playlist\n" +
+			"K/J: Move up/down  N: Play next\n" +
+			"A: After album  c: Clear\n" +
+			"D: Dedupe  u: Undo\n" +
 			"Tab: Switch panes\n" +
 			"h: Toggle help",
 	)
@@ -126,6 +156,7 @@ func (m *Model) renderControlsPane(width int) string {
 		nowPlaying,
 		status,
 		volumeDisplay,
+		shuffleDisplay,
 		"",
 		controls,
 	)
@@ -133,6 +164,110 @@ func (m *Model) renderControlsPane(width int) string {
 	return style.Render(content)
 }
 
+// renderSearch renders the search overlay in place of the panes
+func (m *Model) renderSearch() string {
+	styles := m.themeProvider.GetStyles()
+	theme := m.themeProvider.GetTheme()
+
+	lines := []string{
+		styles.SubHeadingStyle.Render("🔍 Search Library"),
+		m.search.input.View(),
+		"",
+	}
+
+	// Leave room for the title, input, status and hints
+	rows := max(m.height-10, 3)
+	results := m.search.results
+	start := max(0, min(m.search.cursor-rows/2, len(results)-rows))
+	for i := start; i < min(start+rows, len(results)); i++ {
+		track := results[i]
+		text := trackLabel(track)
+		if track.Album != "" {
+			text += " · " + track.Album
+		}
+		if track.Duration > 0 {
+			text += " (" + formatDuration(track.Duration) + ")"
+		}
+
+		style := styles.ListItemStyle
+		if i == m.search.cursor {
+			style = styles.ListItemSelectedStyle
+		}
+		lines = append(lines, style.Render(text))
+	}
+	if len(results) == 0 {
+		lines = append(lines, styles.BodyStyle.Render("No matches"))
+	}
+
+	lines = append(lines, "")
+	if m.search.status != "" {
+		lines = append(lines, styles.BodyStyle.Render(m.search.status))
+	}
+	lines = append(lines, styles.BodyStyle.Render("↑/↓: Select · Enter: Play now · Ctrl+N: Play next · Tab: Queue · Esc: Close"))
+
+	style := styles.CardStyle.Width(m.width).BorderForeground(theme.Bases.Primary)
+	return style.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// renderManager renders the playlist manager in place of the panes
+func (m *Model) renderManager() string {
+	styles := m.themeProvider.GetStyles()
+	theme := m.themeProvider.GetTheme()
+
+	lines := []string{
+		styles.SubHeadingStyle.Render("📜 Saved Playlists"),
+		styles.BodyStyle.Render("In " + m.playlists.Directory()),
+		"",
+	}
+
+	// Leave room for the title, prompt, status and hints
+	rows := max(m.height-12, 3)
+	names := m.manager.names
+	start := max(0, min(m.manager.cursor-rows/2, len(names)-rows))
+	for i := start; i < min(start+rows, len(names)); i++ {
+		style := styles.ListItemStyle
+		if i == m.manager.cursor {
+			style = styles.ListItemSelectedStyle
+		}
+		lines = append(lines, style.Render(names[i]))
+	}
+	if len(names) == 0 {
+		lines = append(lines, styles.BodyStyle.Render("No saved playlists"))
+	}
+
+	lines = append(lines, "")
+	if m.manager.prompt != promptNone {
+		lines = append(lines, m.manager.input.View())
+	}
+	if m.manager.status != "" {
+		lines = append(lines, styles.BodyStyle.Render(m.manager.status))
+	}
+	if m.manager.prompt != promptNone {
+		lines = append(lines, styles.BodyStyle.Render("Enter: Confirm · Esc: Cancel"))
+	} else {
+		lines = append(lines, styles.BodyStyle.Render(
+			"Enter: Play · a: Append to queue · s: Save queue · d: Delete · i: Import · e: Export · Esc: Close"))
+	}
+
+	style := styles.CardStyle.Width(m.width).BorderForeground(theme.Bases.Primary)
+	return style.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// renderProgress renders a progress bar with the elapsed and remaining time
+func (m *Model) renderProgress(width int) string {
+	elapsed := formatDuration(m.position)
+	remaining := "-" + formatDuration(max(m.duration-m.position, 0))
+
+	barWidth := max(width-len(elapsed)-len(remaining)-2, 1)
+	filled := 0
+	if m.duration > 0 {
+		filled = min(int(int64(barWidth)*int64(m.position)/int64(m.duration)), barWidth)
+	}
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
+
+	return elapsed + " " + bar + " " + remaining
+}
+
 // renderHelp renders the help information
 func (m *Model) renderHelp() string {
 	styles := m.themeProvider.GetStyles()
@@ -143,13 +278,22 @@ func (m *Model) renderHelp() string {
 				"Navigation:\n"+
 					"• Tab: Switch between panes (Directory → Playlist → Controls)\n"+
 					"• Enter: Select item / Enter directory\n"+
-					"• a: Add current file to playlist\n"+
-					"• d: Remove selected item from playlist\n\n"+
+					"• a: Add current file, album, artist or genre to playlist\n"+
+					"• b: Browse by folder, artist, album or genre\n"+
+					"• /: Search titles, artists and albums\n"+
+					"• m: Save, load, import and export playlists\n"+
+					"• d: Remove selected item from playlist\n"+
+					"• K/J or Shift+↑/↓: Move the selected queue entry up/down\n"+
+					"• N: Play the selected entry next · A: Play it after the current album\n"+
+					"• c: Clear the queue · D: Remove duplicates · u: Undo the last queue change\n\n"+
 					"Playback:\n"+
 					"• Space: Play/Pause\n"+
 					"• n: Next track\n"+
 					"• p: Previous track\n"+
-					"• +/-: Volume up/down\n\n"+
+					"• [/]: Back/forward 10 seconds (←/→ in the controls pane)\n"+
+					"• +/-: Volume up/down\n"+
+					"• s: Cycle shuffle off → random → smart (smart keeps artists and albums\n"+
+					"  apart and holds back recently played tracks)\n\n"+
 					"• h/?: Toggle this help\n"+
 					"• q: Quit application",
 			),
@@ -46,6 +46,26 @@ func (m *Model) SetSize(width, height int) {
 	m.jukebox.SetSize(jukeboxWidth, jukeboxHeight)
 }
 
+// SetMusicDirectory sets the root directory of the jukebox browser
+func (m *Model) SetMusicDirectory(dir string) {
+	m.jukebox.SetMusicDirectory(dir)
+}
+
+// SetLibrary sets the music library the jukebox browses
+func (m *Model) SetLibrary(library services.LibraryServiceInterface) {
+	m.jukebox.SetLibrary(library)
+}
+
+// SetPlaylistStore sets where the jukebox saves named playlists
+func (m *Model) SetPlaylistStore(store *services.PlaylistStore) {
+	m.jukebox.SetPlaylistStore(store)
+}
+
+// Capturing reports whether the jukebox is taking text input
+func (m *Model) Capturing() bool {
+	return m.jukebox.Capturing()
+}
+
 // Init initializes the entertainment screen
 func (m *Model) Init() tea.Cmd {
 	return m.jukebox.Init()
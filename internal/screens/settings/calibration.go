@@ -0,0 +1,329 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thornzero/barkeep/internal/config"
+	"github.com/thornzero/barkeep/internal/services"
+	"github.com/thornzero/barkeep/internal/theme"
+)
+
+const (
+	// calibrationTick is the interval between live readings
+	calibrationTick = 50 * time.Millisecond
+	// calibrationSample is how long each button state is sampled
+	calibrationSample = 3 * time.Second
+	// calibrationCountdown gives the user time to hold a button down
+	calibrationCountdown = 3 * time.Second
+)
+
+// calibrationPhase is a step of the calibration wizard
+type calibrationPhase int
+
+const (
+	phaseStart calibrationPhase = iota
+	phaseIdle
+	phaseCountdown
+	phasePressed
+	phaseResult
+	phaseSaved
+	phaseFailed
+)
+
+// calibrationTickMsg drives the wizard; ticks from an earlier run are ignored
+type calibrationTickMsg struct {
+	run int
+}
+
+// ButtonSuspender stops physical buttons from triggering actions, so they
+// can be pressed for calibration without navigating or seeking
+type ButtonSuspender interface {
+	SetSuspended(suspended bool)
+}
+
+// CalibrationPanel is a wizard that measures the analog Up/Down buttons and
+// saves their pressed windows to the configuration
+type CalibrationPanel struct {
+	megaInd   services.MegaInd
+	simulator *services.SimulatedMegaInd
+	buttons   ButtonSuspender
+	cfg       *config.Config
+
+	run        int
+	phase      calibrationPhase
+	phaseStart time.Time
+	// button indexes services.AnalogButtons during the countdown and pressed phases
+	button     int
+	readings   [2]uint16
+	idle       [2]services.AnalogSamples
+	pressed    [2]services.AnalogSamples
+	thresholds [2]services.AnalogThreshold
+	err        error
+
+	themeProvider theme.Provider
+}
+
+// NewCalibrationPanel creates the wizard; megaInd may be nil, in which case
+// the panel explains that no hardware is available. With a simulator, the
+// buttons are pressed on the user's behalf. buttons, which may be nil, is
+// suspended while the wizard samples.
+func NewCalibrationPanel(megaInd services.MegaInd, simulator *services.SimulatedMegaInd, buttons ButtonSuspender, cfg *config.Config, themeProvider theme.Provider) *CalibrationPanel {
+	return &CalibrationPanel{
+		megaInd:       megaInd,
+		simulator:     simulator,
+		buttons:       buttons,
+		cfg:           cfg,
+		themeProvider: themeProvider,
+	}
+}
+
+func (p *CalibrationPanel) Title() string {
+	return "Button Calibration"
+}
+
+func (p *CalibrationPanel) Description() string {
+	return "Measure the analog Up/Down buttons and save their thresholds"
+}
+
+// Open resets the wizard to its first step
+func (p *CalibrationPanel) Open() tea.Cmd {
+	p.run++
+	p.reset()
+	if p.megaInd == nil {
+		return nil
+	}
+	return p.tick()
+}
+
+// Close stops the wizard, releases any simulated button and gives the
+// buttons back to the UI
+func (p *CalibrationPanel) Close() {
+	p.run++
+	p.releaseSimulated()
+	if p.buttons != nil {
+		p.buttons.SetSuspended(false)
+	}
+}
+
+// reset clears the samples and returns to the first step
+func (p *CalibrationPanel) reset() {
+	p.releaseSimulated()
+	p.phase = phaseStart
+	p.button = 0
+	p.idle = [2]services.AnalogSamples{}
+	p.pressed = [2]services.AnalogSamples{}
+	p.err = nil
+}
+
+// tick schedules the next reading
+func (p *CalibrationPanel) tick() tea.Cmd {
+	run := p.run
+	return tea.Tick(calibrationTick, func(time.Time) tea.Msg {
+		return calibrationTickMsg{run: run}
+	})
+}
+
+// Update advances the wizard on key presses and ticks
+func (p *CalibrationPanel) Update(msg tea.Msg) tea.Cmd {
+	if p.megaInd == nil {
+		return nil
+	}
+	defer p.suspendButtons()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "enter":
+			switch p.phase {
+			case phaseStart:
+				p.enter(phaseIdle, time.Now())
+			case phaseResult:
+				p.save()
+			case phaseSaved, phaseFailed:
+				p.reset()
+			}
+		case "r":
+			p.reset()
+		}
+
+	case calibrationTickMsg:
+		if msg.run != p.run {
+			return nil
+		}
+		p.step(time.Now())
+		return p.tick()
+	}
+
+	return nil
+}
+
+// enter switches to a phase
+func (p *CalibrationPanel) enter(phase calibrationPhase, now time.Time) {
+	p.phase = phase
+	p.phaseStart = now
+
+	if phase == phaseCountdown && p.simulator != nil {
+		p.simulator.PressButton(services.AnalogButtons[p.button])
+	}
+}
+
+// step takes a reading and moves on when the current phase has run its time
+func (p *CalibrationPanel) step(now time.Time) {
+	for i, button := range services.AnalogButtons {
+		channel, _ := services.AnalogButtonChannel(button)
+		value, err := p.megaInd.RawAnalogInput(channel)
+		if err != nil {
+			p.fail(err)
+			return
+		}
+		p.readings[i] = value
+	}
+
+	elapsed := now.Sub(p.phaseStart)
+	switch p.phase {
+	case phaseIdle:
+		for i := range p.idle {
+			p.idle[i].Add(float64(p.readings[i]))
+		}
+		if elapsed >= calibrationSample {
+			p.enter(phaseCountdown, now)
+		}
+
+	case phaseCountdown:
+		if elapsed >= calibrationCountdown {
+			p.enter(phasePressed, now)
+		}
+
+	case phasePressed:
+		p.pressed[p.button].Add(float64(p.readings[p.button]))
+		if elapsed < calibrationSample {
+			return
+		}
+
+		p.releaseSimulated()
+		threshold, err := services.CalibrateAnalogThreshold(p.idle[p.button], p.pressed[p.button])
+		if err != nil {
+			p.fail(fmt.Errorf("%s: %w", services.AnalogButtons[p.button], err))
+			return
+		}
+		p.thresholds[p.button] = threshold
+
+		p.button++
+		if p.button < len(services.AnalogButtons) {
+			p.enter(phaseCountdown, now)
+		} else {
+			p.enter(phaseResult, now)
+		}
+	}
+}
+
+// sampling reports whether the wizard is reading the buttons
+func (p *CalibrationPanel) sampling() bool {
+	switch p.phase {
+	case phaseIdle, phaseCountdown, phasePressed:
+		return true
+	}
+	return false
+}
+
+// suspendButtons keeps button presses from triggering actions while the
+// wizard samples, since the user is pressing them to be measured
+func (p *CalibrationPanel) suspendButtons() {
+	if p.buttons != nil {
+		p.buttons.SetSuspended(p.sampling())
+	}
+}
+
+// fail stops the wizard with an error
+func (p *CalibrationPanel) fail(err error) {
+	p.releaseSimulated()
+	p.err = err
+	p.phase = phaseFailed
+}
+
+// releaseSimulated releases the simulated button held during sampling
+func (p *CalibrationPanel) releaseSimulated() {
+	if p.simulator == nil {
+		return
+	}
+	for _, button := range services.AnalogButtons {
+		p.simulator.ReleaseButton(button)
+	}
+}
+
+// save applies the thresholds to the controller and merges them into the
+// configuration file
+func (p *CalibrationPanel) save() {
+	thresholds := make(map[string]config.AnalogThresholdConfig)
+	for i, button := range services.AnalogButtons {
+		channel, _ := services.AnalogButtonChannel(button)
+		if err := p.megaInd.SetAnalogThreshold(channel, p.thresholds[i]); err != nil {
+			p.fail(err)
+			return
+		}
+		thresholds[button.String()] = config.AnalogThresholdConfig(p.thresholds[i])
+	}
+
+	if err := config.SaveAnalogThresholds(p.cfg.Path(), thresholds); err != nil {
+		p.fail(err)
+		return
+	}
+
+	if p.cfg.Hardware.AnalogThresholds == nil {
+		p.cfg.Hardware.AnalogThresholds = make(map[string]config.AnalogThresholdConfig)
+	}
+	for name, threshold := range thresholds {
+		p.cfg.Hardware.AnalogThresholds[name] = threshold
+	}
+	p.phase = phaseSaved
+}
+
+// View renders the current step and the live readings
+func (p *CalibrationPanel) View(width int) string {
+	styles := p.themeProvider.GetStyles()
+
+	if p.megaInd == nil {
+		return styles.BodyStyle.Render("No MegaInd hardware is available to calibrate.")
+	}
+
+	var b strings.Builder
+	b.WriteString(styles.SubHeadingStyle.Render("Live readings") + "\n")
+	for i, button := range services.AnalogButtons {
+		channel, _ := services.AnalogButtonChannel(button)
+		threshold, _ := p.megaInd.AnalogThreshold(channel)
+		fmt.Fprintf(&b, "%-5s %5d mV   pressed between %.2f and %.2f\n", button, p.readings[i], threshold.Lower, threshold.Upper)
+	}
+	b.WriteString("\n")
+
+	var step string
+	remaining := func(total time.Duration) int {
+		return int((total - time.Since(p.phaseStart) + time.Second - 1) / time.Second)
+	}
+	switch p.phase {
+	case phaseStart:
+		step = "Release the Up and Down buttons, then press Enter to begin."
+	case phaseIdle:
+		step = fmt.Sprintf("Measuring released buttons... keep hands off (%ds)", remaining(calibrationSample))
+	case phaseCountdown:
+		step = fmt.Sprintf("Press and hold %s (%ds)", services.AnalogButtons[p.button], remaining(calibrationCountdown))
+	case phasePressed:
+		step = fmt.Sprintf("Measuring %s... keep holding (%ds)", services.AnalogButtons[p.button], remaining(calibrationSample))
+	case phaseResult:
+		for i, button := range services.AnalogButtons {
+			t := p.thresholds[i]
+			fmt.Fprintf(&b, "%-5s idle %s\n      held %s\n      pressed between %.2f and %.2f (hysteresis %.2f)\n",
+				button, p.idle[i], p.pressed[i], t.Lower, t.Upper, t.Hysteresis)
+		}
+		step = "Enter: save and apply · r: start over"
+	case phaseSaved:
+		step = "Thresholds applied and saved to " + p.cfg.Path() + ". Press Enter to calibrate again."
+	case phaseFailed:
+		return b.String() + styles.ErrorStyle.Render("Calibration failed: "+p.err.Error()) + "\n\n" +
+			styles.BodyStyle.Render("Enter: try again")
+	}
+
+	return b.String() + styles.BodyStyle.Render(services.Txt.WrapText(step, width))
+}
@@ -0,0 +1,213 @@
+package settings
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thornzero/barkeep/internal/services"
+	"github.com/thornzero/barkeep/internal/theme"
+)
+
+// highPassSteps are the high-pass frequencies the panel steps through
+var highPassSteps = []float64{0, 40, 60, 80, 100, 120, 150, 200}
+
+// responseWidth is how many points of the frequency response are drawn
+const responseWidth = 40
+
+// EqualizerPanel edits the music bus effects: EQ bands and presets, bass
+// and treble, the high-pass filter, mono output and the limiter. Changes
+// apply at once and are saved by the audio manager.
+type EqualizerPanel struct {
+	audio    services.AudioServiceInterface
+	selected int
+	err      error
+
+	themeProvider theme.Provider
+}
+
+// NewEqualizerPanel creates the panel; audio may be nil, in which case the
+// panel explains that there is no audio output
+func NewEqualizerPanel(audio services.AudioServiceInterface, themeProvider theme.Provider) *EqualizerPanel {
+	return &EqualizerPanel{
+		audio:         audio,
+		themeProvider: themeProvider,
+	}
+}
+
+func (p *EqualizerPanel) Title() string {
+	return "Equalizer"
+}
+
+func (p *EqualizerPanel) Description() string {
+	return "Shape the music for the speakers with EQ, filters and the limiter"
+}
+
+// Open clears any earlier error
+func (p *EqualizerPanel) Open() tea.Cmd {
+	p.err = nil
+	return nil
+}
+
+// Close does nothing; settings are saved as they change
+func (p *EqualizerPanel) Close() {}
+
+// rows returns the labels of the panel's lines for the current settings
+func (p *EqualizerPanel) rows(effects services.Effects) []string {
+	rows := []string{"Preset"}
+	for _, band := range effects.Bands {
+		rows = append(rows, formatFrequency(band.Frequency))
+	}
+	return append(rows, "Bass", "Treble", "High-pass", "Output", "Limiter")
+}
+
+// Update moves between lines and adjusts the selected one
+func (p *EqualizerPanel) Update(msg tea.Msg) tea.Cmd {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok || p.audio == nil {
+		return nil
+	}
+
+	effects := p.audio.GetEffects()
+	rows := p.rows(effects)
+	direction := 0
+	switch key.String() {
+	case "up", "k":
+		p.selected = max(p.selected-1, 0)
+		return nil
+	case "down", "j":
+		p.selected = min(p.selected+1, len(rows)-1)
+		return nil
+	case "left", "h", "-":
+		direction = -1
+	case "right", "l", "+", "=", "enter", " ":
+		direction = 1
+	default:
+		return nil
+	}
+
+	p.err = p.adjust(&effects, direction)
+	if p.err == nil {
+		p.err = p.audio.SetEffects(effects)
+	}
+	return nil
+}
+
+// adjust changes the selected setting one step in direction. The preset
+// comes first, then a line per band and then the other settings.
+func (p *EqualizerPanel) adjust(effects *services.Effects, direction int) error {
+	bands := len(effects.Bands)
+	step := float64(direction)
+	switch row := p.selected; {
+	case row == 0:
+		index := slices.IndexFunc(services.EQPresets, func(preset services.EQPreset) bool {
+			return preset.Name == effects.Preset
+		})
+		count := len(services.EQPresets)
+		return effects.ApplyPreset(services.EQPresets[((index+direction)%count+count)%count].Name)
+
+	case row <= bands:
+		band := &effects.Bands[row-1]
+		band.Gain = clampGain(band.Gain + step)
+		effects.Preset = "custom"
+
+	default:
+		switch row - bands - 1 {
+		case 0:
+			effects.Bass = clampGain(effects.Bass + step)
+		case 1:
+			effects.Treble = clampGain(effects.Treble + step)
+		case 2:
+			index, _ := slices.BinarySearch(highPassSteps, effects.HighPass)
+			index = min(max(index+direction, 0), len(highPassSteps)-1)
+			effects.HighPass = highPassSteps[index]
+		case 3:
+			effects.Mono = !effects.Mono
+		case 4:
+			effects.Limiter = !effects.Limiter
+		}
+	}
+	return nil
+}
+
+// clampGain holds a gain within the equalizer's range
+func clampGain(gain float64) float64 {
+	return min(max(gain, -services.EQGainLimit), services.EQGainLimit)
+}
+
+// View renders the settings and the resulting frequency response
+func (p *EqualizerPanel) View(width int) string {
+	styles := p.themeProvider.GetStyles()
+
+	if p.audio == nil {
+		return styles.BodyStyle.Render("No audio output is available.")
+	}
+
+	effects := p.audio.GetEffects()
+	values := []string{effects.Preset}
+	for _, band := range effects.Bands {
+		values = append(values, formatGain(band.Gain))
+	}
+	highPass := "off"
+	if effects.HighPass > 0 {
+		highPass = formatFrequency(effects.HighPass)
+	}
+	output := "stereo"
+	if effects.Mono {
+		output = "mono"
+	}
+	limiter := "off"
+	if effects.Limiter {
+		limiter = "on"
+	}
+	values = append(values, formatGain(effects.Bass), formatGain(effects.Treble), highPass, output, limiter)
+
+	var b strings.Builder
+	for i, label := range p.rows(effects) {
+		style := styles.ListItemStyle
+		if i == p.selected {
+			style = styles.ListItemSelectedStyle
+		}
+		b.WriteString(style.Render(fmt.Sprintf("%-10s %s", label, values[i])) + "\n")
+	}
+
+	b.WriteString("\n" + styles.SubHeadingStyle.Render("Response, 20 Hz to 20 kHz") + "\n")
+	b.WriteString(styles.BodyStyle.Render(responseCurve(effects)) + "\n")
+
+	if p.err != nil {
+		b.WriteString("\n" + styles.ErrorStyle.Render(p.err.Error()) + "\n")
+	}
+
+	help := "↑/↓: setting · ←/→: adjust · enter: toggle"
+	return b.String() + "\n" + styles.StatusStyle.Render(services.Txt.WrapText(help, width))
+}
+
+// responseCurve draws the frequency response as a row of bars, from a cut
+// of EQGainLimit at the bottom to a boost of EQGainLimit at the top
+func responseCurve(effects services.Effects) string {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	var curve strings.Builder
+	for i := range responseWidth {
+		frequency := 20 * math.Pow(1000, float64(i)/(responseWidth-1))
+		gain := effects.Response(frequency)
+		position := (gain + services.EQGainLimit) / (2 * services.EQGainLimit)
+		index := int(math.Round(position * float64(len(levels)-1)))
+		curve.WriteRune(levels[min(max(index, 0), len(levels)-1)])
+	}
+	return curve.String()
+}
+
+// formatGain formats a gain in dB with its sign
+func formatGain(gain float64) string {
+	return fmt.Sprintf("%+.0f dB", gain)
+}
+
+// formatFrequency formats a frequency as Hz or kHz
+func formatFrequency(frequency float64) string {
+	if frequency >= 1000 {
+		return fmt.Sprintf("%g kHz", frequency/1000)
+	}
+	return fmt.Sprintf("%g Hz", frequency)
+}
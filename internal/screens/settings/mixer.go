@@ -0,0 +1,144 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thornzero/barkeep/internal/services"
+	"github.com/thornzero/barkeep/internal/theme"
+)
+
+// mixerFaderWidth is the width of a fader bar in cells
+const mixerFaderWidth = 24
+
+// busTitles names the mixer buses for display
+var busTitles = map[services.Bus]string{
+	services.BusMaster:       "Master",
+	services.BusMusic:        "Music",
+	services.BusSFX:          "Effects",
+	services.BusAnnouncement: "Announcements",
+}
+
+// MixerPanel shows and adjusts the level, mute and limits of each audio bus.
+// Changes apply at once and are saved by the audio manager.
+type MixerPanel struct {
+	audio    services.AudioServiceInterface
+	selected int
+	err      error
+
+	themeProvider theme.Provider
+}
+
+// NewMixerPanel creates the mixer; audio may be nil, in which case the panel
+// explains that there is no audio output
+func NewMixerPanel(audio services.AudioServiceInterface, themeProvider theme.Provider) *MixerPanel {
+	return &MixerPanel{
+		audio:         audio,
+		themeProvider: themeProvider,
+	}
+}
+
+func (p *MixerPanel) Title() string {
+	return "Audio Mixer"
+}
+
+func (p *MixerPanel) Description() string {
+	return "Set the master, music, effects and announcement levels"
+}
+
+// Open clears any earlier error
+func (p *MixerPanel) Open() tea.Cmd {
+	p.err = nil
+	return nil
+}
+
+// Close does nothing; levels are saved as they change
+func (p *MixerPanel) Close() {}
+
+// Update moves between buses and adjusts the selected one
+func (p *MixerPanel) Update(msg tea.Msg) tea.Cmd {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok || p.audio == nil {
+		return nil
+	}
+
+	bus := services.Buses[p.selected]
+	level := p.audio.GetMixer()[bus]
+	var err error
+	switch key.String() {
+	case "up", "k":
+		p.selected = max(p.selected-1, 0)
+	case "down", "j":
+		p.selected = min(p.selected+1, len(services.Buses)-1)
+	case "left", "h", "-":
+		err = p.audio.SetBusLevel(bus, level.Level-services.FaderStep)
+	case "right", "l", "+", "=":
+		err = p.audio.SetBusLevel(bus, level.Level+services.FaderStep)
+	case "m", " ":
+		err = p.audio.SetBusMuted(bus, !level.Muted)
+	case "[":
+		err = p.audio.SetBusLimits(bus, level.Min, max(level.Max-services.FaderStep, level.Min))
+	case "]":
+		err = p.audio.SetBusLimits(bus, level.Min, min(level.Max+services.FaderStep, 1))
+	case "{":
+		err = p.audio.SetBusLimits(bus, max(level.Min-services.FaderStep, 0), level.Max)
+	case "}":
+		err = p.audio.SetBusLimits(bus, min(level.Min+services.FaderStep, level.Max), level.Max)
+	}
+	p.err = err
+	return nil
+}
+
+// View renders a fader per bus with its level and limits
+func (p *MixerPanel) View(width int) string {
+	styles := p.themeProvider.GetStyles()
+
+	if p.audio == nil {
+		return styles.BodyStyle.Render("No audio output is available.")
+	}
+
+	mixer := p.audio.GetMixer()
+	var b strings.Builder
+	for i, bus := range services.Buses {
+		level := mixer[bus]
+		style := styles.ListItemStyle
+		if i == p.selected {
+			style = styles.ListItemSelectedStyle
+		}
+		line := fmt.Sprintf("%-13s %s %7s", busTitles[bus], faderBar(level), services.FormatLevel(level.Level, level.Muted))
+		b.WriteString(style.Render(line) + "\n")
+		limits := fmt.Sprintf("  limits %s to %s", services.FormatLevel(level.Min, false), services.FormatLevel(level.Max, false))
+		b.WriteString(styles.BodyStyle.Render(limits) + "\n")
+	}
+
+	if p.err != nil {
+		b.WriteString("\n" + styles.ErrorStyle.Render(p.err.Error()) + "\n")
+	}
+
+	help := "↑/↓: bus · ←/→: level · m: mute · [/]: lower/raise the maximum · {/}: lower/raise the minimum"
+	return b.String() + "\n" + styles.StatusStyle.Render(services.Txt.WrapText(help, width))
+}
+
+// faderBar draws a fader position, marking the limits outside which it
+// cannot move
+func faderBar(level services.BusLevel) string {
+	filled := int(level.Level*mixerFaderWidth + 0.5)
+	low := int(level.Min*mixerFaderWidth + 0.5)
+	high := int(level.Max*mixerFaderWidth + 0.5)
+
+	var bar strings.Builder
+	bar.WriteString("[")
+	for i := range mixerFaderWidth {
+		switch {
+		case i < filled && !level.Muted:
+			bar.WriteString("█")
+		case i < low || i >= high:
+			bar.WriteString("·")
+		default:
+			bar.WriteString("░")
+		}
+	}
+	bar.WriteString("]")
+	return bar.String()
+}
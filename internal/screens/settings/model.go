@@ -1,11 +1,28 @@
 package settings
 
 import (
+	"strings"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/thornzero/barkeep/internal/services"
+	"github.com/thornzero/barkeep/internal/sound"
 	"github.com/thornzero/barkeep/internal/theme"
 )
 
+// Panel is a page of the settings screen opened from its menu
+type Panel interface {
+	// Title names the panel in the menu
+	Title() string
+	// Description is shown under the title in the menu
+	Description() string
+	// Open is called when the panel is opened
+	Open() tea.Cmd
+	// Close is called when the panel is left with esc
+	Close()
+	Update(msg tea.Msg) tea.Cmd
+	View(width int) string
+}
+
 // Model represents the settings screen
 type Model struct {
 	// Configuration
@@ -13,7 +30,10 @@ type Model struct {
 	height int
 
 	// Content
-	content string
+	content  string
+	panels   []Panel
+	selected int
+	open     Panel
 
 	// Dependencies
 	themeProvider theme.Provider
@@ -21,13 +41,9 @@ type Model struct {
 
 // NewModel creates a new settings screen model
 func NewModel(themeProvider theme.Provider) *Model {
-	content := "System Settings\n\n" +
-		"This screen will handle:\n" +
-		"• Audio configuration\n" +
-		"• Hardware settings\n" +
+	content := "Still to come:\n" +
 		"• User management\n" +
-		"• System preferences\n\n" +
-		"Coming soon!"
+		"• System preferences"
 
 	return &Model{
 		width:         80,
@@ -43,13 +59,55 @@ func (m *Model) SetSize(width, height int) {
 	m.height = height
 }
 
+// AddPanel adds a panel to the settings menu
+func (m *Model) AddPanel(panel Panel) {
+	m.panels = append(m.panels, panel)
+}
+
+// Capturing reports whether a panel is open and handles esc itself
+func (m *Model) Capturing() bool {
+	return m.open != nil
+}
+
 // Update handles messages and updates the settings screen state
 func (m *Model) Update(msg tea.Msg) (*Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.SetSize(msg.Width, msg.Height)
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.open != nil {
+			if msg.String() == "esc" {
+				m.open.Close()
+				m.open = nil
+				return m, sound.Emit(services.UIBack)
+			}
+			return m, m.open.Update(msg)
+		}
+
+		switch msg.String() {
+		case "up", "k":
+			if m.selected > 0 {
+				m.selected--
+			}
+		case "down", "j":
+			if m.selected < len(m.panels)-1 {
+				m.selected++
+			}
+		case "enter":
+			if m.selected < len(m.panels) {
+				m.open = m.panels[m.selected]
+				return m, tea.Batch(m.open.Open(), sound.Emit(services.UISelect))
+			}
+		}
+		return m, nil
 	}
 
+	// Panels keep their own timers running while open
+	if m.open != nil {
+		return m, m.open.Update(msg)
+	}
 	return m, nil
 }
 
@@ -63,9 +121,26 @@ func (m *Model) View() string {
 		contentWidth = 20
 	}
 
+	if m.open != nil {
+		return styles.HeadingStyle.Render("⚙️ Settings › "+m.open.Title()) + "\n\n" +
+			m.open.View(contentWidth) + "\n\n" +
+			styles.StatusStyle.Render("esc: back")
+	}
+
+	var menu strings.Builder
+	for i, panel := range m.panels {
+		style := styles.ListItemStyle
+		if i == m.selected {
+			style = styles.ListItemSelectedStyle
+		}
+		menu.WriteString(style.Render(panel.Title()) + "\n")
+		menu.WriteString(styles.BodyStyle.Render("  "+panel.Description()) + "\n\n")
+	}
+
 	// Wrap the content text to fit
 	wrappedContent := services.Txt.WrapText(m.content, contentWidth)
 
 	return styles.HeadingStyle.Render("⚙️ Settings") + "\n\n" +
+		menu.String() +
 		styles.BodyStyle.Render(wrappedContent)
 }
@@ -3,27 +3,42 @@ package services
 import (
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
-	"strings"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
-	"github.com/faiface/beep/mp3"
 	"github.com/faiface/beep/speaker"
-	"github.com/faiface/beep/wav"
 )
 
 // AudioManager manages audio playback and sound effects
 type AudioManager struct {
-	// Playback state
-	speaker       *beep.Mixer
-	musicStreamer beep.StreamSeekCloser
-	musicControl  *beep.Ctrl
-	musicVolume   *effects.Volume
-	sfxVolume     *effects.Volume
+	// Playback state. The deck stays in the mixer for the life of the
+	// manager and is only touched with the speaker locked.
+	speaker      *beep.Mixer
+	deck         *deck
+	equalizer    *equalizer
+	limiter      *limiter
+	musicControl *beep.Ctrl
+	musicVolume  *effects.Volume
+	sfxMixer     *beep.Mixer
+	sfxVolume    *effects.Volume
+
+	// Sound effects are decoded once into the bank; the theme picks the
+	// sounds for UI events
+	sounds     *SoundBank
+	soundTheme *SoundTheme
+
+	// Announcements play on their own bus and duck the music
+	duck               *ducker
+	announcer          *announcer
+	announcementVolume *effects.Volume
+
+	// track is the deck track the manager considers current; deck events
+	// about other tracks are stale
+	track *deckTrack
 
 	// Current state
 	currentTrack string
@@ -31,24 +46,63 @@ type AudioManager struct {
 	isPaused     bool
 	position     time.Duration
 	duration     time.Duration
+	started      bool
+	ended        bool
 
-	// Volume levels
-	masterVolume     float64
-	musicVolumeLevel float64
-	sfxVolumeLevel   float64
+	// mixer holds the bus levels, saved to mixerFile on every change
+	mixer     MixerLevels
+	mixerFile string
+
+	// effects are the music bus's EQ and limiter settings, saved to
+	// effectsFile on every change
+	effects     Effects
+	effectsFile string
 
 	// Queue management
 	playlist     []string
 	currentIndex int
-	shuffleMode  bool
-	repeatMode   RepeatMode
+	// nextIndex is the playlist entry decoded and queued on the deck, or -1
+	nextIndex  int
+	repeatMode RepeatMode
+
+	// Shuffle plays the playlist in the order of a permutation, kept so
+	// Previous retraces it; smart shuffle consults the play history
+	shuffle     ShuffleMode
+	order       []int
+	history     *PlayHistory
+	smartWindow time.Duration
+	grouping    TrackGrouping
+
+	// queueFile keeps the playlist and position across restarts. Saves are
+	// requested on queueSaves and written off the playback path; queueSeq
+	// numbers the saved states so an older one never overwrites a newer.
+	queueFile    string
+	queueSaves   chan struct{}
+	queueSeq     uint64
+	queueWriteMu sync.Mutex
+	queueWritten uint64
+	// undo holds the playlist before each edit, most recent last
+	undo []queueSnapshot
+
+	// Loudness normalization applies track or album gain from the lookup,
+	// offset by the preamp in dB
+	normalization NormalizationMode
+	preamp        float64
+	gains         GainLookup
+
+	// Transitions between tracks; the playlist transition overrides the
+	// default until the playlist is replaced
+	transition         Transition
+	playlistTransition *Transition
 
 	// Channels for communication
 	nowPlayingChan chan string
-	statusChan     chan AudioStatus
+	statusChan     chan AudioEvent
 
 	// Synchronization
-	mutex sync.RWMutex
+	mutex  sync.RWMutex
+	closed bool
+	done   chan struct{}
 
 	// Configuration
 	musicDirectory string
@@ -64,24 +118,63 @@ const (
 	RepeatAll
 )
 
+// positionInterval is how often the playback position is refreshed
+const positionInterval = 250 * time.Millisecond
+
 // NewAudioManager creates a new audio manager instance
 func NewAudioManager() *AudioManager {
 	am := &AudioManager{
-		masterVolume:     1.0,
-		musicVolumeLevel: 1.0,
-		sfxVolumeLevel:   1.0,
-		playlist:         make([]string, 0),
-		currentIndex:     0,
-		repeatMode:       RepeatOff,
-		nowPlayingChan:   make(chan string, 10),
-		statusChan:       make(chan AudioStatus, 10),
-		musicDirectory:   "~/music",
-		sfxDirectory:     "assets/sounds",
+		mixer:          DefaultMixerLevels(),
+		effects:        DefaultEffects(),
+		playlist:       make([]string, 0),
+		currentIndex:   0,
+		nextIndex:      -1,
+		repeatMode:     RepeatOff,
+		smartWindow:    DefaultSmartShuffleWindow,
+		grouping:       GroupByDirectory,
+		transition:     DefaultTransition(),
+		normalization:  NormalizeOff,
+		queueSaves:     make(chan struct{}, 1),
+		nowPlayingChan: make(chan string, 10),
+		statusChan:     make(chan AudioEvent, 32),
+		done:           make(chan struct{}),
+		musicDirectory: "~/music",
+		sfxDirectory:   "assets/sounds",
+		sounds:         NewSoundBank(),
+	}
+
+	// Build the music chain once; tracks are swapped on the deck
+	am.deck = newDeck(am.transition)
+	am.equalizer = &equalizer{Streamer: am.deck}
+	am.limiter = newLimiter(am.equalizer)
+	// Bus volumes are powers of ten; applyMixerLocked sets their exponents
+	am.musicVolume = &effects.Volume{
+		Streamer: am.limiter,
+		Base:     10,
 	}
+	am.duck = newDucker(am.musicVolume, DefaultDucking())
+	am.musicControl = &beep.Ctrl{Streamer: am.duck, Paused: true}
+
+	// Sound effects mix over the music from memory
+	am.sfxMixer = &beep.Mixer{}
+	am.sfxVolume = &effects.Volume{
+		Streamer: am.sfxMixer,
+		Base:     10,
+	}
+
+	// Announcements mix over the music, ducking it while they play
+	am.announcer = &announcer{duck: am.duck}
+	am.announcementVolume = &effects.Volume{
+		Streamer: am.announcer,
+		Base:     10,
+	}
+
+	go am.trackPosition()
+	go am.handleDeckEvents()
+	go am.saveQueueLoop()
 
 	// Initialize speaker with reasonable sample rate
-	sr := beep.SampleRate(44100)
-	err := speaker.Init(sr, sr.N(time.Second/10))
+	err := speaker.Init(speakerSampleRate, speakerSampleRate.N(time.Second/10))
 	if err != nil {
 		log.Printf("Failed to initialize speaker: %v", err)
 		return am
@@ -89,98 +182,153 @@ func NewAudioManager() *AudioManager {
 
 	// Create mixer
 	am.speaker = &beep.Mixer{}
+	am.speaker.Add(am.musicControl, am.sfxVolume, am.announcementVolume)
 	speaker.Play(am.speaker)
 
 	return am
 }
 
+// ProbeAudio checks that the audio output device can be opened
+func ProbeAudio() error {
+	if err := speaker.Init(speakerSampleRate, speakerSampleRate.N(time.Second/10)); err != nil {
+		return fmt.Errorf("audio output unavailable: %w", err)
+	}
+	speaker.Close()
+	return nil
+}
+
 // LoadTrack loads a music track for playback
 func (am *AudioManager) LoadTrack(filePath string) error {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
-	// Stop current track if playing
-	if am.musicControl != nil {
-		am.musicControl.Paused = true
+	// Keep the playlist position in step when a queued track is picked directly
+	index := -1
+	for i, track := range am.playlist {
+		if track == filePath {
+			index = i
+			break
+		}
 	}
 
-	// Close previous streamer
-	if am.musicStreamer != nil {
-		am.musicStreamer.Close()
+	if err := am.loadTrackLocked(filePath); err != nil {
+		return err
 	}
-
-	// Open the audio file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to open audio file: %w", err)
+	if index >= 0 {
+		am.playNextInOrderLocked(index)
+		am.currentIndex = index
 	}
+	am.queueNextLocked()
 
-	// Determine file type and decode
-	ext := strings.ToLower(filepath.Ext(filePath))
-	var streamer beep.StreamSeekCloser
-	var format beep.Format
-
-	switch ext {
-	case ".mp3":
-		streamer, format, err = mp3.Decode(file)
-	case ".wav":
-		streamer, format, err = wav.Decode(file)
-	default:
-		file.Close()
-		return fmt.Errorf("unsupported audio format: %s", ext)
-	}
+	return nil
+}
 
+// loadTrackLocked replaces the current track, leaving playback paused.
+// Callers must hold am.mutex and queue the following track afterwards.
+func (am *AudioManager) loadTrackLocked(filePath string) error {
+	// Decode the file with whichever decoder recognises it, keeping the
+	// current track if that fails
+	track, err := openDeckTrack(filePath)
 	if err != nil {
-		file.Close()
-		return fmt.Errorf("failed to decode audio: %w", err)
-	}
-
-	// Create volume control
-	am.musicVolume = &effects.Volume{
-		Streamer: streamer,
-		Base:     2,
-		Volume:   am.volumeToDecibels(am.musicVolumeLevel),
-		Silent:   false,
+		return err
 	}
+	track.gain = am.trackGainLocked(filePath)
 
-	// Create playback control
-	am.musicControl = &beep.Ctrl{
-		Streamer: am.musicVolume,
-		Paused:   true,
-	}
+	speaker.Lock()
+	am.musicControl.Paused = true
+	am.deck.Load(track)
+	speaker.Unlock()
 
-	// Store references
-	am.musicStreamer = streamer
-	am.currentTrack = filePath
+	am.setTrackLocked(track)
 	am.isPlaying = false
 	am.isPaused = true
 
-	// Calculate duration (approximate)
-	am.duration = format.SampleRate.D(streamer.Len())
-
-	// Add to mixer
-	am.speaker.Add(am.musicControl)
-
 	// Notify about track change
-	am.nowPlayingChan <- fmt.Sprintf("Loaded: %s", filepath.Base(filePath))
+	am.notify(fmt.Sprintf("Loaded: %s", filepath.Base(filePath)))
 
 	return nil
 }
 
+// setTrackLocked records a track that has become current on the deck.
+// Callers must hold am.mutex.
+func (am *AudioManager) setTrackLocked(track *deckTrack) {
+	am.track = track
+	am.nextIndex = -1
+	am.currentTrack = track.path
+	am.started = false
+	am.ended = false
+	am.position = 0
+	am.duration = track.duration()
+}
+
+// queueNextLocked decodes the playlist entry that follows the current track
+// and queues it on the deck, so it can start without a gap. Callers must
+// hold am.mutex.
+func (am *AudioManager) queueNextLocked() {
+	var track *deckTrack
+	am.nextIndex = -1
+
+	// Only tracks played from the playlist advance through it
+	if am.playingFromPlaylistLocked() {
+		// Skip entries that fail to decode, trying each at most once
+		index := am.currentIndex
+		for range am.playlist {
+			next, ok := am.followingIndexLocked(index)
+			if !ok {
+				break
+			}
+
+			var err error
+			if track, err = openDeckTrack(am.playlist[next]); err == nil {
+				track.gain = am.trackGainLocked(track.path)
+				am.nextIndex = next
+				break
+			}
+			log.Printf("Skipping %s: %v", am.playlist[next], err)
+			index = next
+		}
+	}
+
+	speaker.Lock()
+	replaced := am.deck.Queue(track)
+	am.deck.closeTrack(replaced)
+	speaker.Unlock()
+}
+
 // Play starts or resumes playback
 func (am *AudioManager) Play() error {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
-	if am.musicControl == nil {
+	return am.playLocked()
+}
+
+// playLocked starts or resumes playback; callers must hold am.mutex
+func (am *AudioManager) playLocked() error {
+	if am.track == nil {
 		return fmt.Errorf("no track loaded")
 	}
 
+	// A finished track has left the deck, so load it again
+	if am.ended {
+		if err := am.loadTrackLocked(am.currentTrack); err != nil {
+			return err
+		}
+		am.queueNextLocked()
+	}
+
+	speaker.Lock()
 	am.musicControl.Paused = false
+	speaker.Unlock()
 	am.isPlaying = true
 	am.isPaused = false
 
-	am.nowPlayingChan <- fmt.Sprintf("Playing: %s", filepath.Base(am.currentTrack))
+	am.notify(fmt.Sprintf("Playing: %s", filepath.Base(am.currentTrack)))
+	if !am.started {
+		am.started = true
+		am.recordPlayLocked()
+		am.publish(TrackStarted)
+	}
 
 	return nil
 }
@@ -190,15 +338,18 @@ func (am *AudioManager) Pause() error {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
-	if am.musicControl == nil {
+	if am.track == nil {
 		return fmt.Errorf("no track loaded")
 	}
 
+	speaker.Lock()
 	am.musicControl.Paused = true
+	speaker.Unlock()
 	am.isPlaying = false
 	am.isPaused = true
+	am.requestQueueSaveLocked()
 
-	am.nowPlayingChan <- "Paused"
+	am.notify("Paused")
 
 	return nil
 }
@@ -208,134 +359,293 @@ func (am *AudioManager) Stop() error {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
-	if am.musicControl != nil {
-		am.musicControl.Paused = true
-	}
-
-	if am.musicStreamer != nil {
-		am.musicStreamer.Seek(0)
+	if am.track != nil && !am.ended {
+		// Reloading drops any crossfade in progress and rewinds the track
+		if err := am.loadTrackLocked(am.currentTrack); err != nil {
+			return err
+		}
+		am.queueNextLocked()
 	}
 
 	am.isPlaying = false
 	am.isPaused = false
+	am.started = false
 	am.position = 0
 
-	am.nowPlayingChan <- "Stopped"
+	am.notify("Stopped")
 
 	return nil
 }
 
-// SetVolume sets the master volume (0.0 to 1.0)
-func (am *AudioManager) SetVolume(volume float64) {
+// Seek moves playback of the current track to position, clamped to the
+// track's length
+func (am *AudioManager) Seek(position time.Duration) error {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
-	if volume < 0 {
-		volume = 0
-	}
-	if volume > 1 {
-		volume = 1
-	}
-
-	am.masterVolume = volume
-	am.updateVolumes()
+	return am.seekLocked(position)
 }
 
-// SetMusicVolume sets the music volume (0.0 to 1.0)
-func (am *AudioManager) SetMusicVolume(volume float64) {
+// SeekRelative moves playback forwards or, with a negative offset, backwards
+func (am *AudioManager) SeekRelative(offset time.Duration) error {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
-	if volume < 0 {
-		volume = 0
+	if am.track == nil {
+		return fmt.Errorf("no track loaded")
+	}
+	return am.seekLocked(am.positionLocked() + offset)
+}
+
+// seekLocked seeks the current track; callers must hold am.mutex
+func (am *AudioManager) seekLocked(position time.Duration) error {
+	if am.track == nil {
+		return fmt.Errorf("no track loaded")
+	}
+
+	// A finished track has left the deck, so load it again, paused
+	if am.ended {
+		wasStarted := am.started
+		if err := am.loadTrackLocked(am.currentTrack); err != nil {
+			return err
+		}
+		am.queueNextLocked()
+		am.started = wasStarted
 	}
-	if volume > 1 {
-		volume = 1
+
+	position = min(max(position, 0), am.duration)
+	speaker.Lock()
+	err := am.deck.Seek(am.track, position)
+	speaker.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to seek: %w", err)
 	}
 
-	am.musicVolumeLevel = volume
-	am.updateVolumes()
+	am.position = position
+	am.publish(PositionChanged)
+	return nil
 }
 
-// SetSFXVolume sets the sound effects volume (0.0 to 1.0)
-func (am *AudioManager) SetSFXVolume(volume float64) {
+// positionLocked reads the playback position of the current track.
+// Callers must hold am.mutex.
+func (am *AudioManager) positionLocked() time.Duration {
+	if am.track == nil || am.ended {
+		return am.position
+	}
+
+	speaker.Lock()
+	defer speaker.Unlock()
+	return am.track.position()
+}
+
+// handleDeckEvents applies track changes made by the deck on the audio
+// thread until the manager is closed
+func (am *AudioManager) handleDeckEvents() {
+	for {
+		select {
+		case <-am.done:
+			return
+		case <-am.deck.signal:
+			for _, event := range am.deck.takeEvents() {
+				am.deckEvent(event)
+			}
+		}
+	}
+}
+
+// deckEvent records a track change made by the deck
+func (am *AudioManager) deckEvent(event deckEvent) {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
-	if volume < 0 {
-		volume = 0
+	if am.closed || event.From != am.track {
+		return
 	}
-	if volume > 1 {
-		volume = 1
+
+	am.position = am.duration
+	am.isPlaying = false
+	am.publish(TrackEnded)
+
+	switch event.Type {
+	case deckAdvanced:
+		if am.nextIndex >= 0 {
+			am.currentIndex = am.nextIndex
+		}
+		am.setTrackLocked(event.To)
+		am.isPlaying = true
+		am.started = true
+		am.notify(fmt.Sprintf("Playing: %s", filepath.Base(am.currentTrack)))
+		am.recordPlayLocked()
+		am.publish(TrackStarted)
+		am.queueNextLocked()
+
+	case deckEnded:
+		am.ended = true
+		am.isPaused = false
+		speaker.Lock()
+		am.musicControl.Paused = true
+		speaker.Unlock()
+		am.notify("Stopped")
 	}
+}
 
-	am.sfxVolumeLevel = volume
+// nextIndexLocked returns the playlist index to play after the current one
+// according to the repeat and shuffle modes. Callers must hold am.mutex.
+func (am *AudioManager) nextIndexLocked() (int, bool) {
+	return am.followingIndexLocked(am.currentIndex)
 }
 
-// PlaySFX plays a sound effect
-func (am *AudioManager) PlaySFX(filename string) error {
-	// Build full path
-	fullPath := filepath.Join(am.sfxDirectory, filename)
+// followingIndexLocked returns the playlist index to play after index.
+// Callers must hold am.mutex.
+func (am *AudioManager) followingIndexLocked(index int) (int, bool) {
+	count := len(am.playlist)
+	switch {
+	case count == 0:
+		return 0, false
+	case am.repeatMode == RepeatOne:
+		return index, true
+	case am.shuffle != ShuffleOff && len(am.order) == count:
+		position := am.orderPositionLocked(index)
+		if position < count-1 {
+			return am.order[position+1], true
+		}
+		if am.repeatMode == RepeatAll {
+			return am.order[0], true
+		}
+		return 0, false
+	case index < count-1:
+		return index + 1, true
+	case am.repeatMode == RepeatAll:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
 
-	// Open the file
-	file, err := os.Open(fullPath)
-	if err != nil {
-		return fmt.Errorf("failed to open SFX file: %w", err)
+// trackPosition refreshes the playback position until the manager is closed
+func (am *AudioManager) trackPosition() {
+	ticker := time.NewTicker(positionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-am.done:
+			return
+		case <-ticker.C:
+			am.updatePosition()
+		}
 	}
-	defer file.Close()
+}
 
-	// Decode based on file extension
-	ext := strings.ToLower(filepath.Ext(filename))
-	var streamer beep.StreamCloser
+// updatePosition reads the position from the deck and publishes it while
+// playing
+func (am *AudioManager) updatePosition() {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
 
-	switch ext {
-	case ".wav":
-		streamer, _, err = wav.Decode(file)
-	case ".mp3":
-		streamer, _, err = mp3.Decode(file)
-	default:
-		return fmt.Errorf("unsupported SFX format: %s", ext)
+	if am.track == nil || !am.isPlaying {
+		return
 	}
 
-	if err != nil {
-		return fmt.Errorf("failed to decode SFX: %w", err)
+	if position := am.positionLocked(); position != am.position {
+		am.position = position
+		am.publish(PositionChanged)
 	}
+}
 
-	// Apply volume
-	volume := &effects.Volume{
-		Streamer: streamer,
-		Base:     2,
-		Volume:   am.volumeToDecibels(am.sfxVolumeLevel * am.masterVolume),
-		Silent:   false,
+// SetTransition sets how tracks are joined when the playlist does not set
+// its own transition
+func (am *AudioManager) SetTransition(transition Transition) error {
+	if err := transition.Validate(); err != nil {
+		return err
 	}
 
-	// Play the sound effect
-	done := make(chan bool)
-	speaker.Play(beep.Seq(volume, beep.Callback(func() {
-		done <- true
-	})))
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
 
-	// Don't wait for completion, let it play asynchronously
-	go func() {
-		<-done
-		streamer.Close()
-	}()
+	am.transition = transition
+	am.applyTransitionLocked()
+	return nil
+}
+
+// SetPlaylistTransition sets how the tracks of the current playlist are
+// joined. It applies until the playlist is replaced.
+func (am *AudioManager) SetPlaylistTransition(transition Transition) error {
+	if err := transition.Validate(); err != nil {
+		return err
+	}
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
 
+	am.playlistTransition = &transition
+	am.applyTransitionLocked()
 	return nil
 }
 
+// GetTransition returns the transition in effect
+func (am *AudioManager) GetTransition() Transition {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+
+	return am.effectiveTransitionLocked()
+}
+
+// effectiveTransitionLocked returns the playlist transition if set, else
+// the default. Callers must hold am.mutex.
+func (am *AudioManager) effectiveTransitionLocked() Transition {
+	if am.playlistTransition != nil {
+		return *am.playlistTransition
+	}
+	return am.transition
+}
+
+// applyTransitionLocked hands the effective transition to the deck. A
+// crossfade already under way finishes with its original settings.
+// Callers must hold am.mutex.
+func (am *AudioManager) applyTransitionLocked() {
+	transition := am.effectiveTransitionLocked()
+	speaker.Lock()
+	if am.deck.outgoing == nil {
+		am.deck.transition = transition
+	}
+	speaker.Unlock()
+}
+
+// SetVolume moves the master fader (0.0 to 1.0)
+func (am *AudioManager) SetVolume(volume float64) {
+	am.SetBusLevel(BusMaster, volume)
+}
+
+// SetMusicVolume moves the music fader (0.0 to 1.0)
+func (am *AudioManager) SetMusicVolume(volume float64) {
+	am.SetBusLevel(BusMusic, volume)
+}
+
+// SetSFXVolume moves the sound effects fader (0.0 to 1.0)
+func (am *AudioManager) SetSFXVolume(volume float64) {
+	am.SetBusLevel(BusSFX, volume)
+}
+
 // GetStatus returns the current audio status
 func (am *AudioManager) GetStatus() AudioStatus {
 	am.mutex.RLock()
 	defer am.mutex.RUnlock()
 
+	return am.statusLocked()
+}
+
+// statusLocked builds the current status; callers must hold am.mutex
+func (am *AudioManager) statusLocked() AudioStatus {
 	return AudioStatus{
 		IsPlaying:    am.isPlaying,
 		IsPaused:     am.isPaused,
 		CurrentTrack: am.currentTrack,
-		Position:     am.position,
+		Position:     am.positionLocked(),
 		Duration:     am.duration,
-		Volume:       am.masterVolume,
+		Volume:       am.mixer[BusMaster].Level,
+		Muted:        am.mixer[BusMaster].Muted,
+		Shuffle:      am.shuffle,
 	}
 }
 
@@ -344,8 +654,9 @@ func (am *AudioManager) GetNowPlayingChannel() <-chan string {
 	return am.nowPlayingChan
 }
 
-// GetStatusChannel returns the channel for status updates
-func (am *AudioManager) GetStatusChannel() <-chan AudioStatus {
+// GetStatusChannel returns the channel playback events are published on.
+// The channel is closed when the manager is closed.
+func (am *AudioManager) GetStatusChannel() <-chan AudioEvent {
 	return am.statusChan
 }
 
@@ -354,7 +665,60 @@ func (am *AudioManager) AddToPlaylist(tracks []string) {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
+	am.pushUndoLocked()
 	am.playlist = append(am.playlist, tracks...)
+	if am.shuffle != ShuffleOff {
+		// New tracks are shuffled into the part of the order still to play
+		am.reshuffleLocked()
+		am.queueNextLocked()
+	} else if am.nextIndex < 0 {
+		am.queueNextLocked()
+	}
+	am.publish(QueueChanged)
+}
+
+// PlayNext inserts tracks into the playlist straight after the current one
+func (am *AudioManager) PlayNext(tracks []string) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.pushUndoLocked()
+	am.insertNextLocked(tracks)
+	am.queueNextLocked()
+	am.publish(QueueChanged)
+}
+
+// PlayNow inserts a track after the current one and switches to it,
+// starting playback
+func (am *AudioManager) PlayNow(track string) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.pushUndoLocked()
+	index := am.insertNextLocked([]string{track})
+	am.publish(QueueChanged)
+	if err := am.switchTrackLocked(index); err != nil {
+		return err
+	}
+	if !am.isPlaying {
+		return am.playLocked()
+	}
+	return nil
+}
+
+// insertNextLocked inserts tracks after the current playlist entry and
+// returns the index of the first. Callers must hold am.mutex and queue the
+// following track afterwards.
+func (am *AudioManager) insertNextLocked(tracks []string) int {
+	position := min(am.currentIndex+1, len(am.playlist))
+	am.playlist = slices.Insert(am.playlist, position, tracks...)
+	if len(am.playlist) == len(tracks) {
+		// The playlist was empty, so the first track becomes the current entry
+		position = 0
+		am.currentIndex = 0
+	}
+	am.insertOrderLocked(position, len(tracks))
+	return position
 }
 
 // SetPlaylist replaces the current playlist
@@ -362,12 +726,26 @@ func (am *AudioManager) SetPlaylist(tracks []string) {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
+	am.pushUndoLocked()
 	am.playlist = make([]string, len(tracks))
 	copy(am.playlist, tracks)
 	am.currentIndex = 0
+	for i, track := range am.playlist {
+		if track == am.currentTrack {
+			am.currentIndex = i
+			break
+		}
+	}
+	am.playlistTransition = nil
+	am.applyTransitionLocked()
+	am.order = nil
+	am.reshuffleLocked()
+	am.queueNextLocked()
+	am.publish(QueueChanged)
 }
 
-// Next moves to the next track in the playlist
+// Next moves to the next track in the playlist, continuing playback if a
+// track was playing
 func (am *AudioManager) Next() error {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
@@ -376,27 +754,20 @@ func (am *AudioManager) Next() error {
 		return fmt.Errorf("playlist is empty")
 	}
 
-	switch am.repeatMode {
-	case RepeatOne:
-		// Stay on the same track
-	case RepeatAll:
-		am.currentIndex = (am.currentIndex + 1) % len(am.playlist)
-	default:
-		if am.currentIndex < len(am.playlist)-1 {
-			am.currentIndex++
-		} else {
-			return fmt.Errorf("end of playlist reached")
-		}
+	// Prefer the queued track so a shuffled pick is not drawn twice
+	next, ok := am.nextIndex, am.nextIndex >= 0
+	if !ok {
+		next, ok = am.nextIndexLocked()
 	}
-
-	if am.currentIndex < len(am.playlist) {
-		return am.LoadTrack(am.playlist[am.currentIndex])
+	if !ok {
+		return fmt.Errorf("end of playlist reached")
 	}
 
-	return nil
+	return am.switchTrackLocked(next)
 }
 
-// Previous moves to the previous track in the playlist
+// Previous moves to the previous track in the playlist, continuing playback
+// if a track was playing
 func (am *AudioManager) Previous() error {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
@@ -405,15 +776,48 @@ func (am *AudioManager) Previous() error {
 		return fmt.Errorf("playlist is empty")
 	}
 
-	if am.currentIndex > 0 {
-		am.currentIndex--
-	} else if am.repeatMode == RepeatAll {
-		am.currentIndex = len(am.playlist) - 1
-	} else {
+	previous, ok := am.precedingIndexLocked(am.currentIndex)
+	if !ok {
 		return fmt.Errorf("beginning of playlist reached")
 	}
 
-	return am.LoadTrack(am.playlist[am.currentIndex])
+	return am.switchTrackLocked(previous)
+}
+
+// precedingIndexLocked returns the playlist index played before index,
+// retracing the shuffle order when shuffling. Callers must hold am.mutex.
+func (am *AudioManager) precedingIndexLocked(index int) (int, bool) {
+	count := len(am.playlist)
+	sequence := func(position int) int { return position }
+	if am.shuffle != ShuffleOff && len(am.order) == count {
+		index = am.orderPositionLocked(index)
+		sequence = func(position int) int { return am.order[position] }
+	}
+
+	switch {
+	case index > 0:
+		return sequence(index - 1), true
+	case am.repeatMode == RepeatAll:
+		return sequence(count - 1), true
+	default:
+		return 0, false
+	}
+}
+
+// switchTrackLocked loads a playlist entry, keeping playback running if it
+// was. Callers must hold am.mutex.
+func (am *AudioManager) switchTrackLocked(index int) error {
+	wasPlaying := am.isPlaying
+	if err := am.loadTrackLocked(am.playlist[index]); err != nil {
+		return err
+	}
+	am.currentIndex = index
+	am.queueNextLocked()
+
+	if wasPlaying {
+		return am.playLocked()
+	}
+	return nil
 }
 
 // SetRepeatMode sets the repeat mode
@@ -422,6 +826,7 @@ func (am *AudioManager) SetRepeatMode(mode RepeatMode) {
 	defer am.mutex.Unlock()
 
 	am.repeatMode = mode
+	am.queueNextLocked()
 }
 
 // GetPlaylist returns the current playlist
@@ -442,19 +847,42 @@ func (am *AudioManager) SetMusicDirectory(dir string) {
 	am.musicDirectory = dir
 }
 
+// SetSFXDirectory sets the directory sound effects are loaded from
+func (am *AudioManager) SetSFXDirectory(dir string) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.sfxDirectory = dir
+}
+
+// GetMusicDirectory returns the directory to scan for music
+func (am *AudioManager) GetMusicDirectory() string {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+
+	return am.musicDirectory
+}
+
 // Close cleans up the audio manager
 func (am *AudioManager) Close() error {
 	am.mutex.Lock()
 	defer am.mutex.Unlock()
 
-	if am.musicControl != nil {
-		am.musicControl.Paused = true
-	}
-
-	if am.musicStreamer != nil {
-		am.musicStreamer.Close()
+	if am.closed {
+		return nil
 	}
+	am.saveQueueLocked()
 
+	speaker.Lock()
+	am.musicControl.Paused = true
+	am.deck.Clear()
+	am.announcer.Clear()
+	am.sfxMixer.Clear()
+	speaker.Unlock()
+	am.track = nil
+
+	am.closed = true
+	close(am.done)
 	close(am.nowPlayingChan)
 	close(am.statusChan)
 
@@ -463,26 +891,6 @@ func (am *AudioManager) Close() error {
 
 // Helper methods
 
-// updateVolumes updates the volume controls
-func (am *AudioManager) updateVolumes() {
-	if am.musicVolume != nil {
-		am.musicVolume.Volume = am.volumeToDecibels(am.musicVolumeLevel * am.masterVolume)
-	}
-}
-
-// volumeToDecibels converts a 0.0-1.0 volume to decibels
-func (am *AudioManager) volumeToDecibels(volume float64) float64 {
-	if volume <= 0 {
-		return -10 // Very quiet but not silent
-	}
-	if volume >= 1 {
-		return 0
-	}
-	// Convert linear volume to logarithmic (decibels)
-	// -20dB to 0dB range
-	return -20 + (20 * volume)
-}
-
 // Global audio manager instance
 var GlobalAudioManager *AudioManager
 
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// Ducking describes how far and how fast music is lowered under
+// announcements
+type Ducking struct {
+	// Depth is how many decibels the music drops by
+	Depth float64
+	// Attack is how long the music takes to drop; announcements start once
+	// it has
+	Attack time.Duration
+	// Release is how long the music takes to come back afterwards
+	Release time.Duration
+}
+
+// DefaultDucking drops music by 12 dB in 300 ms and restores it over 800 ms
+func DefaultDucking() Ducking {
+	return Ducking{Depth: 12, Attack: 300 * time.Millisecond, Release: 800 * time.Millisecond}
+}
+
+// Validate checks the ducking settings
+func (d Ducking) Validate() error {
+	if d.Depth < 0 {
+		return fmt.Errorf("ducking depth must not be negative, got: %v dB", d.Depth)
+	}
+	if d.Attack < 0 || d.Release < 0 {
+		return fmt.Errorf("ducking attack and release must not be negative, got: %v and %v", d.Attack, d.Release)
+	}
+	return nil
+}
+
+// ducker lowers the music by a ramped amount of attenuation. It is only
+// touched on the audio thread or with the speaker locked.
+type ducker struct {
+	Streamer beep.Streamer
+
+	settings Ducking
+	// attenuation is the current drop in dB, moving towards target
+	attenuation float64
+	target      float64
+	gain        float64
+}
+
+// newDucker creates a ducker that passes the music through unchanged
+func newDucker(streamer beep.Streamer, settings Ducking) *ducker {
+	return &ducker{Streamer: streamer, settings: settings, gain: 1}
+}
+
+// Duck starts lowering the music
+func (d *ducker) Duck() {
+	d.target = d.settings.Depth
+}
+
+// Restore starts bringing the music back
+func (d *ducker) Restore() {
+	d.target = 0
+}
+
+// Settling returns how many samples remain until the music is fully down
+func (d *ducker) Settling() int {
+	step := d.step(d.settings.Attack)
+	if d.target <= d.attenuation || math.IsInf(step, 1) {
+		return 0
+	}
+	return int(math.Ceil((d.target - d.attenuation) / step))
+}
+
+// step is the change in attenuation per sample for a ramp over duration
+func (d *ducker) step(duration time.Duration) float64 {
+	samples := speakerSampleRate.N(duration)
+	if samples <= 0 {
+		return math.Inf(1)
+	}
+	return d.settings.Depth / float64(samples)
+}
+
+func (d *ducker) Stream(samples [][2]float64) (int, bool) {
+	n, ok := d.Streamer.Stream(samples)
+	for i := range samples[:n] {
+		if d.attenuation != d.target {
+			if d.attenuation < d.target {
+				d.attenuation = min(d.attenuation+d.step(d.settings.Attack), d.target)
+			} else {
+				d.attenuation = max(d.attenuation-d.step(d.settings.Release), d.target)
+			}
+			d.gain = math.Pow(10, -d.attenuation/20)
+		}
+		samples[i][0] *= d.gain
+		samples[i][1] *= d.gain
+	}
+	return n, ok
+}
+
+func (d *ducker) Err() error {
+	return d.Streamer.Err()
+}
+
+// announcement is a sequence of decoded files played as one
+type announcement struct {
+	streamer beep.Streamer
+	files    []beep.StreamSeekCloser
+}
+
+// close releases the announcement's files
+func (a *announcement) close() {
+	for _, file := range a.files {
+		file.Close()
+	}
+}
+
+// announcer plays queued announcements one at a time, ducking the music
+// while any are playing. It stays in the mixer for the life of the manager
+// and is only touched on the audio thread or with the speaker locked.
+type announcer struct {
+	duck    *ducker
+	queue   []*announcement
+	current *announcement
+	// lead is the silence left before the current announcement, while the
+	// music drops
+	lead int
+}
+
+// Enqueue adds an announcement to play after those already queued
+func (a *announcer) Enqueue(item *announcement) {
+	a.queue = append(a.queue, item)
+}
+
+// Busy reports whether an announcement is playing or queued
+func (a *announcer) Busy() bool {
+	return a.current != nil || len(a.queue) > 0
+}
+
+// Clear drops every announcement, restoring the music
+func (a *announcer) Clear() {
+	if a.current != nil {
+		a.current.close()
+		a.current = nil
+	}
+	for _, item := range a.queue {
+		item.close()
+	}
+	a.queue = nil
+	a.duck.Restore()
+}
+
+func (a *announcer) Stream(samples [][2]float64) (int, bool) {
+	filled := 0
+	for filled < len(samples) {
+		if a.current == nil {
+			if len(a.queue) == 0 {
+				break
+			}
+			a.current = a.queue[0]
+			a.queue = a.queue[1:]
+			a.duck.Duck()
+			a.lead = a.duck.Settling()
+		}
+
+		if a.lead > 0 {
+			n := min(a.lead, len(samples)-filled)
+			clear(samples[filled : filled+n])
+			a.lead -= n
+			filled += n
+			continue
+		}
+
+		n, ok := a.current.streamer.Stream(samples[filled:])
+		filled += n
+		if !ok || n == 0 {
+			a.current.close()
+			a.current = nil
+			if len(a.queue) == 0 {
+				a.duck.Restore()
+			}
+		}
+	}
+
+	// Stay in the mixer, silent, between announcements
+	clear(samples[filled:])
+	return len(samples), true
+}
+
+func (a *announcer) Err() error {
+	return nil
+}
+
+// PlayAnnouncement queues a file, or a sequence of files such as a chime
+// followed by a message, to play over the music. The music is ducked while
+// announcements play and restored once the last has finished. Relative
+// paths are looked up in the sound effects directory.
+func (am *AudioManager) PlayAnnouncement(files ...string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no announcement files given")
+	}
+
+	am.mutex.RLock()
+	sfxDirectory := am.sfxDirectory
+	am.mutex.RUnlock()
+
+	item := &announcement{}
+	parts := make([]beep.Streamer, 0, len(files))
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(sfxDirectory, file)
+		}
+		streamer, format, err := AudioDecoders.Open(file)
+		if err != nil {
+			item.close()
+			return fmt.Errorf("failed to load announcement: %w", err)
+		}
+		item.files = append(item.files, streamer)
+		parts = append(parts, resample(streamer, format))
+	}
+	item.streamer = beep.Seq(parts...)
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if am.closed {
+		item.close()
+		return fmt.Errorf("audio manager is closed")
+	}
+
+	speaker.Lock()
+	am.announcer.Enqueue(item)
+	speaker.Unlock()
+	return nil
+}
+
+// IsAnnouncing reports whether an announcement is playing or queued
+func (am *AudioManager) IsAnnouncing() bool {
+	speaker.Lock()
+	defer speaker.Unlock()
+
+	return am.announcer.Busy()
+}
+
+// SetDucking sets how music is lowered under announcements
+func (am *AudioManager) SetDucking(ducking Ducking) error {
+	if err := ducking.Validate(); err != nil {
+		return err
+	}
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	speaker.Lock()
+	am.duck.settings = ducking
+	if am.duck.target > 0 {
+		am.duck.target = ducking.Depth
+	}
+	speaker.Unlock()
+	return nil
+}
+
+// SetAnnouncementVolume moves the announcement fader (0.0 to 1.0)
+func (am *AudioManager) SetAnnouncementVolume(volume float64) {
+	am.SetBusLevel(BusAnnouncement, volume)
+}
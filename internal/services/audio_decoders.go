@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/flac"
+	"github.com/faiface/beep/mp3"
+	"github.com/faiface/beep/vorbis"
+	"github.com/faiface/beep/wav"
+)
+
+// speakerSampleRate is the rate the speaker is initialised at; decoded
+// audio at any other rate is resampled to it
+const speakerSampleRate = beep.SampleRate(44100)
+
+// resampleQuality is the beep.Resample quality used for tracks and effects
+const resampleQuality = 4
+
+// sniffLength is how many leading bytes are read to identify a file
+const sniffLength = 12
+
+// DecodeFunc decodes an audio stream. The returned streamer owns rc and
+// closes it when the streamer is closed or decoding fails.
+type DecodeFunc func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error)
+
+// AudioDecoder describes a decodable audio format
+type AudioDecoder struct {
+	Name string
+	// Extensions are lower case and include the dot, e.g. ".flac"
+	Extensions []string
+	// Match reports whether the leading bytes of a file are in this format
+	Match  func(header []byte) bool
+	Decode DecodeFunc
+}
+
+// DecoderRegistry selects a decoder for a file by its content, falling back
+// to its extension
+type DecoderRegistry struct {
+	mu       sync.RWMutex
+	decoders []AudioDecoder
+}
+
+// NewDecoderRegistry creates an empty registry
+func NewDecoderRegistry() *DecoderRegistry {
+	return &DecoderRegistry{}
+}
+
+// Register adds a decoder. Decoders registered later take precedence when
+// several match the same file.
+func (r *DecoderRegistry) Register(decoder AudioDecoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders = append([]AudioDecoder{decoder}, r.decoders...)
+}
+
+// Supports reports whether a file's extension belongs to a registered format
+func (r *DecoderRegistry) Supports(path string) bool {
+	_, ok := r.byExtension(strings.ToLower(filepath.Ext(path)))
+	return ok
+}
+
+// Extensions returns the registered file extensions
+func (r *DecoderRegistry) Extensions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var extensions []string
+	for _, decoder := range r.decoders {
+		extensions = append(extensions, decoder.Extensions...)
+	}
+	return extensions
+}
+
+// byExtension finds the decoder registered for an extension
+func (r *DecoderRegistry) byExtension(ext string) (AudioDecoder, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, decoder := range r.decoders {
+		for _, e := range decoder.Extensions {
+			if e == ext {
+				return decoder, true
+			}
+		}
+	}
+	return AudioDecoder{}, false
+}
+
+// byContent finds the decoder whose magic bytes match the header
+func (r *DecoderRegistry) byContent(header []byte) (AudioDecoder, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, decoder := range r.decoders {
+		if decoder.Match != nil && decoder.Match(header) {
+			return decoder, true
+		}
+	}
+	return AudioDecoder{}, false
+}
+
+// Open decodes the file at path. The format is sniffed from the file's
+// leading bytes so misnamed files still play; the extension is used when
+// no decoder recognises the content.
+func (r *DecoderRegistry) Open(path string) (beep.StreamSeekCloser, beep.Format, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, beep.Format{}, fmt.Errorf("failed to open audio file: %w", err)
+	}
+
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		file.Close()
+		return nil, beep.Format{}, fmt.Errorf("failed to read audio file: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, beep.Format{}, fmt.Errorf("failed to rewind audio file: %w", err)
+	}
+
+	decoder, ok := r.byContent(header[:n])
+	if !ok {
+		ext := strings.ToLower(filepath.Ext(path))
+		if decoder, ok = r.byExtension(ext); !ok {
+			file.Close()
+			return nil, beep.Format{}, fmt.Errorf("unsupported audio format: %s", ext)
+		}
+	}
+
+	streamer, format, err := decoder.Decode(file)
+	if err != nil {
+		return nil, beep.Format{}, fmt.Errorf("failed to decode %s audio: %w", decoder.Name, err)
+	}
+	return streamer, format, nil
+}
+
+// AudioDecoders is the registry used by AudioManager. MP3, WAV, FLAC and
+// Ogg Vorbis are registered by default.
+var AudioDecoders = NewDecoderRegistry()
+
+func init() {
+	AudioDecoders.Register(AudioDecoder{
+		Name:       "MP3",
+		Extensions: []string{".mp3"},
+		Match:      isMP3,
+		Decode:     mp3.Decode,
+	})
+	AudioDecoders.Register(AudioDecoder{
+		Name:       "WAV",
+		Extensions: []string{".wav", ".wave"},
+		Match: func(header []byte) bool {
+			return len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE"))
+		},
+		Decode: func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+			return wav.Decode(rc)
+		},
+	})
+	AudioDecoders.Register(AudioDecoder{
+		Name:       "FLAC",
+		Extensions: []string{".flac"},
+		Match: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("fLaC"))
+		},
+		Decode: func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+			return flac.Decode(rc)
+		},
+	})
+	AudioDecoders.Register(AudioDecoder{
+		Name:       "Ogg Vorbis",
+		Extensions: []string{".ogg", ".oga"},
+		Match: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("OggS"))
+		},
+		Decode: vorbis.Decode,
+	})
+}
+
+// isMP3 recognises an ID3v2 tag or an MPEG audio frame sync
+func isMP3(header []byte) bool {
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		return true
+	}
+	return len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0
+}
+
+// resample converts a stream to the speaker's sample rate
+func resample(streamer beep.Streamer, format beep.Format) beep.Streamer {
+	if format.SampleRate == speakerSampleRate {
+		return streamer
+	}
+	return beep.Resample(resampleQuality, format.SampleRate, speakerSampleRate, streamer)
+}
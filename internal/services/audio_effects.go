@@ -0,0 +1,342 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/cmplx"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// biquad is a second order IIR filter section with a state per channel
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             [2]float64
+}
+
+// process filters one sample of a channel
+func (f *biquad) process(channel int, x float64) float64 {
+	y := f.b0*x + f.z1[channel]
+	f.z1[channel] = f.b1*x - f.a1*y + f.z2[channel]
+	f.z2[channel] = f.b2*x - f.a2*y
+	return y
+}
+
+// response returns the filter's gain in dB at a frequency
+func (f *biquad) response(frequency float64, rate beep.SampleRate) float64 {
+	z := cmplx.Exp(complex(0, -2*math.Pi*frequency/float64(rate)))
+	h := (complex(f.b0, 0) + complex(f.b1, 0)*z + complex(f.b2, 0)*z*z) /
+		(1 + complex(f.a1, 0)*z + complex(f.a2, 0)*z*z)
+	return 20 * math.Log10(cmplx.Abs(h))
+}
+
+// newBiquad normalizes coefficients by a0
+func newBiquad(b0, b1, b2, a0, a1, a2 float64) biquad {
+	return biquad{b0: b0 / a0, b1: b1 / a0, b2: b2 / a0, a1: a1 / a0, a2: a2 / a0}
+}
+
+// The filter designs follow the Audio EQ Cookbook by Robert Bristow-Johnson
+
+// peakingFilter boosts or cuts a band around frequency
+func peakingFilter(frequency, gain, q float64, rate beep.SampleRate) biquad {
+	a := math.Pow(10, gain/40)
+	w0 := 2 * math.Pi * frequency / float64(rate)
+	alpha := math.Sin(w0) / (2 * q)
+	cos := math.Cos(w0)
+	return newBiquad(1+alpha*a, -2*cos, 1-alpha*a, 1+alpha/a, -2*cos, 1-alpha/a)
+}
+
+// shelfFilter boosts or cuts everything below (low) or above frequency,
+// with the steepest slope that does not overshoot
+func shelfFilter(low bool, frequency, gain float64, rate beep.SampleRate) biquad {
+	a := math.Pow(10, gain/40)
+	w0 := 2 * math.Pi * frequency / float64(rate)
+	alpha := math.Sin(w0) / math.Sqrt2
+	cos := math.Cos(w0)
+	root := 2 * math.Sqrt(a) * alpha
+	if low {
+		return newBiquad(
+			a*((a+1)-(a-1)*cos+root), 2*a*((a-1)-(a+1)*cos), a*((a+1)-(a-1)*cos-root),
+			(a+1)+(a-1)*cos+root, -2*((a-1)+(a+1)*cos), (a+1)+(a-1)*cos-root)
+	}
+	return newBiquad(
+		a*((a+1)+(a-1)*cos+root), -2*a*((a-1)+(a+1)*cos), a*((a+1)+(a-1)*cos-root),
+		(a+1)-(a-1)*cos+root, 2*((a-1)-(a+1)*cos), (a+1)-(a-1)*cos-root)
+}
+
+// highPassFilter removes everything below frequency at 12 dB per octave
+func highPassFilter(frequency float64, rate beep.SampleRate) biquad {
+	w0 := 2 * math.Pi * frequency / float64(rate)
+	alpha := math.Sin(w0) / math.Sqrt2
+	cos := math.Cos(w0)
+	return newBiquad((1+cos)/2, -(1 + cos), (1+cos)/2, 1+alpha, -2*cos, 1-alpha)
+}
+
+// Shelf corner frequencies for the bass and treble controls
+const (
+	bassShelfFrequency   = 120.0
+	trebleShelfFrequency = 6000.0
+)
+
+// EQGainLimit is the most a band or shelf can boost or cut, in dB
+const EQGainLimit = 12.0
+
+// EQBand is a peaking band of the equalizer
+type EQBand struct {
+	// Frequency is the centre of the band in Hz
+	Frequency float64 `json:"frequency"`
+	// Gain is in dB
+	Gain float64 `json:"gain"`
+	// Q sets the width; higher is narrower
+	Q float64 `json:"q"`
+}
+
+// DefaultEQBands returns the bands of the equalizer, all flat
+func DefaultEQBands() []EQBand {
+	frequencies := []float64{60, 150, 400, 1000, 2500, 6300, 15000}
+	bands := make([]EQBand, len(frequencies))
+	for i, frequency := range frequencies {
+		bands[i] = EQBand{Frequency: frequency, Q: 1}
+	}
+	return bands
+}
+
+// EQPreset is a named set of gains for the default bands
+type EQPreset struct {
+	Name string
+	// Gains are in dB, one per default band
+	Gains []float64
+	// HighPass is the high-pass frequency, 0 for none
+	HighPass float64
+}
+
+// EQPresets lists the presets in display order
+var EQPresets = []EQPreset{
+	{Name: "flat", Gains: []float64{0, 0, 0, 0, 0, 0, 0}},
+	{Name: "reduce_boom", Gains: []float64{-3, -6, -2, 0, 0, 0, 0}},
+	{Name: "small_speakers", Gains: []float64{0, 2, 0, 0, 1, 2, 0}, HighPass: 100},
+	{Name: "vocal", Gains: []float64{-2, -2, 0, 2, 3, 1, 0}},
+	{Name: "late_night", Gains: []float64{4, 1, 0, -1, 0, 2, 3}},
+}
+
+// Effects are the settings of the music bus's processing chain. Tracks pass
+// through the high-pass filter, the bands, the shelves, the stereo to mono
+// switch and then the limiter.
+type Effects struct {
+	// Preset names the preset the bands were last set from; edits make it
+	// "custom"
+	Preset string   `json:"preset"`
+	Bands  []EQBand `json:"bands"`
+	// Bass and Treble are shelf gains in dB
+	Bass   float64 `json:"bass"`
+	Treble float64 `json:"treble"`
+	// HighPass removes bass the speakers cannot play, in Hz; 0 is off
+	HighPass float64 `json:"high_pass"`
+	// Mono sums the channels for zones with a single speaker
+	Mono    bool `json:"mono"`
+	Limiter bool `json:"limiter"`
+}
+
+// DefaultEffects is a flat, stereo chain with the limiter on
+func DefaultEffects() Effects {
+	return Effects{Preset: "flat", Bands: DefaultEQBands(), Limiter: true}
+}
+
+// ApplyPreset sets the bands and high-pass filter from a preset, keeping the
+// other settings
+func (e *Effects) ApplyPreset(name string) error {
+	index := slices.IndexFunc(EQPresets, func(p EQPreset) bool { return p.Name == name })
+	if index < 0 {
+		return fmt.Errorf("unknown equalizer preset: %q", name)
+	}
+
+	preset := EQPresets[index]
+	e.Bands = DefaultEQBands()
+	for i := range e.Bands {
+		e.Bands[i].Gain = preset.Gains[i]
+	}
+	e.HighPass = preset.HighPass
+	e.Preset = preset.Name
+	return nil
+}
+
+// Validate checks the effect settings
+func (e Effects) Validate() error {
+	nyquist := float64(speakerSampleRate) / 2
+	for _, band := range e.Bands {
+		if band.Frequency <= 0 || band.Frequency >= nyquist {
+			return fmt.Errorf("equalizer band frequency must be between 0 and %v Hz, got: %v", nyquist, band.Frequency)
+		}
+		if band.Q <= 0 {
+			return fmt.Errorf("equalizer band Q must be positive, got: %v", band.Q)
+		}
+		if math.Abs(band.Gain) > EQGainLimit {
+			return fmt.Errorf("equalizer band gain must be within ±%v dB, got: %v", EQGainLimit, band.Gain)
+		}
+	}
+	if math.Abs(e.Bass) > EQGainLimit || math.Abs(e.Treble) > EQGainLimit {
+		return fmt.Errorf("bass and treble must be within ±%v dB, got: %v and %v", EQGainLimit, e.Bass, e.Treble)
+	}
+	if e.HighPass < 0 || e.HighPass >= nyquist {
+		return fmt.Errorf("high-pass frequency must be between 0 and %v Hz, got: %v", nyquist, e.HighPass)
+	}
+	return nil
+}
+
+// filters builds the filter sections for the settings, leaving out those
+// that would do nothing
+func (e Effects) filters(rate beep.SampleRate) []biquad {
+	var filters []biquad
+	if e.HighPass > 0 {
+		filters = append(filters, highPassFilter(e.HighPass, rate))
+	}
+	for _, band := range e.Bands {
+		if band.Gain != 0 {
+			filters = append(filters, peakingFilter(band.Frequency, band.Gain, band.Q, rate))
+		}
+	}
+	if e.Bass != 0 {
+		filters = append(filters, shelfFilter(true, bassShelfFrequency, e.Bass, rate))
+	}
+	if e.Treble != 0 {
+		filters = append(filters, shelfFilter(false, trebleShelfFrequency, e.Treble, rate))
+	}
+	return filters
+}
+
+// Response returns the gain of the filters at a frequency in dB, as played
+// at the speaker rate
+func (e Effects) Response(frequency float64) float64 {
+	var gain float64
+	for _, filter := range e.filters(speakerSampleRate) {
+		gain += filter.response(frequency, speakerSampleRate)
+	}
+	return gain
+}
+
+// LoadEffects reads saved effect settings; a missing file gives the defaults
+func LoadEffects(path string) (Effects, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultEffects(), nil
+	}
+	if err != nil {
+		return DefaultEffects(), fmt.Errorf("failed to read audio effects %s: %w", path, err)
+	}
+
+	effects := DefaultEffects()
+	if err := json.Unmarshal(data, &effects); err != nil {
+		return DefaultEffects(), fmt.Errorf("failed to parse audio effects %s: %w", path, err)
+	}
+	if err := effects.Validate(); err != nil {
+		return DefaultEffects(), fmt.Errorf("invalid audio effects %s: %w", path, err)
+	}
+	return effects, nil
+}
+
+// equalizer runs the music through the effect filters and optionally sums
+// it to mono. It is only touched on the audio thread or with the speaker
+// locked.
+type equalizer struct {
+	Streamer beep.Streamer
+
+	filters []biquad
+	mono    bool
+}
+
+// Configure rebuilds the filters for new settings at a sample rate
+func (e *equalizer) Configure(effects Effects, rate beep.SampleRate) {
+	e.filters = effects.filters(rate)
+	e.mono = effects.Mono
+}
+
+func (e *equalizer) Stream(samples [][2]float64) (int, bool) {
+	n, ok := e.Streamer.Stream(samples)
+	if len(e.filters) == 0 && !e.mono {
+		return n, ok
+	}
+
+	for i := range samples[:n] {
+		for channel := range samples[i] {
+			x := samples[i][channel]
+			for f := range e.filters {
+				x = e.filters[f].process(channel, x)
+			}
+			samples[i][channel] = x
+		}
+		if e.mono {
+			sum := (samples[i][0] + samples[i][1]) / 2
+			samples[i] = [2]float64{sum, sum}
+		}
+	}
+	return n, ok
+}
+
+func (e *equalizer) Err() error {
+	return e.Streamer.Err()
+}
+
+// SetEffectsFile sets where effect settings are saved after every change;
+// empty keeps them in memory only
+func (am *AudioManager) SetEffectsFile(path string) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.effectsFile = path
+}
+
+// SetEffects replaces the music bus effect settings
+func (am *AudioManager) SetEffects(effects Effects) error {
+	if err := effects.Validate(); err != nil {
+		return err
+	}
+	effects.Bands = slices.Clone(effects.Bands)
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.effects = effects
+	speaker.Lock()
+	am.equalizer.Configure(effects, speakerSampleRate)
+	am.limiter.Enabled = effects.Limiter
+	speaker.Unlock()
+
+	am.saveEffectsLocked()
+	return nil
+}
+
+// GetEffects returns the music bus effect settings
+func (am *AudioManager) GetEffects() Effects {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+
+	effects := am.effects
+	effects.Bands = slices.Clone(effects.Bands)
+	return effects
+}
+
+// saveEffectsLocked writes the effect settings to the effects file.
+// Callers must hold am.mutex.
+func (am *AudioManager) saveEffectsLocked() {
+	if am.effectsFile == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(am.effects, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(am.effectsFile), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(am.effectsFile, data, 0o644)
+	}
+	if err != nil {
+		log.Printf("Failed to save audio effects %s: %v", am.effectsFile, err)
+	}
+}
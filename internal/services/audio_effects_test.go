@@ -0,0 +1,165 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+// sineStreamer plays an endless sine wave with a gain per channel
+type sineStreamer struct {
+	frequency float64
+	gains     [2]float64
+	sample    int
+}
+
+func (s *sineStreamer) Stream(samples [][2]float64) (int, bool) {
+	for i := range samples {
+		x := math.Sin(2 * math.Pi * s.frequency * float64(s.sample) / float64(speakerSampleRate))
+		samples[i] = [2]float64{x * s.gains[0], x * s.gains[1]}
+		s.sample++
+	}
+	return len(samples), true
+}
+
+func (s *sineStreamer) Err() error {
+	return nil
+}
+
+// newEffectsChain builds the equalizer and limiter of the music bus over a
+// source, as the audio manager does
+func newEffectsChain(source *sineStreamer, effects Effects) *limiter {
+	equalizer := &equalizer{Streamer: source}
+	equalizer.Configure(effects, speakerSampleRate)
+	chain := newLimiter(equalizer)
+	chain.Enabled = effects.Limiter
+	return chain
+}
+
+// streamSeconds streams from a chain in buffer sized chunks
+func streamSeconds(chain *limiter, seconds float64) [][2]float64 {
+	samples := make([][2]float64, int(seconds*float64(speakerSampleRate)))
+	for start := 0; start < len(samples); start += 512 {
+		chain.Stream(samples[start:min(start+512, len(samples))])
+	}
+	return samples
+}
+
+// measureGain plays a quiet sine through the chain and returns the gain of
+// the left channel in dB, skipping the filters' settling time
+func measureGain(effects Effects, frequency float64) float64 {
+	const amplitude = 0.1
+	chain := newEffectsChain(&sineStreamer{frequency: frequency, gains: [2]float64{amplitude, amplitude}}, effects)
+	samples := streamSeconds(chain, 1)[speakerSampleRate.N(250e6):]
+
+	var sum float64
+	for _, sample := range samples {
+		sum += sample[0] * sample[0]
+	}
+	rms := math.Sqrt(sum / float64(len(samples)))
+	return 20 * math.Log10(rms/(amplitude/math.Sqrt2))
+}
+
+func TestEqualizerMatchesResponse(t *testing.T) {
+	type setting struct {
+		name    string
+		effects Effects
+	}
+	var settings []setting
+	for _, preset := range EQPresets {
+		effects := DefaultEffects()
+		if err := effects.ApplyPreset(preset.Name); err != nil {
+			t.Fatalf("ApplyPreset %s: %v", preset.Name, err)
+		}
+		settings = append(settings, setting{preset.Name, effects})
+	}
+	shelves := DefaultEffects()
+	shelves.Bass, shelves.Treble = 6, -9
+	settings = append(settings, setting{"shelves", shelves})
+	highPass := DefaultEffects()
+	highPass.HighPass = 200
+	settings = append(settings, setting{"high-pass", highPass})
+
+	for _, s := range settings {
+		t.Run(s.name, func(t *testing.T) {
+			for _, frequency := range []float64{40, 100, 250, 1000, 2500, 6300, 12000} {
+				want := s.effects.Response(frequency)
+				if got := measureGain(s.effects, frequency); math.Abs(got-want) > 0.2 {
+					t.Errorf("gain at %v Hz = %.2f dB, Response says %.2f dB", frequency, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseOfShelvesAndHighPass(t *testing.T) {
+	effects := DefaultEffects()
+	effects.Bass, effects.Treble = 6, -9
+	for _, check := range []struct {
+		frequency, gain float64
+	}{
+		// The shelves reach their gain away from their corners
+		{bassShelfFrequency / 4, 6},
+		{trebleShelfFrequency * 3, -9},
+		// and are half way at them
+		{bassShelfFrequency, 3},
+		{trebleShelfFrequency, -4.5},
+	} {
+		if got := effects.Response(check.frequency); math.Abs(got-check.gain) > 0.6 {
+			t.Errorf("response at %v Hz = %.2f dB, want about %v dB", check.frequency, got, check.gain)
+		}
+	}
+
+	// The high-pass is 3 dB down at its corner and falls 12 dB per octave
+	// below it
+	highPass := DefaultEffects()
+	highPass.HighPass = 200
+	if got := highPass.Response(200); math.Abs(got+3) > 0.1 {
+		t.Errorf("high-pass response at its corner = %.2f dB, want -3 dB", got)
+	}
+	if got := highPass.Response(25) - highPass.Response(50); math.Abs(got+12) > 0.5 {
+		t.Errorf("high-pass slope = %.2f dB per octave, want -12 dB", got)
+	}
+}
+
+func TestMonoSumsChannels(t *testing.T) {
+	effects := DefaultEffects()
+	effects.Mono = true
+	chain := newEffectsChain(&sineStreamer{frequency: 1000, gains: [2]float64{0.4, -0.2}}, effects)
+
+	for i, sample := range streamSeconds(chain, 0.1) {
+		x := math.Sin(2 * math.Pi * 1000 * float64(i-len(chain.delay)) / float64(speakerSampleRate))
+		if i < len(chain.delay) {
+			x = 0
+		}
+		want := (0.4 - 0.2) / 2 * x
+		if math.Abs(sample[0]-want) > 1e-9 || math.Abs(sample[1]-want) > 1e-9 {
+			t.Fatalf("sample %d = %v, want both channels at %v", i, sample, want)
+		}
+	}
+}
+
+func TestLimiterStaysUnderCeiling(t *testing.T) {
+	effects := DefaultEffects()
+	if err := effects.ApplyPreset("late_night"); err != nil {
+		t.Fatalf("ApplyPreset: %v", err)
+	}
+	effects.Bass = EQGainLimit
+
+	// Loud low notes boosted by the bass, and a sine well over full scale
+	for _, source := range []*sineStreamer{
+		{frequency: 60, gains: [2]float64{0.9, 0.5}},
+		{frequency: 3000, gains: [2]float64{4, -4}},
+	} {
+		chain := newEffectsChain(source, effects)
+		var peak float64
+		for _, sample := range streamSeconds(chain, 2) {
+			peak = max(peak, math.Abs(sample[0]), math.Abs(sample[1]))
+		}
+		if peak > limiterCeiling {
+			t.Errorf("%v Hz at %v: peak %v over the ceiling %v", source.frequency, source.gains, peak, limiterCeiling)
+		}
+		if peak < limiterCeiling*0.9 {
+			t.Errorf("%v Hz at %v: peak %v, want the limiter near its ceiling", source.frequency, source.gains, peak)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+// AudioEventType identifies what changed in an AudioEvent
+type AudioEventType int
+
+const (
+	// TrackStarted is published when a newly loaded track begins playing
+	TrackStarted AudioEventType = iota
+	// TrackEnded is published when a track plays to its end
+	TrackEnded
+	// PositionChanged is published periodically while a track is playing
+	PositionChanged
+	// QueueChanged is published when the playlist is modified
+	QueueChanged
+	// MixerChanged is published when a bus level, mute or limit changes
+	MixerChanged
+)
+
+func (t AudioEventType) String() string {
+	switch t {
+	case TrackStarted:
+		return "TrackStarted"
+	case TrackEnded:
+		return "TrackEnded"
+	case PositionChanged:
+		return "PositionChanged"
+	case QueueChanged:
+		return "QueueChanged"
+	case MixerChanged:
+		return "MixerChanged"
+	default:
+		return "Unknown"
+	}
+}
+
+// AudioEvent reports a playback change together with the status after it
+type AudioEvent struct {
+	Type   AudioEventType
+	Status AudioStatus
+}
+
+// publish sends an event without blocking; events are dropped while the
+// channel is full. Queue and track changes also request a queue save.
+// Callers must hold am.mutex.
+func (am *AudioManager) publish(eventType AudioEventType) {
+	if am.closed {
+		return
+	}
+	if eventType != PositionChanged && eventType != MixerChanged {
+		am.requestQueueSaveLocked()
+	}
+
+	select {
+	case am.statusChan <- AudioEvent{Type: eventType, Status: am.statusLocked()}:
+	default:
+	}
+}
+
+// notify sends a now playing message without blocking. Callers must hold
+// am.mutex.
+func (am *AudioManager) notify(message string) {
+	if am.closed {
+		return
+	}
+
+	select {
+	case am.nowPlayingChan <- message:
+	default:
+	}
+}
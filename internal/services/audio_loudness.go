@@ -0,0 +1,187 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// NormalizationMode selects which ReplayGain is applied to music
+type NormalizationMode string
+
+const (
+	// NormalizeOff plays tracks at their mastered level
+	NormalizeOff NormalizationMode = "off"
+	// NormalizeTrack brings every track to the same loudness
+	NormalizeTrack NormalizationMode = "track"
+	// NormalizeAlbum keeps the level differences between tracks of an
+	// album, falling back to track gain for tracks without an album
+	NormalizeAlbum NormalizationMode = "album"
+)
+
+// ParseNormalizationMode parses a normalization mode name
+func ParseNormalizationMode(name string) (NormalizationMode, error) {
+	switch mode := NormalizationMode(name); mode {
+	case NormalizeOff, NormalizeTrack, NormalizeAlbum:
+		return mode, nil
+	}
+	return NormalizeOff, fmt.Errorf("unknown normalization mode: %q", name)
+}
+
+// Limiter settings: peaks are held under the ceiling by looking this far
+// ahead, and the gain recovers over the release time
+const (
+	limiterCeiling   = 0.944 // -0.5 dBFS
+	limiterLookahead = 5 * time.Millisecond
+	limiterRelease   = 150 * time.Millisecond
+)
+
+// limiter keeps the music under full scale after gain has been applied. It
+// delays the signal by a short lookahead so the gain can come down before a
+// peak arrives, and clamps any sample still over the ceiling, so it never
+// clips. It is only touched on the audio thread or with the speaker locked.
+type limiter struct {
+	Streamer beep.Streamer
+	Enabled  bool
+
+	delay [][2]float64
+	pos   int
+	// gain moves towards target, falling by step per sample and rising
+	// with the release coefficient
+	gain, target, step float64
+	release            float64
+	// hold keeps the target down until the peak that set it has passed
+	hold int
+}
+
+// newLimiter creates an enabled limiter at the speaker rate
+func newLimiter(streamer beep.Streamer) *limiter {
+	return &limiter{
+		Streamer: streamer,
+		Enabled:  true,
+		delay:    make([][2]float64, max(speakerSampleRate.N(limiterLookahead), 1)),
+		gain:     1,
+		target:   1,
+		release:  math.Exp(-1 / float64(speakerSampleRate.N(limiterRelease))),
+	}
+}
+
+func (l *limiter) Stream(samples [][2]float64) (int, bool) {
+	n, ok := l.Streamer.Stream(samples)
+	if !l.Enabled {
+		return n, ok
+	}
+
+	lookahead := len(l.delay)
+	for i := range samples[:n] {
+		in := samples[i]
+		peak := max(math.Abs(in[0]), math.Abs(in[1]))
+		if need := limiterCeiling / max(peak, limiterCeiling); need < l.target {
+			// Reach the new target by the time this sample comes out
+			l.target = need
+			l.step = max(l.step, (l.gain-need)/float64(lookahead))
+			l.hold = lookahead
+		} else if l.hold > 0 {
+			l.hold--
+		} else {
+			l.target = 1
+		}
+
+		if l.gain > l.target {
+			l.gain = max(l.gain-l.step, l.target)
+		} else {
+			l.step = 0
+			l.gain = l.target - (l.target-l.gain)*l.release
+		}
+
+		out := l.delay[l.pos]
+		l.delay[l.pos] = in
+		l.pos = (l.pos + 1) % lookahead
+
+		// Clamp whatever the envelope missed rather than clip
+		gain := l.gain
+		if outPeak := max(math.Abs(out[0]), math.Abs(out[1])); outPeak*gain > limiterCeiling {
+			gain = limiterCeiling / outPeak
+		}
+		samples[i] = [2]float64{underCeiling(out[0] * gain), underCeiling(out[1] * gain)}
+	}
+	return n, ok
+}
+
+// underCeiling clamps a sample to the limiter ceiling, which scaling by the
+// gain can overshoot by a rounding error
+func underCeiling(x float64) float64 {
+	return math.Copysign(min(math.Abs(x), limiterCeiling), x)
+}
+
+func (l *limiter) Err() error {
+	return l.Streamer.Err()
+}
+
+// SetNormalization sets which ReplayGain is applied to music. preamp is
+// added to every gain, in dB. The tracks on the deck are adjusted at once.
+func (am *AudioManager) SetNormalization(mode NormalizationMode, preamp float64) error {
+	if _, err := ParseNormalizationMode(string(mode)); err != nil {
+		return err
+	}
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.normalization = mode
+	am.preamp = preamp
+	am.applyGainsLocked()
+	return nil
+}
+
+// GetNormalization returns the normalization mode
+func (am *AudioManager) GetNormalization() NormalizationMode {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+
+	return am.normalization
+}
+
+// SetGainLookup sets where track and album gains come from, usually the
+// music library
+func (am *AudioManager) SetGainLookup(lookup GainLookup) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.gains = lookup
+	am.applyGainsLocked()
+}
+
+// applyGainsLocked updates the gain of the tracks on the deck. Callers must
+// hold am.mutex.
+func (am *AudioManager) applyGainsLocked() {
+	speaker.Lock()
+	defer speaker.Unlock()
+
+	for _, track := range []*deckTrack{am.deck.current, am.deck.next, am.deck.outgoing} {
+		if track != nil {
+			track.gain = am.trackGainLocked(track.path)
+		}
+	}
+}
+
+// trackGainLocked returns the linear gain normalization applies to a file.
+// Callers must hold am.mutex.
+func (am *AudioManager) trackGainLocked(path string) float64 {
+	if am.normalization == NormalizeOff || am.gains == nil {
+		return 1
+	}
+
+	gains := am.gains(path)
+	gain := gains.Track
+	if am.normalization == NormalizeAlbum && gains.Album != nil || gain == nil {
+		gain = gains.Album
+	}
+	if gain == nil {
+		return 1
+	}
+	return math.Pow(10, (gain.Gain+am.preamp)/20)
+}
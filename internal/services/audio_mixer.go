@@ -0,0 +1,288 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/faiface/beep/effects"
+	"github.com/faiface/beep/speaker"
+)
+
+// Bus is a channel of the mixer
+type Bus string
+
+const (
+	// BusMaster scales every other bus
+	BusMaster Bus = "master"
+	// BusMusic carries the jukebox
+	BusMusic Bus = "music"
+	// BusSFX carries interface sounds and sound effects
+	BusSFX Bus = "sfx"
+	// BusAnnouncement carries announcements
+	BusAnnouncement Bus = "announcement"
+)
+
+// Buses lists the mixer buses in display order
+var Buses = []Bus{BusMaster, BusMusic, BusSFX, BusAnnouncement}
+
+// FaderRange is the span of a fader in dB; the bottom of the travel is
+// silence rather than -FaderRange
+const FaderRange = 60.0
+
+// FaderStep is how far one press moves a fader
+const FaderStep = 0.05
+
+// FaderDecibels converts a fader position from 0.0 to 1.0 to dB, evenly
+// spread over FaderRange so each step sounds the same size. Position 0 is
+// negative infinity.
+func FaderDecibels(level float64) float64 {
+	if level <= 0 {
+		return math.Inf(-1)
+	}
+	return (min(level, 1) - 1) * FaderRange
+}
+
+// FormatLevel describes a fader position in dB, such as "-12 dB", "-∞ dB"
+// or "muted"
+func FormatLevel(level float64, muted bool) string {
+	switch db := FaderDecibels(level); {
+	case muted:
+		return "muted"
+	case math.IsInf(db, -1):
+		return "-∞ dB"
+	default:
+		return fmt.Sprintf("%.0f dB", db)
+	}
+}
+
+// BusLevel is the setting of one mixer bus
+type BusLevel struct {
+	// Level is the fader position from 0.0 (silent) to 1.0 (0 dB)
+	Level float64 `json:"level"`
+	Muted bool    `json:"muted,omitempty"`
+	// Min and Max limit the fader, for example to keep announcements
+	// audible or cap the music
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// clamp returns the level held within the bus limits
+func (b BusLevel) clamp(level float64) float64 {
+	return min(max(roundLevel(level), b.Min), b.Max)
+}
+
+// roundLevel drops the error that builds up from stepping a fader
+func roundLevel(level float64) float64 {
+	return math.Round(level*1000) / 1000
+}
+
+// Validate checks the bus limits and level
+func (b BusLevel) Validate() error {
+	if b.Min < 0 || b.Max > 1 || b.Min > b.Max {
+		return fmt.Errorf("bus limits must satisfy 0 <= min <= max <= 1, got: %v to %v", b.Min, b.Max)
+	}
+	if b.Level < b.Min || b.Level > b.Max {
+		return fmt.Errorf("bus level %v is outside its limits %v to %v", b.Level, b.Min, b.Max)
+	}
+	return nil
+}
+
+// MixerLevels are the settings of every bus
+type MixerLevels map[Bus]BusLevel
+
+// DefaultMixerLevels sets every bus to full level with no limits
+func DefaultMixerLevels() MixerLevels {
+	levels := make(MixerLevels, len(Buses))
+	for _, bus := range Buses {
+		levels[bus] = BusLevel{Level: 1, Max: 1}
+	}
+	return levels
+}
+
+// Gain returns the linear gain a bus is played at, scaled by the master
+// bus; it is 0 when either is muted or at the bottom of its travel
+func (m MixerLevels) Gain(bus Bus) float64 {
+	gain := m.busGain(bus)
+	if bus != BusMaster {
+		gain *= m.busGain(BusMaster)
+	}
+	return gain
+}
+
+// busGain returns the linear gain of one bus's own fader
+func (m MixerLevels) busGain(bus Bus) float64 {
+	level := m[bus]
+	if level.Muted {
+		return 0
+	}
+	return math.Pow(10, FaderDecibels(level.Level)/20)
+}
+
+// clone copies the levels
+func (m MixerLevels) clone() MixerLevels {
+	levels := make(MixerLevels, len(m))
+	for bus, level := range m {
+		levels[bus] = level
+	}
+	return levels
+}
+
+// LoadMixerLevels reads saved mixer levels over the defaults. A missing file
+// gives the defaults; buses with invalid settings keep theirs.
+func LoadMixerLevels(path string) (MixerLevels, error) {
+	levels := DefaultMixerLevels()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return levels, nil
+	}
+	if err != nil {
+		return levels, fmt.Errorf("failed to read mixer levels %s: %w", path, err)
+	}
+
+	var saved MixerLevels
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return levels, fmt.Errorf("failed to parse mixer levels %s: %w", path, err)
+	}
+	var errs []error
+	for bus, level := range saved {
+		if _, ok := levels[bus]; !ok {
+			errs = append(errs, fmt.Errorf("unknown bus: %q", bus))
+			continue
+		}
+		if err := level.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", bus, err))
+			continue
+		}
+		levels[bus] = level
+	}
+	return levels, errors.Join(errs...)
+}
+
+// SetMixerFile sets where mixer levels are saved after every change; empty
+// keeps them in memory only
+func (am *AudioManager) SetMixerFile(path string) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.mixerFile = path
+}
+
+// SetMixer replaces every bus setting
+func (am *AudioManager) SetMixer(levels MixerLevels) error {
+	for _, bus := range Buses {
+		level, ok := levels[bus]
+		if !ok {
+			return fmt.Errorf("no setting for the %s bus", bus)
+		}
+		if err := level.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", bus, err)
+		}
+	}
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.mixer = levels.clone()
+	am.applyMixerLocked()
+	return nil
+}
+
+// GetMixer returns the setting of every bus
+func (am *AudioManager) GetMixer() MixerLevels {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+
+	return am.mixer.clone()
+}
+
+// SetBusLevel moves a bus fader, within the bus limits
+func (am *AudioManager) SetBusLevel(bus Bus, level float64) error {
+	return am.updateBus(bus, func(b *BusLevel) error {
+		b.Level = b.clamp(level)
+		return nil
+	})
+}
+
+// SetBusMuted mutes or unmutes a bus, leaving its fader where it is
+func (am *AudioManager) SetBusMuted(bus Bus, muted bool) error {
+	return am.updateBus(bus, func(b *BusLevel) error {
+		b.Muted = muted
+		return nil
+	})
+}
+
+// SetBusLimits limits how far a bus fader can move, pulling the fader
+// inside the new limits
+func (am *AudioManager) SetBusLimits(bus Bus, low, high float64) error {
+	return am.updateBus(bus, func(b *BusLevel) error {
+		limited := BusLevel{Level: b.Level, Muted: b.Muted, Min: roundLevel(low), Max: roundLevel(high)}
+		limited.Level = limited.clamp(limited.Level)
+		if err := limited.Validate(); err != nil {
+			return err
+		}
+		*b = limited
+		return nil
+	})
+}
+
+// updateBus changes one bus and applies and saves the mixer
+func (am *AudioManager) updateBus(bus Bus, change func(*BusLevel) error) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	level, ok := am.mixer[bus]
+	if !ok {
+		return fmt.Errorf("unknown bus: %q", bus)
+	}
+	if err := change(&level); err != nil {
+		return err
+	}
+	am.mixer[bus] = level
+	am.applyMixerLocked()
+	return nil
+}
+
+// applyMixerLocked sets the bus volumes, saves the levels and announces
+// the change. Callers must hold am.mutex.
+func (am *AudioManager) applyMixerLocked() {
+	speaker.Lock()
+	setBusVolume(am.musicVolume, am.mixer.Gain(BusMusic))
+	setBusVolume(am.sfxVolume, am.mixer.Gain(BusSFX))
+	setBusVolume(am.announcementVolume, am.mixer.Gain(BusAnnouncement))
+	speaker.Unlock()
+
+	am.saveMixerLocked()
+	am.publish(MixerChanged)
+}
+
+// setBusVolume sets a bus's volume effect to a linear gain
+func setBusVolume(volume *effects.Volume, gain float64) {
+	volume.Silent = gain == 0
+	if gain > 0 {
+		volume.Volume = math.Log10(gain)
+	}
+}
+
+// saveMixerLocked writes the mixer levels to the mixer file. Callers must
+// hold am.mutex.
+func (am *AudioManager) saveMixerLocked() {
+	if am.mixerFile == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(am.mixer, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(am.mixerFile), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(am.mixerFile, data, 0o644)
+	}
+	if err != nil {
+		log.Printf("Failed to save mixer levels %s: %v", am.mixerFile, err)
+	}
+}
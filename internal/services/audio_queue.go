@@ -0,0 +1,176 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// queueState is the playlist and playback position saved between runs
+type queueState struct {
+	Tracks     []string `json:"tracks"`
+	Index      int      `json:"index"`
+	PositionMS int64    `json:"position_ms"`
+	Order      []int    `json:"order,omitempty"`
+}
+
+// SetQueueFile sets where the playlist and position are saved. The file is
+// rewritten shortly after the playlist or the current track changes, and on
+// Close.
+func (am *AudioManager) SetQueueFile(path string) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.queueFile = path
+}
+
+// RestoreQueue reloads the playlist saved in the queue file and cues the
+// track that was current at its saved position, paused. The saved shuffle
+// order is kept when it still covers the playlist, so a restart does not
+// reshuffle. A missing file leaves the playlist empty.
+func (am *AudioManager) RestoreQueue() error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if am.queueFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(am.queueFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read queue %s: %w", am.queueFile, err)
+	}
+
+	var state queueState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse queue %s: %w", am.queueFile, err)
+	}
+	if len(state.Tracks) == 0 {
+		return nil
+	}
+
+	am.playlist = state.Tracks
+	am.currentIndex = min(max(state.Index, 0), len(am.playlist)-1)
+	am.order = nil
+	if am.shuffle != ShuffleOff && isPermutation(state.Order, len(am.playlist)) {
+		am.order = state.Order
+	} else {
+		am.reshuffleLocked()
+	}
+	defer am.publish(QueueChanged)
+
+	if err := am.loadTrackLocked(am.playlist[am.currentIndex]); err != nil {
+		return fmt.Errorf("failed to restore current track: %w", err)
+	}
+	am.queueNextLocked()
+
+	if position := time.Duration(state.PositionMS) * time.Millisecond; position > 0 {
+		if err := am.seekLocked(position); err != nil {
+			return fmt.Errorf("failed to restore position: %w", err)
+		}
+	}
+	return nil
+}
+
+// queueSaveDelay batches the queue saves requested by a burst of changes
+const queueSaveDelay = 500 * time.Millisecond
+
+// requestQueueSaveLocked asks for the queue to be saved soon, without
+// blocking. Callers must hold am.mutex.
+func (am *AudioManager) requestQueueSaveLocked() {
+	select {
+	case am.queueSaves <- struct{}{}:
+	default:
+	}
+}
+
+// saveQueueLoop saves the queue after changes settle, until the manager is
+// closed
+func (am *AudioManager) saveQueueLoop() {
+	for {
+		select {
+		case <-am.done:
+			return
+		case <-am.queueSaves:
+		}
+
+		select {
+		case <-am.done:
+			return
+		case <-time.After(queueSaveDelay):
+		}
+
+		am.mutex.Lock()
+		path, state, seq := am.queueStateLocked()
+		am.mutex.Unlock()
+		am.writeQueue(path, state, seq)
+	}
+}
+
+// saveQueueLocked writes the playlist and position to the queue file at
+// once. Callers must hold am.mutex.
+func (am *AudioManager) saveQueueLocked() {
+	am.writeQueue(am.queueStateLocked())
+}
+
+// queueStateLocked copies the state to save and numbers it. Callers must
+// hold am.mutex.
+func (am *AudioManager) queueStateLocked() (string, queueState, uint64) {
+	state := queueState{
+		Tracks:     slices.Clone(am.playlist),
+		Index:      am.currentIndex,
+		PositionMS: am.positionLocked().Milliseconds(),
+	}
+	if am.shuffle != ShuffleOff {
+		state.Order = slices.Clone(am.order)
+	}
+	am.queueSeq++
+	return am.queueFile, state, am.queueSeq
+}
+
+// writeQueue writes a queue state to path unless a newer state was written
+func (am *AudioManager) writeQueue(path string, state queueState, seq uint64) {
+	if path == "" {
+		return
+	}
+
+	am.queueWriteMu.Lock()
+	defer am.queueWriteMu.Unlock()
+	if seq <= am.queueWritten {
+		return
+	}
+	am.queueWritten = seq
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		log.Printf("Failed to save queue %s: %v", path, err)
+	}
+}
+
+// isPermutation reports whether order lists every index below count once
+func isPermutation(order []int, count int) bool {
+	if len(order) != count {
+		return false
+	}
+	sorted := slices.Sorted(slices.Values(order))
+	for i, index := range sorted {
+		if index != i {
+			return false
+		}
+	}
+	return true
+}
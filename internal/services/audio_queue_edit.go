@@ -0,0 +1,279 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+)
+
+// queueUndoLimit is how many queue edits can be undone
+const queueUndoLimit = 50
+
+// queueSnapshot is the queue as it was before an edit
+type queueSnapshot struct {
+	playlist     []string
+	order        []int
+	currentIndex int
+	currentTrack string
+}
+
+// MoveInPlaylist moves a playlist entry to another position; the current
+// track stays current wherever it ends up
+func (am *AudioManager) MoveInPlaylist(from, to int) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if err := am.checkIndexLocked(from); err != nil {
+		return err
+	}
+	to = min(max(to, 0), len(am.playlist)-1)
+	if from == to {
+		return nil
+	}
+
+	am.pushUndoLocked()
+	picks := slices.Delete(am.identityLocked(), from, from+1)
+	am.rearrangeLocked(slices.Insert(picks, to, from))
+	am.queueNextLocked()
+	am.publish(QueueChanged)
+	return nil
+}
+
+// MoveToNext moves a playlist entry so it plays after the current track
+func (am *AudioManager) MoveToNext(index int) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if err := am.checkIndexLocked(index); err != nil {
+		return err
+	}
+	if index == am.currentIndex {
+		return nil
+	}
+
+	am.pushUndoLocked()
+	picks := slices.Delete(am.identityLocked(), index, index+1)
+	at := slices.Index(picks, am.currentIndex) + 1
+	am.moveToLocked(picks, at, index)
+	return nil
+}
+
+// MoveAfterAlbum moves a playlist entry so it plays once the entries after
+// the current track from the same album have played. While shuffling, those
+// are the entries that follow it in the shuffle order.
+func (am *AudioManager) MoveAfterAlbum(index int) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if err := am.checkIndexLocked(index); err != nil {
+		return err
+	}
+	if index == am.currentIndex {
+		return nil
+	}
+
+	grouping := am.grouping
+	if grouping == nil {
+		grouping = GroupByDirectory
+	}
+
+	_, album := grouping(am.currentTrack)
+	sameAlbum := func(track string) bool {
+		_, other := grouping(track)
+		return album != "" && other == album
+	}
+
+	am.pushUndoLocked()
+	picks := slices.Delete(am.identityLocked(), index, index+1)
+	at := slices.Index(picks, am.currentIndex) + 1
+	for at < len(picks) && sameAlbum(am.playlist[picks[at]]) {
+		at++
+	}
+	am.rearrangeLocked(slices.Insert(picks, at, index))
+	am.playAfterInOrderLocked(at, func(entry int) bool { return sameAlbum(am.playlist[entry]) })
+	am.queueNextLocked()
+	am.publish(QueueChanged)
+	return nil
+}
+
+// moveToLocked inserts a playlist entry into picks at position and applies
+// the arrangement, moving the entry up the shuffle order too. Callers must
+// hold am.mutex.
+func (am *AudioManager) moveToLocked(picks []int, position, index int) {
+	am.rearrangeLocked(slices.Insert(picks, position, index))
+	am.playNextInOrderLocked(position)
+	am.queueNextLocked()
+	am.publish(QueueChanged)
+}
+
+// RemoveFromPlaylist removes a playlist entry other than the current one
+func (am *AudioManager) RemoveFromPlaylist(index int) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if err := am.checkIndexLocked(index); err != nil {
+		return err
+	}
+	if index == am.currentIndex && am.playingFromPlaylistLocked() {
+		return fmt.Errorf("cannot remove the current track")
+	}
+
+	am.pushUndoLocked()
+	am.rearrangeLocked(slices.Delete(am.identityLocked(), index, index+1))
+	am.queueNextLocked()
+	am.publish(QueueChanged)
+	return nil
+}
+
+// ClearPlaylist empties the playlist, keeping the current track as its only
+// entry when it was played from the playlist
+func (am *AudioManager) ClearPlaylist() {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if len(am.playlist) == 0 {
+		return
+	}
+
+	am.pushUndoLocked()
+	var picks []int
+	if am.playingFromPlaylistLocked() {
+		picks = []int{am.currentIndex}
+	}
+	am.rearrangeLocked(picks)
+	am.queueNextLocked()
+	am.publish(QueueChanged)
+}
+
+// RemoveDuplicates drops repeated playlist entries, keeping the first of
+// each track, or the current entry for the current track. It returns how
+// many entries were removed.
+func (am *AudioManager) RemoveDuplicates() int {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	keep := make(map[string]int, len(am.playlist))
+	if am.playingFromPlaylistLocked() {
+		keep[am.currentTrack] = am.currentIndex
+	}
+	for index, track := range am.playlist {
+		if _, ok := keep[track]; !ok {
+			keep[track] = index
+		}
+	}
+
+	picks := make([]int, 0, len(keep))
+	for index, track := range am.playlist {
+		if keep[track] == index {
+			picks = append(picks, index)
+		}
+	}
+
+	removed := len(am.playlist) - len(picks)
+	if removed > 0 {
+		am.pushUndoLocked()
+		am.rearrangeLocked(picks)
+		am.queueNextLocked()
+		am.publish(QueueChanged)
+	}
+	return removed
+}
+
+// UndoPlaylistEdit restores the playlist as it was before the last edit.
+// Playback carries on; if the current track has changed since, it stays
+// current where it appears in the restored playlist.
+func (am *AudioManager) UndoPlaylistEdit() error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if len(am.undo) == 0 {
+		return fmt.Errorf("nothing to undo")
+	}
+
+	snapshot := am.undo[len(am.undo)-1]
+	am.undo = am.undo[:len(am.undo)-1]
+
+	am.playlist = snapshot.playlist
+	am.order = snapshot.order
+	am.currentIndex = snapshot.currentIndex
+	if snapshot.currentTrack != am.currentTrack {
+		am.currentIndex = max(slices.Index(am.playlist, am.currentTrack), 0)
+		am.order = nil
+		am.reshuffleLocked()
+	}
+	am.queueNextLocked()
+	am.publish(QueueChanged)
+	return nil
+}
+
+// CanUndoPlaylistEdit reports whether there is a playlist edit to undo
+func (am *AudioManager) CanUndoPlaylistEdit() bool {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+
+	return len(am.undo) > 0
+}
+
+// pushUndoLocked records the queue before an edit. Callers must hold
+// am.mutex.
+func (am *AudioManager) pushUndoLocked() {
+	am.undo = append(am.undo, queueSnapshot{
+		playlist:     slices.Clone(am.playlist),
+		order:        slices.Clone(am.order),
+		currentIndex: am.currentIndex,
+		currentTrack: am.currentTrack,
+	})
+	if len(am.undo) > queueUndoLimit {
+		am.undo = slices.Delete(am.undo, 0, len(am.undo)-queueUndoLimit)
+	}
+}
+
+// rearrangeLocked replaces the playlist with the entries at picks, in that
+// order, carrying the current index and the shuffle order along. Callers
+// must hold am.mutex and queue the following track afterwards.
+func (am *AudioManager) rearrangeLocked(picks []int) {
+	moved := make(map[int]int, len(picks))
+	playlist := make([]string, len(picks))
+	for position, index := range picks {
+		playlist[position] = am.playlist[index]
+		moved[index] = position
+	}
+
+	order := make([]int, 0, len(picks))
+	for _, index := range am.order {
+		if position, ok := moved[index]; ok {
+			order = append(order, position)
+		}
+	}
+
+	am.playlist = playlist
+	am.currentIndex = moved[am.currentIndex]
+	am.order = order
+	if am.shuffle != ShuffleOff && len(am.order) != len(am.playlist) {
+		am.order = nil
+		am.reshuffleLocked()
+	}
+}
+
+// identityLocked returns the playlist indices in order. Callers must hold
+// am.mutex.
+func (am *AudioManager) identityLocked() []int {
+	picks := make([]int, len(am.playlist))
+	for i := range picks {
+		picks[i] = i
+	}
+	return picks
+}
+
+// checkIndexLocked validates a playlist index. Callers must hold am.mutex.
+func (am *AudioManager) checkIndexLocked(index int) error {
+	if index < 0 || index >= len(am.playlist) {
+		return fmt.Errorf("no playlist entry %d", index+1)
+	}
+	return nil
+}
+
+// playingFromPlaylistLocked reports whether the loaded track is the current
+// playlist entry. Callers must hold am.mutex.
+func (am *AudioManager) playingFromPlaylistLocked() bool {
+	return am.track != nil && am.currentIndex < len(am.playlist) && am.playlist[am.currentIndex] == am.currentTrack
+}
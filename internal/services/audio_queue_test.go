@@ -0,0 +1,154 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+// writeSilentTracks writes count short silent WAV files and returns their paths
+func writeSilentTracks(t *testing.T, count int) []string {
+	t.Helper()
+
+	format := beep.Format{SampleRate: speakerSampleRate, NumChannels: 2, Precision: 2}
+	tracks := make([]string, count)
+	for i := range tracks {
+		tracks[i] = filepath.Join(t.TempDir(), fmt.Sprintf("track%d.wav", i))
+		file, err := os.Create(tracks[i])
+		if err != nil {
+			t.Fatalf("create track: %v", err)
+		}
+		if err := wav.Encode(file, beep.Silence(speakerSampleRate.N(100e6)), format); err != nil {
+			t.Fatalf("encode track: %v", err)
+		}
+		file.Close()
+	}
+	return tracks
+}
+
+// restoreShuffledQueue starts a shuffled manager on the queue file at path
+func restoreShuffledQueue(t *testing.T, path string) *AudioManager {
+	t.Helper()
+
+	am := NewAudioManager()
+	t.Cleanup(func() { am.Close() })
+	am.SetShuffleMode(ShuffleRandom)
+	am.SetQueueFile(path)
+	if err := am.RestoreQueue(); err != nil {
+		t.Fatalf("RestoreQueue: %v", err)
+	}
+	return am
+}
+
+func TestRestoreQueueKeepsShuffleOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	tracks := writeSilentTracks(t, 8)
+
+	am := NewAudioManager()
+	am.SetShuffleMode(ShuffleRandom)
+	am.SetQueueFile(path)
+	am.SetPlaylist(tracks)
+	saved := slices.Clone(am.order)
+	am.Close()
+
+	restored := restoreShuffledQueue(t, path)
+	if !slices.Equal(restored.order, saved) {
+		t.Errorf("restored order = %v, want the saved %v", restored.order, saved)
+	}
+}
+
+func TestRestoreQueueReshufflesStaleOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	tracks := writeSilentTracks(t, 4)
+
+	// An order saved for a longer queue no longer fits
+	data := fmt.Sprintf(`{"tracks": [%q, %q, %q, %q], "index": 2, "order": [4, 2, 0, 1, 3]}`,
+		tracks[0], tracks[1], tracks[2], tracks[3])
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write queue: %v", err)
+	}
+
+	restored := restoreShuffledQueue(t, path)
+	if !isPermutation(restored.order, len(tracks)) || restored.order[0] != 2 {
+		t.Errorf("restored order = %v, want a new permutation starting at the current track", restored.order)
+	}
+}
+
+func TestQueueSavesAreBatched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	tracks := writeSilentTracks(t, 3)
+
+	am := NewAudioManager()
+	t.Cleanup(func() { am.Close() })
+	am.SetQueueFile(path)
+	am.SetPlaylist(tracks)
+	am.SetPlaylist(tracks[:2])
+
+	// Changes are saved off the playback path, not as they happen
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("queue saved as the playlist changed: %v", err)
+	}
+
+	deadline := time.Now().Add(queueSaveDelay + time.Second)
+	for {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var state queueState
+			if err := json.Unmarshal(data, &state); err != nil {
+				t.Fatalf("parse queue: %v", err)
+			}
+			if !slices.Equal(state.Tracks, tracks[:2]) {
+				t.Errorf("saved tracks = %v, want the latest playlist %v", state.Tracks, tracks[:2])
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue not saved %v after the changes", queueSaveDelay+time.Second)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestMoveAfterAlbumFollowsShuffleOrder(t *testing.T) {
+	tracks := writeSilentTracks(t, 6)
+	albums := map[string]string{
+		tracks[0]: "A", tracks[1]: "A", tracks[2]: "A",
+		tracks[3]: "B", tracks[4]: "B",
+		tracks[5]: "C",
+	}
+
+	am := NewAudioManager()
+	t.Cleanup(func() { am.Close() })
+	am.SetTrackGrouping(func(path string) (string, string) { return "", albums[path] })
+	am.SetShuffleMode(ShuffleRandom)
+	am.SetPlaylist(tracks)
+
+	// Only the next shuffled track shares the current track's album
+	am.mutex.Lock()
+	am.currentIndex, am.currentTrack = 0, tracks[0]
+	am.order = []int{0, 1, 3, 2, 4, 5}
+	am.mutex.Unlock()
+
+	if err := am.MoveAfterAlbum(5); err != nil {
+		t.Fatalf("MoveAfterAlbum: %v", err)
+	}
+
+	am.mutex.RLock()
+	var played []string
+	for _, index := range am.order {
+		played = append(played, am.playlist[index])
+	}
+	am.mutex.RUnlock()
+	want := []string{tracks[0], tracks[1], tracks[5], tracks[3], tracks[2], tracks[4]}
+	if !slices.Equal(played, want) {
+		t.Errorf("play order = %v, want %v", played, want)
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// SoundBank holds sound effects decoded into memory at the speaker's sample
+// rate, so short UI sounds start without touching the disk
+type SoundBank struct {
+	mu      sync.Mutex
+	buffers map[string]*beep.Buffer
+}
+
+// NewSoundBank creates an empty sound bank
+func NewSoundBank() *SoundBank {
+	return &SoundBank{buffers: make(map[string]*beep.Buffer)}
+}
+
+// Preload decodes files into the bank, skipping those already loaded. Every
+// file is tried; the errors of those that fail are returned together.
+func (b *SoundBank) Preload(paths ...string) error {
+	var errs []error
+	for _, path := range paths {
+		if _, err := b.Buffer(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Buffer returns the decoded sound for a file, loading it on first use
+func (b *SoundBank) Buffer(path string) (*beep.Buffer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if buffer, ok := b.buffers[path]; ok {
+		return buffer, nil
+	}
+
+	streamer, format, err := AudioDecoders.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sound %s: %w", path, err)
+	}
+	defer streamer.Close()
+
+	buffer := beep.NewBuffer(beep.Format{SampleRate: speakerSampleRate, NumChannels: 2, Precision: 2})
+	buffer.Append(resample(streamer, format))
+	if err := streamer.Err(); err != nil {
+		return nil, fmt.Errorf("failed to decode sound %s: %w", path, err)
+	}
+
+	b.buffers[path] = buffer
+	return buffer, nil
+}
+
+// UISound is a user interface event that a sound theme can give a sound
+type UISound string
+
+const (
+	// UINavigate is moving between screens or items
+	UINavigate UISound = "navigate"
+	// UISelect is choosing an item or opening a dialog
+	UISelect UISound = "select"
+	// UIBack is leaving a screen or cancelling a dialog
+	UIBack UISound = "back"
+	// UIError is a failed action
+	UIError UISound = "error"
+	// UISuccess is a completed action
+	UISuccess UISound = "success"
+	// UIOrderReady calls staff to the pass; it plays as an announcement,
+	// ducking the music
+	UIOrderReady UISound = "order_ready"
+)
+
+// UISounds lists the events a sound theme can map
+var UISounds = []UISound{UINavigate, UISelect, UIBack, UIError, UISuccess, UIOrderReady}
+
+// SoundTheme maps UI events to sound files
+type SoundTheme struct {
+	Name string `json:"name"`
+	// Sounds gives a file per event; relative paths are resolved against
+	// the theme file's directory
+	Sounds map[UISound]string `json:"sounds"`
+}
+
+// LoadSoundTheme reads a sound theme file
+func LoadSoundTheme(path string) (*SoundTheme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sound theme %s: %w", path, err)
+	}
+
+	var theme SoundTheme
+	if err := json.Unmarshal(data, &theme); err != nil {
+		return nil, fmt.Errorf("failed to parse sound theme %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for event, file := range theme.Sounds {
+		if !filepath.IsAbs(file) {
+			theme.Sounds[event] = filepath.Join(dir, file)
+		}
+	}
+	return &theme, nil
+}
+
+// SetSoundTheme switches the sounds played for UI events, preloading them.
+// Sounds that fail to load are reported in the returned error and dropped
+// from the theme, so those events stay silent; a nil theme silences the UI.
+func (am *AudioManager) SetSoundTheme(theme *SoundTheme) error {
+	var errs []error
+	if theme != nil {
+		loaded := &SoundTheme{Name: theme.Name, Sounds: make(map[UISound]string, len(theme.Sounds))}
+		for event, file := range theme.Sounds {
+			if _, err := am.sounds.Buffer(file); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			loaded.Sounds[event] = file
+		}
+		theme = loaded
+	}
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.soundTheme = theme
+	return errors.Join(errs...)
+}
+
+// PlayUISound plays the sound the theme gives an event. Events without a
+// sound are silently ignored.
+func (am *AudioManager) PlayUISound(event UISound) error {
+	am.mutex.RLock()
+	theme := am.soundTheme
+	am.mutex.RUnlock()
+
+	if theme == nil {
+		return nil
+	}
+	file, ok := theme.Sounds[event]
+	if !ok {
+		return nil
+	}
+
+	if event == UIOrderReady {
+		return am.PlayAnnouncement(file)
+	}
+	return am.playSound(file)
+}
+
+// PlaySFX plays a sound effect from the sound effects directory
+func (am *AudioManager) PlaySFX(filename string) error {
+	am.mutex.RLock()
+	path := filepath.Join(am.sfxDirectory, filename)
+	am.mutex.RUnlock()
+
+	return am.playSound(path)
+}
+
+// playSound plays a sound from the bank on the sound effects bus, over
+// anything already playing on it
+func (am *AudioManager) playSound(path string) error {
+	buffer, err := am.sounds.Buffer(path)
+	if err != nil {
+		return err
+	}
+
+	speaker.Lock()
+	am.sfxMixer.Add(buffer.Streamer(0, buffer.Len()))
+	speaker.Unlock()
+	return nil
+}
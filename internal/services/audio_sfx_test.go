@@ -0,0 +1,42 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSoundThemeDropsSoundsThatFailToLoad(t *testing.T) {
+	good := writeSilentTracks(t, 1)[0]
+	missing := filepath.Join(t.TempDir(), "missing.wav")
+
+	am := NewAudioManager()
+	t.Cleanup(func() { am.Close() })
+	err := am.SetSoundTheme(&SoundTheme{Sounds: map[UISound]string{
+		UISelect: good,
+		UIError:  missing,
+	}})
+	if err == nil {
+		t.Fatal("SetSoundTheme succeeded, want the missing sound reported")
+	}
+
+	// The failed event stays silent without going back to disk, even once
+	// the file turns up
+	if err := os.Rename(good, missing); err != nil {
+		t.Fatalf("move sound: %v", err)
+	}
+	if err := am.PlayUISound(UIError); err != nil {
+		t.Errorf("PlayUISound for the failed sound = %v, want silence", err)
+	}
+	am.sounds.mu.Lock()
+	_, loaded := am.sounds.buffers[missing]
+	am.sounds.mu.Unlock()
+	if loaded {
+		t.Error("the failed sound was loaded when its event fired")
+	}
+
+	// The sound that loaded plays from the bank
+	if err := am.PlayUISound(UISelect); err != nil {
+		t.Errorf("PlayUISound for the loaded sound: %v", err)
+	}
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ShuffleMode selects the order the playlist is played in
+type ShuffleMode int
+
+const (
+	// ShuffleOff plays the playlist in order
+	ShuffleOff ShuffleMode = iota
+	// ShuffleRandom plays a random permutation of the playlist
+	ShuffleRandom
+	// ShuffleSmart keeps artists and albums apart and plays recently heard
+	// tracks last
+	ShuffleSmart
+)
+
+func (m ShuffleMode) String() string {
+	switch m {
+	case ShuffleOff:
+		return "off"
+	case ShuffleRandom:
+		return "random"
+	case ShuffleSmart:
+		return "smart"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseShuffleMode parses a shuffle mode name as used in the configuration
+func ParseShuffleMode(name string) (ShuffleMode, error) {
+	for _, mode := range []ShuffleMode{ShuffleOff, ShuffleRandom, ShuffleSmart} {
+		if mode.String() == name {
+			return mode, nil
+		}
+	}
+	return ShuffleOff, fmt.Errorf("unknown shuffle mode: %q", name)
+}
+
+// DefaultSmartShuffleWindow is how long a played track counts as recent
+const DefaultSmartShuffleWindow = 4 * time.Hour
+
+// TrackGrouping returns keys for the artist and album of a track; smart
+// shuffle avoids playing either twice in a row. Empty keys are unknown.
+type TrackGrouping func(path string) (artist, album string)
+
+// GroupByDirectory assumes an Artist/Album/Track directory layout. The album
+// key is the album directory, so albums of the same name stay distinct.
+func GroupByDirectory(path string) (artist, album string) {
+	album = filepath.Dir(path)
+	return filepath.Base(filepath.Dir(album)), album
+}
+
+// historyRetention is how long plays are kept in the history file
+const historyRetention = 30 * 24 * time.Hour
+
+// PlayHistory records when tracks were last played. It is saved as JSON after
+// every play so smart shuffle remembers across restarts.
+type PlayHistory struct {
+	mu     sync.Mutex
+	path   string
+	played map[string]time.Time
+}
+
+// NewPlayHistory creates an empty history that is kept in memory only
+func NewPlayHistory() *PlayHistory {
+	return &PlayHistory{played: make(map[string]time.Time)}
+}
+
+// LoadPlayHistory reads the history saved at path; a missing file gives an
+// empty history that is created on the first play
+func LoadPlayHistory(path string) (*PlayHistory, error) {
+	history := NewPlayHistory()
+	history.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read play history %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &history.played); err != nil {
+		return nil, fmt.Errorf("failed to parse play history %s: %w", path, err)
+	}
+	return history, nil
+}
+
+// Record notes that a track was played at a time, dropping plays older
+// than the retention period, and saves the history
+func (h *PlayHistory) Record(track string, at time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.played[track] = at
+	for path, played := range h.played {
+		if at.Sub(played) > historyRetention {
+			delete(h.played, path)
+		}
+	}
+	return h.saveLocked()
+}
+
+// LastPlayed returns when a track was last played
+func (h *PlayHistory) LastPlayed(track string) (time.Time, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	played, ok := h.played[track]
+	return played, ok
+}
+
+// saveLocked writes the history file; callers must hold h.mu
+func (h *PlayHistory) saveLocked() error {
+	if h.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(h.played, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode play history: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create play history directory: %w", err)
+	}
+	if err := os.WriteFile(h.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write play history %s: %w", h.path, err)
+	}
+	return nil
+}
+
+// SetShuffleMode sets the playlist order. Turning shuffle on draws a new
+// permutation starting from the current track.
+func (am *AudioManager) SetShuffleMode(mode ShuffleMode) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.shuffle = mode
+	am.order = nil
+	if mode != ShuffleOff {
+		am.shuffleUpcomingLocked([]int{am.currentIndex})
+	}
+	am.queueNextLocked()
+	am.publish(QueueChanged)
+}
+
+// GetShuffleMode returns the playlist order
+func (am *AudioManager) GetShuffleMode() ShuffleMode {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+
+	return am.shuffle
+}
+
+// SetPlayHistory sets where plays are recorded for smart shuffle
+func (am *AudioManager) SetPlayHistory(history *PlayHistory) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.history = history
+}
+
+// SetSmartShuffleWindow sets how long a played track counts as recent
+func (am *AudioManager) SetSmartShuffleWindow(window time.Duration) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.smartWindow = window
+}
+
+// SetTrackGrouping sets how smart shuffle identifies artists and albums
+func (am *AudioManager) SetTrackGrouping(grouping TrackGrouping) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.grouping = grouping
+}
+
+// recordPlayLocked adds the current track to the play history. Callers
+// must hold am.mutex.
+func (am *AudioManager) recordPlayLocked() {
+	if am.history == nil {
+		return
+	}
+	if err := am.history.Record(am.currentTrack, time.Now()); err != nil {
+		log.Printf("Failed to record play: %v", err)
+	}
+}
+
+// shuffleUpcomingLocked keeps the played part of the shuffle order and
+// draws a new order for every other playlist entry. Callers must hold
+// am.mutex.
+func (am *AudioManager) shuffleUpcomingLocked(played []int) {
+	if len(am.playlist) == 0 {
+		am.order = nil
+		return
+	}
+
+	seen := make(map[int]bool, len(played))
+	order := make([]int, 0, len(am.playlist))
+	for _, index := range played {
+		if index < len(am.playlist) && !seen[index] {
+			seen[index] = true
+			order = append(order, index)
+		}
+	}
+
+	var upcoming []int
+	for index := range am.playlist {
+		if !seen[index] {
+			upcoming = append(upcoming, index)
+		}
+	}
+
+	rand.Shuffle(len(upcoming), func(i, j int) {
+		upcoming[i], upcoming[j] = upcoming[j], upcoming[i]
+	})
+	if am.shuffle == ShuffleSmart && len(order) > 0 {
+		upcoming = am.smartOrderLocked(upcoming, order[len(order)-1])
+	}
+	am.order = append(order, upcoming...)
+}
+
+// reshuffleLocked redraws the order of the tracks after the current one.
+// Callers must hold am.mutex.
+func (am *AudioManager) reshuffleLocked() {
+	if am.shuffle == ShuffleOff {
+		return
+	}
+
+	position := am.orderPositionLocked(am.currentIndex)
+	if position < 0 {
+		am.shuffleUpcomingLocked([]int{am.currentIndex})
+		return
+	}
+	am.shuffleUpcomingLocked(am.order[:position+1])
+}
+
+// playNextInOrderLocked moves a playlist entry picked directly so it follows
+// the current track in the shuffle order, keeping Previous in step. Callers
+// must hold am.mutex.
+func (am *AudioManager) playNextInOrderLocked(index int) {
+	am.playAfterInOrderLocked(index, func(int) bool { return false })
+}
+
+// playAfterInOrderLocked moves a playlist entry in the shuffle order so it
+// follows the current track and the run of entries after it that skip
+// accepts. Callers must hold am.mutex.
+func (am *AudioManager) playAfterInOrderLocked(index int, skip func(entry int) bool) {
+	from := am.orderPositionLocked(index)
+	if am.shuffle == ShuffleOff || from < 0 || index == am.currentIndex {
+		return
+	}
+
+	am.order = slices.Delete(am.order, from, from+1)
+	to := am.orderPositionLocked(am.currentIndex) + 1
+	for to < len(am.order) && skip(am.order[to]) {
+		to++
+	}
+	am.order = slices.Insert(am.order, to, index)
+}
+
+// insertOrderLocked makes room in the shuffle order for count entries
+// inserted into the playlist at position, and plays them after the current
+// track. Callers must hold am.mutex.
+func (am *AudioManager) insertOrderLocked(position, count int) {
+	if am.shuffle == ShuffleOff {
+		return
+	}
+
+	for i, index := range am.order {
+		if index >= position {
+			am.order[i] = index + count
+		}
+	}
+
+	inserted := make([]int, count)
+	for i := range inserted {
+		inserted[i] = position + i
+	}
+	at := am.orderPositionLocked(am.currentIndex) + 1
+	am.order = slices.Insert(am.order, at, inserted...)
+}
+
+// orderPositionLocked finds a playlist entry in the shuffle order, or -1.
+// Callers must hold am.mutex.
+func (am *AudioManager) orderPositionLocked(index int) int {
+	for position, entry := range am.order {
+		if entry == index {
+			return position
+		}
+	}
+	return -1
+}
+
+// smartOrderLocked reorders shuffled playlist entries so tracks played
+// within the smart shuffle window come last, oldest play first, and no two
+// neighbours share an artist or album where it can be avoided. Callers must
+// hold am.mutex.
+func (am *AudioManager) smartOrderLocked(shuffled []int, previous int) []int {
+	grouping := am.grouping
+	if grouping == nil {
+		grouping = GroupByDirectory
+	}
+
+	type candidate struct {
+		index         int
+		artist, album string
+		lastPlayed    time.Time
+		recent        bool
+	}
+
+	now := time.Now()
+	candidates := make([]candidate, len(shuffled))
+	for i, index := range shuffled {
+		c := candidate{index: index}
+		c.artist, c.album = grouping(am.playlist[index])
+		if am.history != nil {
+			if played, ok := am.history.LastPlayed(am.playlist[index]); ok {
+				c.lastPlayed = played
+				c.recent = now.Sub(played) < am.smartWindow
+			}
+		}
+		candidates[i] = c
+	}
+
+	// Fresh tracks keep their shuffled order ahead of recent ones
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].recent != candidates[j].recent {
+			return !candidates[i].recent
+		}
+		return candidates[i].recent && candidates[i].lastPlayed.Before(candidates[j].lastPlayed)
+	})
+
+	lastArtist, lastAlbum := grouping(am.playlist[previous])
+	order := make([]int, 0, len(candidates))
+	for len(candidates) > 0 {
+		pick := 0
+		for i, c := range candidates {
+			if (c.artist == "" || c.artist != lastArtist) && (c.album == "" || c.album != lastAlbum) {
+				pick = i
+				break
+			}
+		}
+
+		c := candidates[pick]
+		candidates = append(candidates[:pick], candidates[pick+1:]...)
+		order = append(order, c.index)
+		lastArtist, lastAlbum = c.artist, c.album
+	}
+	return order
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// TransitionMode selects how one queued track leads into the next
+type TransitionMode string
+
+const (
+	// TransitionGapless starts the next track on the sample after the
+	// previous one ends
+	TransitionGapless TransitionMode = "gapless"
+	// TransitionCrossfade overlaps the end of a track with the start of
+	// the next
+	TransitionCrossfade TransitionMode = "crossfade"
+)
+
+// FadeCurve shapes the gain of a crossfade
+type FadeCurve string
+
+const (
+	// CurveLinear fades gains linearly; the overlap dips slightly in loudness
+	CurveLinear FadeCurve = "linear"
+	// CurveEqualPower keeps the combined loudness constant for unrelated material
+	CurveEqualPower FadeCurve = "equal_power"
+)
+
+// Transition describes how tracks are joined
+type Transition struct {
+	Mode TransitionMode
+	// Duration and Curve apply to crossfades
+	Duration time.Duration
+	Curve    FadeCurve
+}
+
+// DefaultTransition joins tracks gaplessly
+func DefaultTransition() Transition {
+	return Transition{Mode: TransitionGapless, Duration: 4 * time.Second, Curve: CurveEqualPower}
+}
+
+// Validate checks the transition settings
+func (t Transition) Validate() error {
+	switch t.Mode {
+	case TransitionGapless:
+	case TransitionCrossfade:
+		if t.Duration <= 0 {
+			return fmt.Errorf("crossfade duration must be positive, got: %v", t.Duration)
+		}
+	default:
+		return fmt.Errorf("unknown transition mode: %q", t.Mode)
+	}
+
+	switch t.Curve {
+	case CurveLinear, CurveEqualPower:
+	default:
+		return fmt.Errorf("unknown fade curve: %q", t.Curve)
+	}
+	return nil
+}
+
+// gains returns the fade-out and fade-in gains at progress 0-1
+func (c FadeCurve) gains(progress float64) (out, in float64) {
+	if c == CurveEqualPower {
+		return math.Cos(progress * math.Pi / 2), math.Sin(progress * math.Pi / 2)
+	}
+	return 1 - progress, progress
+}
+
+// deckTrack is a decoded track queued on the deck
+type deckTrack struct {
+	path     string
+	streamer beep.StreamSeekCloser
+	format   beep.Format
+	// source is streamer resampled to the speaker rate
+	source beep.Streamer
+	// length and played count samples at the speaker rate
+	length int
+	played int
+	// gain is the linear loudness normalization gain
+	gain float64
+}
+
+// openDeckTrack decodes a file for the deck
+func openDeckTrack(path string) (*deckTrack, error) {
+	streamer, format, err := AudioDecoders.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &deckTrack{
+		path:     path,
+		streamer: streamer,
+		format:   format,
+		source:   resample(streamer, format),
+		length:   speakerSampleRate.N(format.SampleRate.D(streamer.Len())),
+		gain:     1,
+	}, nil
+}
+
+// remaining returns the samples left to play
+func (t *deckTrack) remaining() int {
+	return max(t.length-t.played, 0)
+}
+
+// duration returns the length of the track
+func (t *deckTrack) duration() time.Duration {
+	return t.format.SampleRate.D(t.streamer.Len())
+}
+
+// position returns how far into the track playback is. It counts samples
+// handed to the speaker rather than reading the decoder, which the resampler
+// reads ahead of.
+func (t *deckTrack) position() time.Duration {
+	return speakerSampleRate.D(t.played)
+}
+
+// deckEventType identifies a deck event
+type deckEventType int
+
+const (
+	// deckAdvanced means the queued track became the current track
+	deckAdvanced deckEventType = iota
+	// deckEnded means the current track finished with nothing queued
+	deckEnded
+)
+
+// deckEvent reports a track change from the audio thread
+type deckEvent struct {
+	Type deckEventType
+	From *deckTrack
+	To   *deckTrack
+}
+
+// deck streams the current track and joins it to the queued next track
+// gaplessly or with a crossfade. It always streams, producing silence when
+// empty, so it can stay in the mixer for the life of the AudioManager. All
+// methods other than Stream must be called with the speaker locked.
+type deck struct {
+	current  *deckTrack
+	next     *deckTrack
+	outgoing *deckTrack
+
+	transition Transition
+	fadePos    int
+	fadeLen    int
+
+	// pending holds events until they are taken; signal wakes the taker
+	// without ever blocking the audio thread or dropping an event
+	eventsMu sync.Mutex
+	pending  []deckEvent
+	signal   chan struct{}
+
+	buf [512][2]float64
+}
+
+// newDeck creates an empty deck; signal fires when events are pending
+func newDeck(transition Transition) *deck {
+	return &deck{
+		transition: transition,
+		signal:     make(chan struct{}, 1),
+	}
+}
+
+// emit queues an event without blocking the audio thread
+func (d *deck) emit(event deckEvent) {
+	d.eventsMu.Lock()
+	d.pending = append(d.pending, event)
+	d.eventsMu.Unlock()
+
+	select {
+	case d.signal <- struct{}{}:
+	default:
+	}
+}
+
+// takeEvents returns the pending events in the order they happened
+func (d *deck) takeEvents() []deckEvent {
+	d.eventsMu.Lock()
+	defer d.eventsMu.Unlock()
+
+	events := d.pending
+	d.pending = nil
+	return events
+}
+
+// Load replaces the current track, dropping any queued and fading tracks
+func (d *deck) Load(track *deckTrack) {
+	d.closeTrack(d.current)
+	d.closeTrack(d.outgoing)
+	d.closeTrack(d.next)
+	d.current, d.outgoing, d.next = track, nil, nil
+}
+
+// Queue sets the track to follow the current one, returning the track it replaced
+func (d *deck) Queue(track *deckTrack) *deckTrack {
+	previous := d.next
+	d.next = track
+	return previous
+}
+
+// Seek moves the current track to position, ending any crossfade into it
+func (d *deck) Seek(track *deckTrack, position time.Duration) error {
+	if track != d.current {
+		return fmt.Errorf("track is not playing")
+	}
+
+	if err := track.streamer.Seek(track.format.SampleRate.N(position)); err != nil {
+		return err
+	}
+	// Restart the resampler so it drops samples buffered before the seek
+	track.source = resample(track.streamer, track.format)
+	track.played = speakerSampleRate.N(position)
+
+	d.closeTrack(d.outgoing)
+	d.outgoing = nil
+	return nil
+}
+
+// Clear closes every track
+func (d *deck) Clear() {
+	d.Load(nil)
+}
+
+// closeTrack releases a track's decoder
+func (d *deck) closeTrack(track *deckTrack) {
+	if track != nil {
+		track.streamer.Close()
+	}
+}
+
+// Stream mixes the current, outgoing and queued tracks
+func (d *deck) Stream(samples [][2]float64) (n int, ok bool) {
+	for i := range samples {
+		samples[i] = [2]float64{}
+	}
+
+	for n < len(samples) {
+		chunk := min(len(samples)-n, len(d.buf))
+		if d.current == nil {
+			break
+		}
+
+		// Start a crossfade so the outgoing track ends with the fade
+		if d.transition.Mode == TransitionCrossfade && d.next != nil && d.outgoing == nil {
+			fadeLen := speakerSampleRate.N(d.transition.Duration)
+			remaining := d.current.remaining()
+			if remaining <= fadeLen {
+				d.outgoing, d.current, d.next = d.current, d.next, nil
+				d.fadePos, d.fadeLen = 0, max(remaining, 1)
+				d.emit(deckEvent{Type: deckAdvanced, From: d.outgoing, To: d.current})
+			} else {
+				chunk = min(chunk, remaining-fadeLen)
+			}
+		}
+
+		out := samples[n : n+chunk]
+		streamed, more := d.current.source.Stream(d.buf[:chunk])
+		d.current.played += streamed
+		fading := d.outgoing != nil
+		for i := 0; i < streamed; i++ {
+			gain := d.current.gain
+			if fading {
+				progress := min(float64(d.fadePos+i)/float64(d.fadeLen), 1)
+				_, fade := d.transition.Curve.gains(progress)
+				gain *= fade
+			}
+			out[i][0] += d.buf[i][0] * gain
+			out[i][1] += d.buf[i][1] * gain
+		}
+
+		if fading {
+			d.mixOutgoing(out)
+		}
+
+		if streamed < chunk || !more {
+			// The current track is exhausted; carry straight on with the
+			// queued track in the same buffer
+			finished := d.current
+			if d.next != nil {
+				d.current, d.next = d.next, nil
+				d.emit(deckEvent{Type: deckAdvanced, From: finished, To: d.current})
+			} else {
+				d.current = nil
+				d.emit(deckEvent{Type: deckEnded, From: finished})
+			}
+			d.closeTrack(finished)
+			n += streamed
+			continue
+		}
+		n += chunk
+	}
+
+	return len(samples), true
+}
+
+// mixOutgoing adds the fading-out track under out and advances the fade
+func (d *deck) mixOutgoing(out [][2]float64) {
+	var tmp [512][2]float64
+	streamed, more := d.outgoing.source.Stream(tmp[:len(out)])
+	for i := 0; i < streamed; i++ {
+		progress := min(float64(d.fadePos+i)/float64(d.fadeLen), 1)
+		fade, _ := d.transition.Curve.gains(progress)
+		gain := fade * d.outgoing.gain
+		out[i][0] += tmp[i][0] * gain
+		out[i][1] += tmp[i][1] * gain
+	}
+
+	d.fadePos += len(out)
+	if !more || streamed < len(out) || d.fadePos >= d.fadeLen {
+		d.closeTrack(d.outgoing)
+		d.outgoing = nil
+	}
+}
+
+// Err implements beep.Streamer
+func (d *deck) Err() error {
+	return nil
+}
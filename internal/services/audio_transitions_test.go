@@ -0,0 +1,34 @@
+package services
+
+import "testing"
+
+func TestDeckKeepsEventsUntilTaken(t *testing.T) {
+	d := newDeck(DefaultTransition())
+
+	// Far more track changes than a listener would ever fall behind by
+	tracks := make([]*deckTrack, 100)
+	for i := range tracks {
+		tracks[i] = &deckTrack{}
+		if i > 0 {
+			d.emit(deckEvent{Type: deckAdvanced, From: tracks[i-1], To: tracks[i]})
+		}
+	}
+
+	select {
+	case <-d.signal:
+	default:
+		t.Fatal("no signal for pending events")
+	}
+	events := d.takeEvents()
+	if len(events) != len(tracks)-1 {
+		t.Fatalf("took %d events, want %d", len(events), len(tracks)-1)
+	}
+	for i, event := range events {
+		if event.From != tracks[i] || event.To != tracks[i+1] {
+			t.Fatalf("event %d out of order", i)
+		}
+	}
+	if events := d.takeEvents(); len(events) != 0 {
+		t.Errorf("took %d events again, want none", len(events))
+	}
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"time"
+
+	"github.com/dhowden/tag"
 )
 
 // AudioServiceInterface defines the interface for audio management
@@ -13,17 +15,81 @@ type AudioServiceInterface interface {
 	Stop() error
 	Next() error
 	Previous() error
+	Seek(position time.Duration) error
+	SeekRelative(offset time.Duration) error
 
 	// Volume control
 	SetVolume(volume float64)
 	SetMusicVolume(volume float64)
+	SetBusLevel(bus Bus, level float64) error
+	SetBusMuted(bus Bus, muted bool) error
+	SetBusLimits(bus Bus, low, high float64) error
+	GetMixer() MixerLevels
+
+	// Sound effects
+	PlaySFX(filename string) error
+	PlayUISound(event UISound) error
+
+	// Announcements
+	PlayAnnouncement(files ...string) error
+	IsAnnouncing() bool
+	SetDucking(ducking Ducking) error
 
 	// Playlist management
 	AddToPlaylist(tracks []string)
+	PlayNext(tracks []string)
+	PlayNow(track string) error
 	SetPlaylist(tracks []string)
+	GetPlaylist() []string
+	MoveInPlaylist(from, to int) error
+	MoveToNext(index int) error
+	MoveAfterAlbum(index int) error
+	RemoveFromPlaylist(index int) error
+	ClearPlaylist()
+	RemoveDuplicates() int
+	UndoPlaylistEdit() error
+	CanUndoPlaylistEdit() bool
+	SetShuffleMode(mode ShuffleMode)
+	GetShuffleMode() ShuffleMode
+
+	// Loudness
+	SetNormalization(mode NormalizationMode, preamp float64) error
+	GetNormalization() NormalizationMode
+
+	// Music bus effects
+	SetEffects(effects Effects) error
+	GetEffects() Effects
+
+	// Track transitions
+	SetTransition(transition Transition) error
+	SetPlaylistTransition(transition Transition) error
+	GetTransition() Transition
 
 	// Status
 	GetStatus() AudioStatus
+	GetStatusChannel() <-chan AudioEvent
+
+	// Cleanup
+	Close() error
+}
+
+// LibraryServiceInterface defines the interface for the music library catalog
+type LibraryServiceInterface interface {
+	// Indexing
+	Scan() (ScanResult, error)
+	Watch() error
+	Changes() <-chan struct{}
+
+	// Browsing
+	Track(path string) (LibraryTrack, bool)
+	Tracks() []LibraryTrack
+	Artists() []string
+	Albums(artist string) []LibraryAlbum
+	AlbumTracks(album LibraryAlbum) []LibraryTrack
+	Genres() []string
+	GenreTracks(genre string) []LibraryTrack
+	Gains(path string) TrackGains
+	Artwork(path string) (*tag.Picture, error)
 
 	// Cleanup
 	Close() error
@@ -37,4 +103,51 @@ type AudioStatus struct {
 	Position     time.Duration
 	Duration     time.Duration
 	Volume       float64
+	Muted        bool
+	Shuffle      ShuffleMode
+}
+
+// MegaInd defines the interface for the MegaInd industrial automation card
+// that drives the physical buttons, button LEDs and cooling fan
+type MegaInd interface {
+	// Inputs
+	Subscribe() (<-chan ButtonEvent, func())
+	Buttons() ButtonState
+	RawAnalogInput(channel int) (uint16, error)
+	AnalogThreshold(channel int) (AnalogThreshold, error)
+	SetAnalogThreshold(channel int, threshold AnalogThreshold) error
+
+	// LED control
+	LightButton(ledIndex int, brightness int) error
+	StartFlashing(ledIndex int, brightness int, interval time.Duration) error
+	StopFlashing(ledIndex int) error
+	LEDs() *LEDEngine
+
+	// Fan control
+	SetFanSpeed(speed int) error
+
+	// General purpose I/O
+	SetOpenDrainOutput(channel int, on bool) error
+	OpenDrainOutputs() (uint8, error)
+	SetAnalogOutput(channel int, volts float64) error
+	AnalogOutput(channel int) (float64, error)
+	OptoInputs() (uint8, error)
+	EnableOptoCounter(channel int, rising, falling bool) error
+	OptoCount(channel int) (uint32, error)
+	ResetOptoCount(channel int) error
+	CurrentInput(channel int) (float64, error)
+
+	// Onboard sensors and clock
+	SupplyVoltage() (float64, error)
+	Temperature() (float64, error)
+	RTC() (time.Time, error)
+	SetRTC(t time.Time) error
+
+	// Status
+	IsRunning() bool
+	Health() MegaIndHealth
+	TransportStats() TransportStats
+
+	// Cleanup
+	Dispose() error
 }
@@ -0,0 +1,590 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Library browse groupings for tracks without the tag
+const (
+	UnknownArtist = "Unknown Artist"
+	UnknownAlbum  = "Unknown Album"
+	UnknownGenre  = "Unknown Genre"
+)
+
+// catalogVersion is bumped when LibraryTrack or how it is read changes so
+// old catalogs are rescanned in full
+const catalogVersion = 3
+
+// catalogCheckpoint is how many files a scan reads between catalog saves,
+// so an interrupted first scan of a large library is not lost
+const catalogCheckpoint = 200
+
+// ErrLibraryClosed is returned by a scan stopped by Close
+var ErrLibraryClosed = errors.New("library is closed")
+
+// LibraryTrack is a catalogued audio file and its tags
+type LibraryTrack struct {
+	Path        string        `json:"path"`
+	Title       string        `json:"title,omitempty"`
+	Artist      string        `json:"artist,omitempty"`
+	AlbumArtist string        `json:"album_artist,omitempty"`
+	Album       string        `json:"album,omitempty"`
+	Genre       string        `json:"genre,omitempty"`
+	Track       int           `json:"track,omitempty"`
+	Disc        int           `json:"disc,omitempty"`
+	Year        int           `json:"year,omitempty"`
+	Duration    time.Duration `json:"duration,omitempty"`
+	// ArtMIME is the type of the embedded cover art, empty if there is none
+	ArtMIME string `json:"art_mime,omitempty"`
+	// TrackGain and AlbumGain come from ReplayGain tags
+	TrackGain *ReplayGain `json:"track_gain,omitempty"`
+	AlbumGain *ReplayGain `json:"album_gain,omitempty"`
+	// Loudness is measured while scanning tracks without a track gain tag
+	Loudness *Loudness `json:"loudness,omitempty"`
+
+	// ModTime and Size detect files changed since they were read
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+}
+
+// DisplayTitle returns the title tag, or the file name without its
+// extension for untagged files
+func (t LibraryTrack) DisplayTitle() string {
+	if t.Title != "" {
+		return t.Title
+	}
+	name := filepath.Base(t.Path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// DisplayArtist returns the artist, falling back to the album artist
+func (t LibraryTrack) DisplayArtist() string {
+	switch {
+	case t.Artist != "":
+		return t.Artist
+	case t.AlbumArtist != "":
+		return t.AlbumArtist
+	default:
+		return UnknownArtist
+	}
+}
+
+// albumArtist returns the artist an album is filed under
+func (t LibraryTrack) albumArtist() string {
+	if t.AlbumArtist != "" {
+		return t.AlbumArtist
+	}
+	return t.DisplayArtist()
+}
+
+// DisplayAlbum returns the album, or UnknownAlbum
+func (t LibraryTrack) DisplayAlbum() string {
+	if t.Album != "" {
+		return t.Album
+	}
+	return UnknownAlbum
+}
+
+// DisplayGenre returns the genre, or UnknownGenre
+func (t LibraryTrack) DisplayGenre() string {
+	if t.Genre != "" {
+		return t.Genre
+	}
+	return UnknownGenre
+}
+
+// LibraryAlbum is an album and the artist it is filed under
+type LibraryAlbum struct {
+	Title  string
+	Artist string
+}
+
+// ScanResult counts the changes a scan made to the catalog
+type ScanResult struct {
+	Added     int
+	Updated   int
+	Removed   int
+	Unchanged int
+	Failed    int
+}
+
+// Changed reports whether the scan changed the catalog. Unreadable files
+// are still catalogued, so they count as changes.
+func (r ScanResult) Changed() bool {
+	return r.Added+r.Updated+r.Removed+r.Failed > 0
+}
+
+func (r ScanResult) String() string {
+	return fmt.Sprintf("%d added, %d updated, %d removed, %d unchanged, %d unreadable",
+		r.Added, r.Updated, r.Removed, r.Unchanged, r.Failed)
+}
+
+// catalogFile is the on-disk form of the catalog
+type catalogFile struct {
+	Version int            `json:"version"`
+	Tracks  []LibraryTrack `json:"tracks"`
+}
+
+// Library indexes the audio files under a set of root directories. The
+// catalog is kept on disk so a rescan only reads files whose size or
+// modification time changed.
+type Library struct {
+	mu          sync.RWMutex
+	roots       []string
+	catalogPath string
+	tracks      map[string]LibraryTrack
+
+	// scanMu serialises scans and watcher updates; unsaved counts the files
+	// read since the catalog was last saved
+	scanMu  sync.Mutex
+	unsaved int
+	changes chan struct{}
+	stop    chan struct{}
+
+	watchMu   sync.Mutex
+	watcher   *fsnotify.Watcher
+	watchDone chan struct{}
+	closed    bool
+}
+
+// NewLibrary creates a library over roots; catalogPath may be empty to keep
+// the catalog in memory only
+func NewLibrary(roots []string, catalogPath string) *Library {
+	return &Library{
+		roots:       roots,
+		catalogPath: catalogPath,
+		tracks:      make(map[string]LibraryTrack),
+		changes:     make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+	}
+}
+
+// Roots returns the directories the library scans
+func (l *Library) Roots() []string {
+	return l.roots
+}
+
+// Load reads the saved catalog; a missing file leaves the library empty
+func (l *Library) Load() error {
+	if l.catalogPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(l.catalogPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read library catalog %s: %w", l.catalogPath, err)
+	}
+
+	var catalog catalogFile
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return fmt.Errorf("failed to parse library catalog %s: %w", l.catalogPath, err)
+	}
+	if catalog.Version != catalogVersion {
+		return nil
+	}
+
+	l.mu.Lock()
+	for _, track := range catalog.Tracks {
+		l.tracks[track.Path] = track
+	}
+	l.mu.Unlock()
+
+	l.notify()
+	return nil
+}
+
+// save writes the catalog file
+func (l *Library) save() error {
+	if l.catalogPath == "" {
+		return nil
+	}
+
+	catalog := catalogFile{Version: catalogVersion, Tracks: l.Tracks()}
+	data, err := json.Marshal(catalog)
+	if err != nil {
+		return fmt.Errorf("failed to encode library catalog: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.catalogPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create library catalog directory: %w", err)
+	}
+	if err := os.WriteFile(l.catalogPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write library catalog %s: %w", l.catalogPath, err)
+	}
+	return nil
+}
+
+// Scan walks the roots, reads new and changed files, drops files that have
+// gone and saves the catalog if anything changed. The catalog is also saved
+// every few hundred files read, and when Close stops the scan, so the next
+// scan carries on from there.
+func (l *Library) Scan() (ScanResult, error) {
+	l.scanMu.Lock()
+	defer l.scanMu.Unlock()
+
+	var result ScanResult
+	for _, root := range l.roots {
+		if err := l.scanTree(root, &result); err != nil {
+			if saveErr := l.commit(result); saveErr != nil {
+				log.Printf("Failed to save library catalog: %v", saveErr)
+			}
+			return result, err
+		}
+	}
+
+	// Drop tracks from roots that are no longer configured
+	l.mu.Lock()
+	for path := range l.tracks {
+		if !l.inRoots(path) {
+			delete(l.tracks, path)
+			result.Removed++
+		}
+	}
+	l.mu.Unlock()
+
+	return result, l.commit(result)
+}
+
+// inRoots reports whether path lies under one of the library roots
+func (l *Library) inRoots(path string) bool {
+	for _, root := range l.roots {
+		if rel, err := filepath.Rel(root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// commit announces and saves the catalog after a scan that changed it.
+// Callers must hold l.scanMu.
+func (l *Library) commit(result ScanResult) error {
+	if !result.Changed() {
+		return nil
+	}
+	l.notify()
+	l.unsaved = 0
+	return l.save()
+}
+
+// checkpoint saves the catalog once enough files have been read since the
+// last save. Callers must hold l.scanMu.
+func (l *Library) checkpoint() {
+	if l.unsaved < catalogCheckpoint {
+		return
+	}
+	l.unsaved = 0
+	if err := l.save(); err != nil {
+		log.Printf("Failed to save library catalog: %v", err)
+	}
+	l.notify()
+}
+
+// scanTree brings the catalog entries under dir up to date. Callers must
+// hold l.scanMu.
+func (l *Library) scanTree(dir string, result *ScanResult) error {
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable directories rather than abandoning the scan
+			log.Printf("Library scan: %v", err)
+			if entry != nil && entry.IsDir() && path != dir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		select {
+		case <-l.stop:
+			return ErrLibraryClosed
+		default:
+		}
+		if entry.IsDir() || !AudioDecoders.Supports(path) {
+			return nil
+		}
+
+		seen[path] = true
+		info, err := entry.Info()
+		if err != nil {
+			result.Failed++
+			return nil
+		}
+		l.scanFile(path, info, result)
+		l.checkpoint()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	l.removeUnder(dir, func(path string) bool { return !seen[path] }, result)
+	return nil
+}
+
+// scanFile reads a file into the catalog unless its entry is current. A
+// file that cannot be read is catalogued but counted only as failed.
+// Callers must hold l.scanMu.
+func (l *Library) scanFile(path string, info fs.FileInfo, result *ScanResult) {
+	l.mu.RLock()
+	cached, ok := l.tracks[path]
+	l.mu.RUnlock()
+	if ok && cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) {
+		result.Unchanged++
+		return
+	}
+
+	track, err := readLibraryTrack(path, info)
+	if err != nil {
+		log.Printf("Library scan: %v", err)
+	}
+
+	l.mu.Lock()
+	l.tracks[path] = track
+	l.mu.Unlock()
+	l.unsaved++
+	switch {
+	case err != nil:
+		result.Failed++
+	case ok:
+		result.Updated++
+	default:
+		result.Added++
+	}
+}
+
+// removeUnder drops catalog entries at or below path that match remove
+func (l *Library) removeUnder(path string, remove func(string) bool, result *ScanResult) {
+	prefix := strings.TrimSuffix(path, string(filepath.Separator)) + string(filepath.Separator)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for track := range l.tracks {
+		if (track == path || strings.HasPrefix(track, prefix)) && remove(track) {
+			delete(l.tracks, track)
+			result.Removed++
+		}
+	}
+}
+
+// readLibraryTrack reads the tags and length of a file. Files that cannot
+// be read are still catalogued, under their file name, and the error is
+// returned alongside.
+func readLibraryTrack(path string, info fs.FileInfo) (LibraryTrack, error) {
+	track := LibraryTrack{
+		Path:    path,
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return track, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	// Files without tags are normal; only the length is missing then
+	if metadata, err := tag.ReadFrom(file); err == nil {
+		track.Title = strings.TrimSpace(metadata.Title())
+		track.Artist = strings.TrimSpace(metadata.Artist())
+		track.AlbumArtist = strings.TrimSpace(metadata.AlbumArtist())
+		track.Album = strings.TrimSpace(metadata.Album())
+		track.Genre = strings.TrimSpace(metadata.Genre())
+		track.Year = metadata.Year()
+		track.Track, _ = metadata.Track()
+		track.Disc, _ = metadata.Disc()
+		if picture := metadata.Picture(); picture != nil {
+			track.ArtMIME = picture.MIMEType
+		}
+		track.TrackGain, track.AlbumGain = readReplayGainTags(metadata)
+	}
+
+	streamer, format, err := AudioDecoders.Open(path)
+	if err != nil {
+		return track, err
+	}
+	defer streamer.Close()
+	track.Duration = format.SampleRate.D(streamer.Len())
+
+	// Untagged tracks are measured so they can still be normalized
+	if track.TrackGain == nil {
+		loudness, err := measureLoudness(streamer, format)
+		if err != nil {
+			log.Printf("Library scan: %s: %v", path, err)
+		} else {
+			track.Loudness = &loudness
+		}
+	}
+
+	return track, nil
+}
+
+// Artwork reads the embedded cover art of a track
+func (l *Library) Artwork(path string) (*tag.Picture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	metadata, err := tag.ReadFrom(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags of %s: %w", path, err)
+	}
+	if metadata.Picture() == nil {
+		return nil, fmt.Errorf("no artwork in %s", path)
+	}
+	return metadata.Picture(), nil
+}
+
+// Changes returns a channel that receives a value after the catalog changes.
+// Changes made while a value is pending are coalesced into it.
+func (l *Library) Changes() <-chan struct{} {
+	return l.changes
+}
+
+// notify signals a catalog change without blocking
+func (l *Library) notify() {
+	select {
+	case l.changes <- struct{}{}:
+	default:
+	}
+}
+
+// Track looks up a catalogued file
+func (l *Library) Track(path string) (LibraryTrack, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	track, ok := l.tracks[path]
+	return track, ok
+}
+
+// Tracks returns every catalogued track sorted by artist, album and track
+func (l *Library) Tracks() []LibraryTrack {
+	return l.filter(func(LibraryTrack) bool { return true })
+}
+
+// Artists returns the artists albums are filed under, sorted by name
+func (l *Library) Artists() []string {
+	return l.distinct(LibraryTrack.albumArtist)
+}
+
+// Genres returns the genres in the library, sorted by name
+func (l *Library) Genres() []string {
+	return l.distinct(LibraryTrack.DisplayGenre)
+}
+
+// Albums returns the albums in the library, or those filed under artist if
+// it is not empty, sorted by title
+func (l *Library) Albums(artist string) []LibraryAlbum {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	seen := make(map[LibraryAlbum]bool)
+	var albums []LibraryAlbum
+	for _, track := range l.tracks {
+		album := LibraryAlbum{Title: track.DisplayAlbum(), Artist: track.albumArtist()}
+		if (artist != "" && album.Artist != artist) || seen[album] {
+			continue
+		}
+		seen[album] = true
+		albums = append(albums, album)
+	}
+
+	sort.Slice(albums, func(i, j int) bool {
+		if !strings.EqualFold(albums[i].Title, albums[j].Title) {
+			return strings.ToLower(albums[i].Title) < strings.ToLower(albums[j].Title)
+		}
+		return strings.ToLower(albums[i].Artist) < strings.ToLower(albums[j].Artist)
+	})
+	return albums
+}
+
+// AlbumTracks returns the tracks of an album in disc and track order
+func (l *Library) AlbumTracks(album LibraryAlbum) []LibraryTrack {
+	return l.filter(func(track LibraryTrack) bool {
+		return track.DisplayAlbum() == album.Title && track.albumArtist() == album.Artist
+	})
+}
+
+// GenreTracks returns the tracks of a genre by artist, album and track
+func (l *Library) GenreTracks(genre string) []LibraryTrack {
+	return l.filter(func(track LibraryTrack) bool {
+		return track.DisplayGenre() == genre
+	})
+}
+
+// Grouping returns the artist and album keys of a track for smart shuffle,
+// using its tags when the track is catalogued
+func (l *Library) Grouping(path string) (artist, album string) {
+	track, ok := l.Track(path)
+	if !ok || (track.Artist == "" && track.Album == "") {
+		return GroupByDirectory(path)
+	}
+	return track.DisplayArtist(), track.albumArtist() + "\x00" + track.DisplayAlbum()
+}
+
+// filter returns the matching tracks sorted by artist, album, disc, track
+// and path
+func (l *Library) filter(match func(LibraryTrack) bool) []LibraryTrack {
+	l.mu.RLock()
+	var tracks []LibraryTrack
+	for _, track := range l.tracks {
+		if match(track) {
+			tracks = append(tracks, track)
+		}
+	}
+	l.mu.RUnlock()
+
+	sort.Slice(tracks, func(i, j int) bool {
+		a, b := tracks[i], tracks[j]
+		artistA, artistB := strings.ToLower(a.albumArtist()), strings.ToLower(b.albumArtist())
+		albumA, albumB := strings.ToLower(a.DisplayAlbum()), strings.ToLower(b.DisplayAlbum())
+		switch {
+		case artistA != artistB:
+			return artistA < artistB
+		case albumA != albumB:
+			return albumA < albumB
+		case a.Disc != b.Disc:
+			return a.Disc < b.Disc
+		case a.Track != b.Track:
+			return a.Track < b.Track
+		default:
+			return a.Path < b.Path
+		}
+	})
+	return tracks
+}
+
+// distinct returns the distinct values of key over the catalog, sorted
+// case-insensitively
+func (l *Library) distinct(key func(LibraryTrack) string) []string {
+	l.mu.RLock()
+	seen := make(map[string]bool)
+	var values []string
+	for _, track := range l.tracks {
+		if value := key(track); !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	l.mu.RUnlock()
+
+	sort.Slice(values, func(i, j int) bool {
+		return strings.ToLower(values[i]) < strings.ToLower(values[j])
+	})
+	return values
+}
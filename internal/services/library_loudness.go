@@ -0,0 +1,239 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/faiface/beep"
+)
+
+// ReplayGainReference is the loudness ReplayGain 2 normalizes to, in LUFS
+const ReplayGainReference = -18.0
+
+// r128Reference is the loudness Opus R128 gain tags are relative to
+const r128Reference = -23.0
+
+// ReplayGain is a gain adjustment and the sample peak it was measured with
+type ReplayGain struct {
+	// Gain is in dB
+	Gain float64 `json:"gain"`
+	// Peak is the largest sample as a fraction of full scale; 0 if unknown
+	Peak float64 `json:"peak,omitempty"`
+}
+
+// Loudness is a measured EBU R128 integrated loudness
+type Loudness struct {
+	// Integrated is in LUFS
+	Integrated float64 `json:"integrated"`
+	Peak       float64 `json:"peak"`
+}
+
+// Gain returns the ReplayGain that brings the loudness to the reference
+func (l Loudness) Gain() ReplayGain {
+	return ReplayGain{Gain: ReplayGainReference - l.Integrated, Peak: l.Peak}
+}
+
+// TrackGains are the track and album ReplayGain of a file; either is nil
+// when unknown
+type TrackGains struct {
+	Track *ReplayGain
+	Album *ReplayGain
+}
+
+// GainLookup returns the ReplayGain of a file
+type GainLookup func(path string) TrackGains
+
+// readReplayGainTags reads the ReplayGain tags of a file in any of the
+// ID3v2, Vorbis comment, MP4 and Opus R128 spellings
+func readReplayGainTags(metadata tag.Metadata) (trackGain, albumGain *ReplayGain) {
+	values := make(map[string]string)
+	for key, value := range metadata.Raw() {
+		switch value := value.(type) {
+		case *tag.Comm:
+			// ID3v2 keeps them in TXXX frames named by their description
+			values[strings.ToLower(value.Description)] = value.Text
+		case string:
+			values[strings.ToLower(key)] = value
+		}
+	}
+
+	read := func(gainKey, peakKey, r128Key string) *ReplayGain {
+		if gain, err := parseDecibels(values[gainKey]); err == nil {
+			peak, _ := strconv.ParseFloat(strings.TrimSpace(values[peakKey]), 64)
+			return &ReplayGain{Gain: gain, Peak: peak}
+		}
+		// Opus stores Q7.8 fixed point gains to -23 LUFS; reaching the
+		// louder ReplayGain reference takes the difference on top
+		if q78, err := strconv.Atoi(strings.TrimSpace(values[r128Key])); err == nil {
+			return &ReplayGain{Gain: float64(q78)/256 + ReplayGainReference - r128Reference}
+		}
+		return nil
+	}
+	return read("replaygain_track_gain", "replaygain_track_peak", "r128_track_gain"),
+		read("replaygain_album_gain", "replaygain_album_peak", "r128_album_gain")
+}
+
+// parseDecibels parses a gain such as "-6.48 dB"
+func parseDecibels(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && strings.EqualFold(value[len(value)-2:], "db") {
+		value = strings.TrimSpace(value[:len(value)-2])
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// kWeighting returns the two stages of the BS.1770 K-weighting filter for
+// a sample rate: a high shelf modelling the head, then a high pass
+func kWeighting(rate beep.SampleRate) [2]biquad {
+	// Coefficients derived for any rate as in libebur128
+	k := math.Tan(math.Pi * 1681.974450955533 / float64(rate))
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	k = math.Tan(math.Pi * 38.13547087602444 / float64(rate))
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return [2]biquad{shelf, highPass}
+}
+
+// measureLoudness reads a stream to its end and returns its EBU R128
+// integrated loudness and sample peak
+func measureLoudness(streamer beep.Streamer, format beep.Format) (Loudness, error) {
+	filters := kWeighting(format.SampleRate)
+
+	// Loudness is measured over 400 ms blocks overlapping by 75%, built
+	// from 100 ms steps
+	step := max(format.SampleRate.N(100*time.Millisecond), 1)
+	var steps []float64
+	var sum float64
+	var filled int
+	var peak float64
+
+	var buf [512][2]float64
+	for {
+		n, ok := streamer.Stream(buf[:])
+		for _, sample := range buf[:n] {
+			for channel, x := range sample {
+				peak = max(peak, math.Abs(x))
+				y := filters[1].process(channel, filters[0].process(channel, x))
+				sum += y * y
+			}
+			if filled++; filled == step {
+				steps = append(steps, sum/float64(step))
+				sum, filled = 0, 0
+			}
+		}
+		if !ok {
+			break
+		}
+	}
+	if err := streamer.Err(); err != nil {
+		return Loudness{}, fmt.Errorf("failed to measure loudness: %w", err)
+	}
+
+	var blocks []float64
+	for i := 3; i < len(steps); i++ {
+		blocks = append(blocks, (steps[i-3]+steps[i-2]+steps[i-1]+steps[i])/4)
+	}
+
+	// Gate out silence, then anything 10 LU below the remaining average
+	gated := gateBlocks(blocks, blockEnergy(-70))
+	if len(gated) == 0 {
+		return Loudness{}, fmt.Errorf("too short or silent to measure loudness")
+	}
+	gated = gateBlocks(gated, meanEnergy(gated)/10)
+	return Loudness{Integrated: energyLoudness(meanEnergy(gated)), Peak: peak}, nil
+}
+
+// blockEnergy converts a loudness in LUFS to the mean square it stands for
+func blockEnergy(loudness float64) float64 {
+	return math.Pow(10, (loudness+0.691)/10)
+}
+
+// energyLoudness converts a mean square to a loudness in LUFS
+func energyLoudness(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+// gateBlocks returns the blocks louder than threshold
+func gateBlocks(blocks []float64, threshold float64) []float64 {
+	var kept []float64
+	for _, energy := range blocks {
+		if energy > threshold {
+			kept = append(kept, energy)
+		}
+	}
+	return kept
+}
+
+// meanEnergy averages block energies
+func meanEnergy(blocks []float64) float64 {
+	var sum float64
+	for _, energy := range blocks {
+		sum += energy
+	}
+	return sum / float64(len(blocks))
+}
+
+// trackGain returns a track's gain from its tags, or from its measured
+// loudness
+func (t LibraryTrack) trackGain() *ReplayGain {
+	if t.TrackGain != nil {
+		return t.TrackGain
+	}
+	if t.Loudness != nil {
+		gain := t.Loudness.Gain()
+		return &gain
+	}
+	return nil
+}
+
+// Gains returns the ReplayGain of a catalogued file. Gains missing from the
+// tags come from the loudness measured during scanning; an untagged album's
+// gain combines the loudness of its tracks, weighted by length.
+func (l *Library) Gains(path string) TrackGains {
+	track, ok := l.Track(path)
+	if !ok {
+		return TrackGains{}
+	}
+
+	gains := TrackGains{Track: track.trackGain(), Album: track.AlbumGain}
+	if gains.Album != nil || track.Album == "" {
+		return gains
+	}
+
+	var energy, weight, peak float64
+	for _, other := range l.AlbumTracks(LibraryAlbum{Title: track.DisplayAlbum(), Artist: track.albumArtist()}) {
+		gain := other.trackGain()
+		if gain == nil {
+			// Without every track the album level is a guess
+			return gains
+		}
+		seconds := max(other.Duration.Seconds(), 1)
+		energy += seconds * blockEnergy(ReplayGainReference-gain.Gain)
+		weight += seconds
+		peak = max(peak, gain.Peak)
+	}
+	gains.Album = &ReplayGain{Gain: ReplayGainReference - energyLoudness(energy/weight), Peak: peak}
+	return gains
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+func TestScanCountsUnreadableFilesOnce(t *testing.T) {
+	root := t.TempDir()
+	tracks := writeSilentTracks(t, 1)
+	if err := os.Rename(tracks[0], filepath.Join(root, "good.wav")); err != nil {
+		t.Fatalf("move track: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "broken.wav"), []byte("not audio"), 0o644); err != nil {
+		t.Fatalf("write broken track: %v", err)
+	}
+
+	library := NewLibrary([]string{root}, filepath.Join(t.TempDir(), "library.json"))
+	result, err := library.Scan()
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if want := (ScanResult{Added: 1, Failed: 1}); result != want {
+		t.Errorf("first scan = %+v, want %+v", result, want)
+	}
+	// The unreadable file is still catalogued under its name
+	if _, ok := library.Track(filepath.Join(root, "broken.wav")); !ok {
+		t.Error("unreadable file missing from the catalog")
+	}
+
+	result, err = library.Scan()
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if want := (ScanResult{Unchanged: 2}); result != want {
+		t.Errorf("second scan = %+v, want %+v", result, want)
+	}
+}
+
+func TestTracksSortAlbumArtistsCaseInsensitively(t *testing.T) {
+	library := NewLibrary(nil, "")
+	for _, track := range []LibraryTrack{
+		{Path: "/b", AlbumArtist: "beatles", Album: "Help", Track: 1},
+		{Path: "/a2", AlbumArtist: "ABBA", Album: "Gold", Track: 2},
+		{Path: "/a3", AlbumArtist: "Abba", Album: "gold", Track: 3},
+		{Path: "/a1", AlbumArtist: "abba", Album: "Gold", Track: 1},
+	} {
+		library.tracks[track.Path] = track
+	}
+
+	var paths []string
+	for _, track := range library.Tracks() {
+		paths = append(paths, track.Path)
+	}
+	// Spellings that differ only in case form one album in track order
+	if want := []string{"/a1", "/a2", "/a3", "/b"}; !slices.Equal(paths, want) {
+		t.Errorf("track order = %v, want %v", paths, want)
+	}
+}
+
+// writeBrokenTracks writes count unreadable audio files under dir
+func writeBrokenTracks(t *testing.T, dir string, count int) {
+	t.Helper()
+
+	for i := range count {
+		path := filepath.Join(dir, fmt.Sprintf("track%04d.wav", i))
+		if err := os.WriteFile(path, []byte("not audio"), 0o644); err != nil {
+			t.Fatalf("write track: %v", err)
+		}
+	}
+}
+
+// catalogLength returns how many tracks the saved catalog at path holds
+func catalogLength(t *testing.T, path string) int {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read catalog: %v", err)
+	}
+	var catalog catalogFile
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		t.Fatalf("parse catalog: %v", err)
+	}
+	return len(catalog.Tracks)
+}
+
+func TestScanSavesCatalogAtCheckpoints(t *testing.T) {
+	root := t.TempDir()
+	writeBrokenTracks(t, root, catalogCheckpoint+10)
+	catalogPath := filepath.Join(t.TempDir(), "library.json")
+	library := NewLibrary([]string{root}, catalogPath)
+
+	// scanTree leaves the final save to its caller, so only the
+	// checkpoint has saved the catalog
+	var result ScanResult
+	library.scanMu.Lock()
+	err := library.scanTree(root, &result)
+	library.scanMu.Unlock()
+	if err != nil {
+		t.Fatalf("scanTree: %v", err)
+	}
+	if got := catalogLength(t, catalogPath); got != catalogCheckpoint {
+		t.Errorf("catalog holds %d tracks mid-scan, want %d", got, catalogCheckpoint)
+	}
+}
+
+func TestCloseStopsScan(t *testing.T) {
+	root := t.TempDir()
+	writeBrokenTracks(t, root, 4*catalogCheckpoint)
+	catalogPath := filepath.Join(t.TempDir(), "library.json")
+	library := NewLibrary([]string{root}, catalogPath)
+
+	type scanned struct {
+		result ScanResult
+		err    error
+	}
+	done := make(chan scanned, 1)
+	go func() {
+		result, err := library.Scan()
+		done <- scanned{result, err}
+	}()
+
+	// Close at the first checkpoint, while the scan is under way
+	<-library.Changes()
+	if err := library.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var scan scanned
+	select {
+	case scan = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scan still running after Close")
+	}
+	switch {
+	case errors.Is(scan.err, ErrLibraryClosed):
+		// What was read before stopping is saved
+		if got := catalogLength(t, catalogPath); got != scan.result.Failed {
+			t.Errorf("catalog holds %d tracks, want the %d read", got, scan.result.Failed)
+		}
+	case scan.err != nil:
+		t.Fatalf("Scan: %v", scan.err)
+	}
+
+	// A closed library does not scan again
+	if _, err := library.Scan(); !errors.Is(err, ErrLibraryClosed) {
+		t.Errorf("Scan after Close = %v, want ErrLibraryClosed", err)
+	}
+}
+
+// rawTags is tag metadata with only raw values
+type rawTags struct {
+	tag.Metadata
+	raw map[string]any
+}
+
+func (m rawTags) Raw() map[string]any {
+	return m.raw
+}
+
+func TestReadReplayGainTags(t *testing.T) {
+	for _, check := range []struct {
+		name       string
+		raw        map[string]any
+		track      float64
+		album      float64
+		trackKnown bool
+		albumKnown bool
+	}{
+		{
+			name:       "Vorbis comments",
+			raw:        map[string]any{"REPLAYGAIN_TRACK_GAIN": "-6.48 dB", "REPLAYGAIN_ALBUM_GAIN": "+1.5 dB"},
+			track:      -6.48,
+			album:      1.5,
+			trackKnown: true,
+			albumKnown: true,
+		},
+		{
+			// R128 gains bring tracks to -23 LUFS, 5 dB under the
+			// ReplayGain reference
+			name:       "Opus R128 tags",
+			raw:        map[string]any{"R128_TRACK_GAIN": "0", "R128_ALBUM_GAIN": "-512"},
+			track:      5,
+			album:      3,
+			trackKnown: true,
+			albumKnown: true,
+		},
+		{
+			name:       "no tags",
+			raw:        map[string]any{"TITLE": "Song"},
+			trackKnown: false,
+			albumKnown: false,
+		},
+	} {
+		track, album := readReplayGainTags(rawTags{raw: check.raw})
+		if (track != nil) != check.trackKnown || track != nil && math.Abs(track.Gain-check.track) > 1e-9 {
+			t.Errorf("%s: track gain = %v, want %v", check.name, track, check.track)
+		}
+		if (album != nil) != check.albumKnown || album != nil && math.Abs(album.Gain-check.album) > 1e-9 {
+			t.Errorf("%s: album gain = %v, want %v", check.name, album, check.album)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// libraryDebounce is how long the library roots must be quiet before
+// changes are applied, so a copy in progress is read once it has settled
+const libraryDebounce = 2 * time.Second
+
+// Watch keeps the catalog up to date as files under the roots change. Bursts
+// of changes are debounced and applied as one catalog update.
+func (l *Library) Watch() error {
+	l.watchMu.Lock()
+	defer l.watchMu.Unlock()
+
+	if l.closed {
+		return fmt.Errorf("library is closed")
+	}
+	if l.watcher != nil {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start library watcher: %w", err)
+	}
+	for _, root := range l.roots {
+		if err := watchTree(watcher, root); err != nil {
+			log.Printf("Library watch: %v", err)
+		}
+	}
+
+	l.watcher = watcher
+	l.watchDone = make(chan struct{})
+	go l.watch(watcher, l.watchDone)
+	return nil
+}
+
+// Close stops watching the roots and stops a scan in progress, waiting for
+// it to save what it has read
+func (l *Library) Close() error {
+	l.watchMu.Lock()
+	defer l.watchMu.Unlock()
+
+	if !l.closed {
+		l.closed = true
+		close(l.stop)
+	}
+
+	var err error
+	if l.watcher != nil {
+		err = l.watcher.Close()
+		<-l.watchDone
+		l.watcher = nil
+	}
+
+	l.scanMu.Lock()
+	defer l.scanMu.Unlock()
+	return err
+}
+
+// watchTree watches dir and every directory below it; fsnotify does not
+// watch recursively
+func watchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if entry != nil && entry.IsDir() && path != dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// watch batches filesystem events until the watcher is closed
+func (l *Library) watch(watcher *fsnotify.Watcher, done chan struct{}) {
+	defer close(done)
+
+	pending := make(map[string]bool)
+	debounce := time.NewTimer(libraryDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			// New directories need watching before files land in them
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(watcher, event.Name); err != nil {
+						log.Printf("Library watch: %v", err)
+					}
+				}
+			}
+			pending[event.Name] = true
+			debounce.Reset(libraryDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Library watch: %v", err)
+
+		case <-debounce.C:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			clear(pending)
+
+			result, err := l.Update(paths)
+			if errors.Is(err, ErrLibraryClosed) {
+				return
+			}
+			if err != nil {
+				log.Printf("Library update failed: %v", err)
+			} else if result.Changed() {
+				log.Printf("Music library updated: %s", result)
+			}
+		}
+	}
+}
+
+// Update brings the catalog up to date for changed files and directories,
+// rescanning directories and dropping whatever no longer exists
+func (l *Library) Update(paths []string) (ScanResult, error) {
+	l.scanMu.Lock()
+	defer l.scanMu.Unlock()
+
+	// Sorting puts directories ahead of their contents, which their scan covers
+	paths = slices.Clone(paths)
+	slices.Sort(paths)
+
+	var result ScanResult
+	var scanned []string
+	for _, path := range paths {
+		if !l.inRoots(path) || slices.ContainsFunc(scanned, func(dir string) bool {
+			return strings.HasPrefix(path, dir+string(filepath.Separator))
+		}) {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			l.removeUnder(path, func(string) bool { return true }, &result)
+		case err != nil:
+			log.Printf("Library update: %v", err)
+		case info.IsDir():
+			if err := l.scanTree(path, &result); err != nil {
+				return result, err
+			}
+			scanned = append(scanned, path)
+		case AudioDecoders.Supports(path):
+			l.scanFile(path, info, &result)
+		}
+	}
+	return result, l.commit(result)
+}
@@ -2,14 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
-
-	"periph.io/x/conn/v3/i2c"
-	"periph.io/x/conn/v3/i2c/i2creg"
-	"periph.io/x/host/v3"
 )
 
 // Button represents the available physical buttons
@@ -43,343 +40,402 @@ func (b Button) String() string {
 	}
 }
 
-// ButtonMap represents the current state of all buttons
-type ButtonMap struct {
-	mu     sync.RWMutex
-	states map[Button]bool
-}
+// MegaIndController runs the buttons, button LEDs and fan wired to one
+// MegaInd card. The card's general purpose I/O is available through the
+// embedded MegaIndCard.
+type MegaIndController struct {
+	*MegaIndCard
 
-// NewButtonMap creates a new ButtonMap with all buttons released
-func NewButtonMap() *ButtonMap {
-	return &ButtonMap{
-		states: make(map[Button]bool),
-	}
-}
+	mu        sync.RWMutex
+	isRunning bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+	// ownsCard is set when the controller opened the card and closes it
+	ownsCard bool
 
-// Get returns the current state of a button
-func (bm *ButtonMap) Get(button Button) bool {
-	bm.mu.RLock()
-	defer bm.mu.RUnlock()
-	return bm.states[button]
-}
+	// Button edge detection
+	detector         *buttonDetector
+	analogThresholds [2]AnalogThreshold
+	buttonState      ButtonState
+	subscribers      []chan ButtonEvent
 
-// Set updates the state of a button
-func (bm *ButtonMap) Set(button Button, pressed bool) {
-	bm.mu.Lock()
-	defer bm.mu.Unlock()
-	bm.states[button] = pressed
-}
-
-// GetAll returns a copy of all button states
-func (bm *ButtonMap) GetAll() map[Button]bool {
-	bm.mu.RLock()
-	defer bm.mu.RUnlock()
-	
-	result := make(map[Button]bool)
-	for k, v := range bm.states {
-		result[k] = v
-	}
-	return result
-}
-
-// MegaIndController handles communication with the MegaInd hardware
-type MegaIndController struct {
-	mu           sync.RWMutex
-	i2cDevice    i2c.Dev
-	isRunning    bool
-	ctx          context.Context
-	cancel       context.CancelFunc
-	buttonMap    *ButtonMap
-	inputChannel chan *ButtonMap
-	
-	// LED flashing state
-	ledFlashing  [4]bool
-	ledMutex     sync.RWMutex
+	// LED compositor and the blink animation started by StartFlashing
+	leds     *LEDEngine
+	flashing []LEDAnimationID
 }
 
 const (
 	// I2C device address and registers
-	deviceAddress           = 0x50
-	digitalInputRegister    = 0x03
-	analogInputRegister1    = 0x1C
-	analogInputRegister2    = 0x1E
-	pwmFanOutputRegister    = 0x14
-	pwmLedOutputRegister0   = 0x16
-	pwmLedOutputRegister1   = 0x18
-	
+	deviceAddress         = 0x50
+	digitalInputRegister  = 0x03
+	analogInputRegister1  = 0x1C
+	analogInputRegister2  = 0x1E
+	pwmFanOutputRegister  = 0x14
+	pwmLedOutputRegister0 = 0x16
+	pwmLedOutputRegister1 = 0x18
+
 	// Analog input thresholds for 5V button detection
 	ainLowerLimit = 114.75
 	ainUpperLimit = 140.25
-	
+
 	// Polling interval
 	inputPollingInterval = 50 * time.Millisecond
+
+	// Buffered events per subscriber before new events are dropped
+	buttonEventBuffer = 64
 )
 
 var (
 	pwmLedOutputRegisters = []int{pwmLedOutputRegister0, pwmLedOutputRegister1}
 )
 
-// Singleton instance
-var (
-	controllerInstance *MegaIndController
-	controllerOnce     sync.Once
-)
+// Compile-time check that the controller satisfies the MegaInd interface
+var _ MegaInd = (*MegaIndController)(nil)
 
-// GetMegaIndController returns the singleton instance of MegaIndController
-func GetMegaIndController() *MegaIndController {
-	controllerOnce.Do(func() {
-		controllerInstance = &MegaIndController{
-			buttonMap:    NewButtonMap(),
-			inputChannel: make(chan *ButtonMap, 10),
-		}
+// NewMegaIndController creates a controller that is not yet attached to a card
+func NewMegaIndController() *MegaIndController {
+	m := &MegaIndController{
+		detector:         newButtonDetector(),
+		analogThresholds: [2]AnalogThreshold{DefaultAnalogThreshold(), DefaultAnalogThreshold()},
+		flashing:         make([]LEDAnimationID, len(pwmLedOutputRegisters)),
+	}
+	m.leds = NewLEDEngine(len(pwmLedOutputRegisters), func(led, brightness int) error {
+		return m.writeByteRegister(pwmLedOutputRegisters[led], uint8(brightness))
 	})
-	return controllerInstance
+	return m
+}
+
+// SetButtonTiming configures debounce, long-press, double-press and repeat
+// timing for a button
+func (m *MegaIndController) SetButtonTiming(button Button, timing ButtonTiming) error {
+	if button < ButtonA || button > ButtonDown {
+		return fmt.Errorf("invalid button: %d", button)
+	}
+	if timing.Debounce < 0 || timing.LongPress < 0 || timing.DoublePress < 0 ||
+		timing.RepeatDelay < 0 || timing.RepeatInterval < 0 {
+		return fmt.Errorf("button timing must not be negative")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.detector.timings[button] = timing
+	return nil
 }
 
-// Init initializes the MegaInd controller with the specified I2C bus
-func (m *MegaIndController) Init(i2cBusNumber int) error {
+// SetAnalogThreshold configures the pressed window of analog input channel
+// 1 (Up) or 2 (Down)
+func (m *MegaIndController) SetAnalogThreshold(channel int, threshold AnalogThreshold) error {
+	if channel < 1 || channel > len(m.analogThresholds) {
+		return fmt.Errorf("invalid analog input channel: %d", channel)
+	}
+	if threshold.Lower >= threshold.Upper {
+		return fmt.Errorf("analog threshold lower limit %.2f must be below upper limit %.2f", threshold.Lower, threshold.Upper)
+	}
+	if threshold.Hysteresis < 0 {
+		return fmt.Errorf("analog threshold hysteresis must not be negative, got: %.2f", threshold.Hysteresis)
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	
-	if m.isRunning {
-		return fmt.Errorf("controller is already running")
+	m.analogThresholds[channel-1] = threshold
+	return nil
+}
+
+// AnalogThreshold returns the pressed window of analog input channel 1 (Up)
+// or 2 (Down)
+func (m *MegaIndController) AnalogThreshold(channel int) (AnalogThreshold, error) {
+	if channel < 1 || channel > len(m.analogThresholds) {
+		return AnalogThreshold{}, fmt.Errorf("invalid analog input channel: %d", channel)
 	}
-	
-	// Initialize the host
-	if _, err := host.Init(); err != nil {
-		return fmt.Errorf("failed to initialize host: %w", err)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.analogThresholds[channel-1], nil
+}
+
+// Init opens the card at a stack position on the named I2C bus and starts
+// the controller on it
+func (m *MegaIndController) Init(busName string, stack int) error {
+	if m.IsRunning() {
+		return fmt.Errorf("controller is already running")
 	}
-	
-	// Open I2C bus
-	bus, err := i2creg.Open(fmt.Sprintf("I2C%d", i2cBusNumber))
+
+	card, err := OpenMegaIndCard(busName, stack)
 	if err != nil {
-		return fmt.Errorf("failed to open I2C bus %d: %w", i2cBusNumber, err)
+		return err
+	}
+
+	if err := m.attach(card, true); err != nil {
+		card.Close()
+		return err
 	}
-	
-	// Create I2C device
-	m.i2cDevice = i2c.Dev{Bus: bus, Addr: deviceAddress}
-	
+
+	log.Printf("MegaInd controller initialized on stack %d of I2C bus %q", stack, busName)
+	return nil
+}
+
+// Attach starts the controller on an already opened register bus, such as
+// a SimulatedMegaInd, as the card at stack position 0. The controller takes
+// ownership of the bus.
+func (m *MegaIndController) Attach(bus RegisterBus) error {
+	return m.attach(NewMegaIndCard(bus, "simulated", 0), true)
+}
+
+// AttachCard starts the controller on an opened card, such as one in a
+// MegaIndStack. The caller keeps ownership of the card and closes it after
+// disposing the controller.
+func (m *MegaIndController) AttachCard(card *MegaIndCard) error {
+	return m.attach(card, false)
+}
+
+// attach starts the controller on a card, closing it on Dispose if owned
+func (m *MegaIndController) attach(card *MegaIndCard, owned bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isRunning {
+		return fmt.Errorf("controller is already running")
+	}
+
+	m.MegaIndCard = card
+	m.ownsCard = owned
+
 	// Create context for cancellation
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 	m.isRunning = true
-	
-	// Start input polling goroutine
+
+	// Start input polling and LED frame goroutines
 	go m.inputPollingLoop()
-	
-	log.Printf("MegaInd controller initialized on I2C bus %d", i2cBusNumber)
+	go m.ledFrameLoop()
+
 	return nil
 }
 
-// GetInputChannel returns the channel for receiving button state updates
-func (m *MegaIndController) GetInputChannel() <-chan *ButtonMap {
-	return m.inputChannel
+// ProbeMegaInd checks that a MegaInd card answers at a stack position on
+// the named I2C bus
+func ProbeMegaInd(busName string, stack int) error {
+	card, err := OpenMegaIndCard(busName, stack)
+	if err != nil {
+		return err
+	}
+	return card.Close()
 }
 
-// GetButtonMap returns the current button map
-func (m *MegaIndController) GetButtonMap() *ButtonMap {
+// Subscribe returns a channel of button events and a function that
+// cancels the subscription. The channel is closed when the subscription is
+// cancelled or the controller is disposed.
+func (m *MegaIndController) Subscribe() (<-chan ButtonEvent, func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := make(chan ButtonEvent, buttonEventBuffer)
+	m.subscribers = append(m.subscribers, ch)
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			for i, sub := range m.subscribers {
+				if sub == ch {
+					m.subscribers = append(m.subscribers[:i], m.subscribers[i+1:]...)
+					close(ch)
+					return
+				}
+			}
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Buttons returns a snapshot of the debounced button levels
+func (m *MegaIndController) Buttons() ButtonState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.buttonState
+}
+
+// publish delivers events to every subscriber without blocking the poller
+func (m *MegaIndController) publish(events []ButtonEvent) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.buttonMap
+
+	for _, event := range events {
+		for _, sub := range m.subscribers {
+			select {
+			case sub <- event:
+			default:
+				log.Printf("Dropped button event %v: subscriber is not keeping up", event)
+			}
+		}
+	}
 }
 
 // inputPollingLoop continuously polls the hardware for input changes
 func (m *MegaIndController) inputPollingLoop() {
 	ticker := time.NewTicker(inputPollingInterval)
 	defer ticker.Stop()
-	
+
 	for {
 		select {
 		case <-m.ctx.Done():
 			return
 		case <-ticker.C:
-			if err := m.pollInputs(); err != nil {
+			// Offline periods are logged once by the transport
+			if err := m.pollInputs(); err != nil && !errors.Is(err, ErrMegaIndOffline) {
 				log.Printf("Error polling inputs: %v", err)
 			}
 		}
 	}
 }
 
-// pollInputs reads the current state of all inputs
+// pollInputs samples all inputs and publishes the resulting button events
 func (m *MegaIndController) pollInputs() error {
 	// Read digital inputs (buttons A, B, X, Y)
 	digitalState, err := m.readByteRegister(digitalInputRegister)
 	if err != nil {
 		return fmt.Errorf("failed to read digital inputs: %w", err)
 	}
-	
+
 	// Invert bits (active low) and mask to 4 bits
 	digitalState = (^digitalState) & 0x0F
-	
-	// Update button states
-	m.buttonMap.Set(ButtonA, (digitalState>>0)&1 == 1)
-	m.buttonMap.Set(ButtonB, (digitalState>>1)&1 == 1)
-	m.buttonMap.Set(ButtonX, (digitalState>>2)&1 == 1)
-	m.buttonMap.Set(ButtonY, (digitalState>>3)&1 == 1)
-	
+
 	// Read analog inputs (Up/Down buttons)
 	ain1, err := m.readWordRegister(analogInputRegister1)
 	if err != nil {
 		return fmt.Errorf("failed to read analog input 1: %w", err)
 	}
-	
+
 	ain2, err := m.readWordRegister(analogInputRegister2)
 	if err != nil {
 		return fmt.Errorf("failed to read analog input 2: %w", err)
 	}
-	
-	// Convert to float and check thresholds
-	ain1Float := float64(ain1)
-	ain2Float := float64(ain2)
-	
-	m.buttonMap.Set(ButtonUp, ain1Float > ainLowerLimit && ain1Float < ainUpperLimit)
-	m.buttonMap.Set(ButtonDown, ain2Float > ainLowerLimit && ain2Float < ainUpperLimit)
-	
-	// Send update through channel (non-blocking)
-	select {
-	case m.inputChannel <- m.buttonMap:
-	default:
-		// Channel is full, skip this update
-	}
-	
+
+	m.mu.Lock()
+	previous := m.detector.State()
+
+	raw := ButtonState(digitalState)
+	raw = raw.With(ButtonUp, m.analogThresholds[0].Pressed(float64(ain1), previous.Pressed(ButtonUp)))
+	raw = raw.With(ButtonDown, m.analogThresholds[1].Pressed(float64(ain2), previous.Pressed(ButtonDown)))
+
+	events := m.detector.Update(raw, time.Now())
+	m.buttonState = m.detector.State()
+	m.mu.Unlock()
+
+	m.publish(events)
 	return nil
 }
 
-// LightButton sets the brightness of an LED (0-100)
-func (m *MegaIndController) LightButton(ledIndex int, brightness int) error {
-	if ledIndex < 0 || ledIndex >= len(pwmLedOutputRegisters) {
-		return fmt.Errorf("invalid LED index: %d", ledIndex)
+// LEDs returns the LED compositor for playing layered animations
+func (m *MegaIndController) LEDs() *LEDEngine {
+	return m.leds
+}
+
+// ledFrameLoop drives the LED compositor on its frame clock
+func (m *MegaIndController) ledFrameLoop() {
+	ticker := time.NewTicker(ledFrameInterval)
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Log a failing bus once rather than on every frame
+			err := m.leds.Step(now)
+			switch {
+			case err == nil, errors.Is(err, ErrMegaIndOffline):
+				lastErr = ""
+			case err.Error() != lastErr:
+				log.Printf("Error updating LEDs: %v", err)
+				lastErr = err.Error()
+			}
+		}
 	}
-	
-	if brightness < 0 || brightness > 100 {
-		return fmt.Errorf("brightness must be between 0 and 100, got: %d", brightness)
+}
+
+// LightButton sets the base brightness of an LED (0-100), shown whenever no
+// animation is playing on it
+func (m *MegaIndController) LightButton(ledIndex int, brightness int) error {
+	if err := m.leds.SetBase(ledIndex, brightness); err != nil {
+		return err
 	}
-	
-	register := pwmLedOutputRegisters[ledIndex]
-	if err := m.writeByteRegister(register, uint8(brightness)); err != nil {
-		return fmt.Errorf("failed to set LED %d brightness: %w", ledIndex, err)
+
+	// Apply immediately instead of waiting for the next frame
+	if err := m.leds.Step(time.Now()); err != nil {
+		return err
 	}
-	
+
 	log.Printf("LED %d brightness set to: %d", ledIndex, brightness)
 	return nil
 }
 
-// StartFlashing starts flashing an LED with the specified parameters
+// StartFlashing blinks an LED, on and off for interval each, replacing any
+// flashing already started on it
 func (m *MegaIndController) StartFlashing(ledIndex int, brightness int, interval time.Duration) error {
-	if ledIndex < 0 || ledIndex >= len(m.ledFlashing) {
-		return fmt.Errorf("invalid LED index: %d", ledIndex)
-	}
-	
-	if brightness < 0 || brightness > 100 {
-		return fmt.Errorf("brightness must be between 0 and 100, got: %d", brightness)
-	}
-	
-	m.ledMutex.Lock()
-	m.ledFlashing[ledIndex] = true
-	m.ledMutex.Unlock()
-	
-	go m.flashLED(ledIndex, brightness, interval)
-	
+	if err := m.leds.validateLED(ledIndex); err != nil {
+		return err
+	}
+	if err := validateBrightness(brightness); err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return fmt.Errorf("flash interval must be positive, got: %v", interval)
+	}
+
+	id, err := m.leds.Play(LEDAnimation{
+		LEDs:     []int{ledIndex},
+		Priority: LEDPriorityNormal,
+		Pattern:  BlinkPattern(brightness, 2*interval),
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	previous := m.flashing[ledIndex]
+	m.flashing[ledIndex] = id
+	m.mu.Unlock()
+	m.leds.Stop(previous)
+
 	log.Printf("Started flashing LED %d with brightness %d and interval %v", ledIndex, brightness, interval)
 	return nil
 }
 
-// StopFlashing stops flashing an LED
+// StopFlashing stops flashing an LED and turns it off
 func (m *MegaIndController) StopFlashing(ledIndex int) error {
-	if ledIndex < 0 || ledIndex >= len(m.ledFlashing) {
-		return fmt.Errorf("invalid LED index: %d", ledIndex)
-	}
-	
-	m.ledMutex.Lock()
-	m.ledFlashing[ledIndex] = false
-	m.ledMutex.Unlock()
-	
+	if err := m.leds.validateLED(ledIndex); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	id := m.flashing[ledIndex]
+	m.flashing[ledIndex] = 0
+	m.mu.Unlock()
+	m.leds.Stop(id)
+
 	// Turn off the LED
 	if err := m.LightButton(ledIndex, 0); err != nil {
 		return fmt.Errorf("failed to turn off LED %d: %w", ledIndex, err)
 	}
-	
+
 	log.Printf("Stopped flashing LED %d", ledIndex)
 	return nil
 }
 
-// flashLED handles the flashing logic for an LED
-func (m *MegaIndController) flashLED(ledIndex int, brightness int, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-	
-	isOn := false
-	
-	for {
-		select {
-		case <-m.ctx.Done():
-			return
-		case <-ticker.C:
-			m.ledMutex.RLock()
-			shouldContinue := m.ledFlashing[ledIndex]
-			m.ledMutex.RUnlock()
-			
-			if !shouldContinue {
-				return
-			}
-			
-			// Toggle LED
-			if isOn {
-				m.LightButton(ledIndex, 0)
-			} else {
-				m.LightButton(ledIndex, brightness)
-			}
-			isOn = !isOn
-		}
-	}
-}
-
 // SetFanSpeed sets the PWM fan speed (0-100)
 func (m *MegaIndController) SetFanSpeed(speed int) error {
 	if speed < 0 || speed > 100 {
 		return fmt.Errorf("fan speed must be between 0 and 100, got: %d", speed)
 	}
-	
+
 	if err := m.writeByteRegister(pwmFanOutputRegister, uint8(speed)); err != nil {
 		return fmt.Errorf("failed to set fan speed: %w", err)
 	}
-	
-	log.Printf("Fan speed set to: %d", speed)
-	return nil
-}
-
-// readByteRegister reads a single byte from an I2C register
-func (m *MegaIndController) readByteRegister(register int) (uint8, error) {
-	read := []byte{0}
-	write := []byte{uint8(register)}
-	
-	if err := m.i2cDevice.Tx(write, read); err != nil {
-		return 0, err
-	}
-	
-	return read[0], nil
-}
 
-// readWordRegister reads a 16-bit word from an I2C register
-func (m *MegaIndController) readWordRegister(register int) (uint16, error) {
-	read := make([]byte, 2)
-	write := []byte{uint8(register)}
-	
-	if err := m.i2cDevice.Tx(write, read); err != nil {
-		return 0, err
-	}
-	
-	// Assuming little-endian format
-	return uint16(read[0]) | (uint16(read[1]) << 8), nil
-}
-
-// writeByteRegister writes a single byte to an I2C register
-func (m *MegaIndController) writeByteRegister(register int, value uint8) error {
-	write := []byte{uint8(register), value}
-	
-	if err := m.i2cDevice.Tx(write, nil); err != nil {
-		return err
-	}
-	
+	log.Printf("Fan speed set to: %d", speed)
 	return nil
 }
 
@@ -387,28 +443,37 @@ func (m *MegaIndController) writeByteRegister(register int, value uint8) error {
 func (m *MegaIndController) Dispose() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	
+
 	if !m.isRunning {
 		return nil
 	}
-	
-	// Stop all flashing LEDs
-	m.ledMutex.Lock()
-	for i := range m.ledFlashing {
-		m.ledFlashing[i] = false
+
+	// Stop all LED animations
+	m.leds.StopAll()
+	for i := range m.flashing {
+		m.flashing[i] = 0
 	}
-	m.ledMutex.Unlock()
-	
+
 	// Cancel context to stop goroutines
 	if m.cancel != nil {
 		m.cancel()
 	}
-	
-	// Close input channel
-	close(m.inputChannel)
-	
+
+	// Close subscriber channels
+	for _, sub := range m.subscribers {
+		close(sub)
+	}
+	m.subscribers = nil
+
+	// Release the card if the controller opened it
+	if m.ownsCard {
+		if err := m.MegaIndCard.Close(); err != nil {
+			log.Printf("Failed to close MegaInd bus: %v", err)
+		}
+	}
+
 	m.isRunning = false
-	
+
 	log.Println("MegaInd controller disposed")
 	return nil
 }
@@ -418,4 +483,4 @@ func (m *MegaIndController) IsRunning() bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	return m.isRunning
-}
\ No newline at end of file
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"fmt"
+
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/i2c/i2creg"
+	"periph.io/x/host/v3"
+)
+
+// RegisterBus performs register transactions with a single MegaInd card.
+// The first byte written selects the register; further bytes are written to
+// consecutive registers, and reads start at the selected register.
+type RegisterBus interface {
+	Tx(w, r []byte) error
+	Close() error
+}
+
+// i2cRegisterBus is a RegisterBus backed by a periph.io I2C device
+type i2cRegisterBus struct {
+	name string
+	bus  i2c.BusCloser
+	dev  i2c.Dev
+}
+
+// OpenI2CRegisterBus opens the named I2C bus and addresses the card at addr.
+// The name is resolved by periph, so it may be a bus number such as "1", a
+// device path such as "/dev/i2c-1", or empty for the first bus found.
+func OpenI2CRegisterBus(busName string, addr uint16) (RegisterBus, error) {
+	// Initialize the host
+	if _, err := host.Init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize host: %w", err)
+	}
+
+	// Open I2C bus
+	bus, err := i2creg.Open(busName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I2C bus %q: %w", busName, err)
+	}
+
+	return &i2cRegisterBus{
+		name: busName,
+		bus:  bus,
+		dev:  i2c.Dev{Bus: bus, Addr: addr},
+	}, nil
+}
+
+// Tx performs a write-then-read transaction with the device
+func (b *i2cRegisterBus) Tx(w, r []byte) error {
+	return b.dev.Tx(w, r)
+}
+
+// Close releases the underlying I2C bus
+func (b *i2cRegisterBus) Close() error {
+	return b.bus.Close()
+}
+
+// Reopen opens the I2C bus again, e.g. after the adapter was reset. The
+// old handle is left open for the caller to close once the new one works.
+func (b *i2cRegisterBus) Reopen() (RegisterBus, error) {
+	return OpenI2CRegisterBus(b.name, b.dev.Addr)
+}
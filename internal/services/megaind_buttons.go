@@ -0,0 +1,254 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// buttonCount is the number of physical buttons
+const buttonCount = int(ButtonDown) + 1
+
+// AllButtons lists the physical buttons in register order
+var AllButtons = []Button{ButtonA, ButtonB, ButtonX, ButtonY, ButtonUp, ButtonDown}
+
+// ParseButton returns the button with the given name, as printed by String
+func ParseButton(name string) (Button, error) {
+	for _, button := range AllButtons {
+		if strings.EqualFold(button.String(), name) {
+			return button, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown button: %q", name)
+}
+
+// ButtonState is an immutable snapshot of all button levels
+type ButtonState uint8
+
+// Pressed reports whether the button is held in this snapshot
+func (s ButtonState) Pressed(button Button) bool {
+	return s&(1<<uint(button)) != 0
+}
+
+// With returns a copy of the snapshot with the button level changed
+func (s ButtonState) With(button Button, pressed bool) ButtonState {
+	if pressed {
+		return s | 1<<uint(button)
+	}
+	return s &^ (1 << uint(button))
+}
+
+// String lists the pressed buttons
+func (s ButtonState) String() string {
+	var pressed []string
+	for _, button := range AllButtons {
+		if s.Pressed(button) {
+			pressed = append(pressed, button.String())
+		}
+	}
+	if len(pressed) == 0 {
+		return "none"
+	}
+	return strings.Join(pressed, "+")
+}
+
+// ButtonEventType identifies the kind of button event
+type ButtonEventType int
+
+const (
+	ButtonPressed ButtonEventType = iota
+	ButtonReleased
+	ButtonLongPress
+	ButtonDoublePress
+	ButtonRepeat
+)
+
+func (t ButtonEventType) String() string {
+	switch t {
+	case ButtonPressed:
+		return "Pressed"
+	case ButtonReleased:
+		return "Released"
+	case ButtonLongPress:
+		return "LongPress"
+	case ButtonDoublePress:
+		return "DoublePress"
+	case ButtonRepeat:
+		return "Repeat"
+	default:
+		return "Unknown"
+	}
+}
+
+// ButtonEvent describes a debounced change in a physical button
+type ButtonEvent struct {
+	Button Button
+	Type   ButtonEventType
+	// Duration is how long the button has been held. It is set for
+	// LongPress, Repeat and Released events.
+	Duration time.Duration
+	Time     time.Time
+	// State is the snapshot of all buttons when the event fired
+	State ButtonState
+}
+
+func (e ButtonEvent) String() string {
+	if e.Duration > 0 {
+		return fmt.Sprintf("%s %s (%v)", e.Button, e.Type, e.Duration.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s %s", e.Button, e.Type)
+}
+
+// ButtonTiming configures edge detection for a single button
+type ButtonTiming struct {
+	// Debounce is how long a new level must be stable before it is accepted
+	Debounce time.Duration
+	// LongPress is how long a button must be held to emit a LongPress event
+	LongPress time.Duration
+	// DoublePress is the longest gap between two presses that counts as a
+	// double press
+	DoublePress time.Duration
+	// RepeatDelay is how long a button must be held before Repeat events start
+	RepeatDelay time.Duration
+	// RepeatInterval is the time between Repeat events; zero disables repeat
+	RepeatInterval time.Duration
+}
+
+// DefaultButtonTiming returns the timing used for buttons without configuration
+func DefaultButtonTiming() ButtonTiming {
+	return ButtonTiming{
+		Debounce:       30 * time.Millisecond,
+		LongPress:      600 * time.Millisecond,
+		DoublePress:    350 * time.Millisecond,
+		RepeatDelay:    600 * time.Millisecond,
+		RepeatInterval: 200 * time.Millisecond,
+	}
+}
+
+// AnalogThreshold is the raw AIN window that counts as a pressed analog
+// button. Once pressed, the window is widened by Hysteresis on both sides so
+// a reading hovering at the edge does not chatter.
+type AnalogThreshold struct {
+	Lower      float64
+	Upper      float64
+	Hysteresis float64
+}
+
+// DefaultAnalogThreshold returns the threshold for 5V button detection
+func DefaultAnalogThreshold() AnalogThreshold {
+	return AnalogThreshold{
+		Lower:      ainLowerLimit,
+		Upper:      ainUpperLimit,
+		Hysteresis: 5,
+	}
+}
+
+// Pressed reports whether the reading counts as pressed given the previous level
+func (t AnalogThreshold) Pressed(value float64, wasPressed bool) bool {
+	if wasPressed {
+		return value > t.Lower-t.Hysteresis && value < t.Upper+t.Hysteresis
+	}
+	return value > t.Lower && value < t.Upper
+}
+
+// buttonTracker holds the edge detection state of one button
+type buttonTracker struct {
+	raw         bool
+	rawSince    time.Time
+	pressed     bool
+	pressedAt   time.Time
+	lastPressAt time.Time
+	longFired   bool
+	nextRepeat  time.Time
+}
+
+// buttonDetector turns sampled button levels into debounced events. It is
+// driven purely by the samples and timestamps it is given, so it can be
+// exercised with synthetic time.
+type buttonDetector struct {
+	timings  [buttonCount]ButtonTiming
+	trackers [buttonCount]buttonTracker
+	state    ButtonState
+}
+
+// newButtonDetector creates a detector with default timing for every button
+func newButtonDetector() *buttonDetector {
+	d := &buttonDetector{}
+	for i := range d.timings {
+		d.timings[i] = DefaultButtonTiming()
+	}
+	return d
+}
+
+// State returns the debounced button snapshot
+func (d *buttonDetector) State() ButtonState {
+	return d.state
+}
+
+// Update feeds a raw sample taken at now and returns the resulting events
+func (d *buttonDetector) Update(raw ButtonState, now time.Time) []ButtonEvent {
+	var events []ButtonEvent
+
+	for _, button := range AllButtons {
+		t := &d.trackers[button]
+		timing := d.timings[button]
+		level := raw.Pressed(button)
+
+		if level != t.raw {
+			t.raw = level
+			t.rawSince = now
+		}
+
+		// Accept the raw level once it has been stable for the debounce time
+		if t.raw != t.pressed && now.Sub(t.rawSince) >= timing.Debounce {
+			t.pressed = t.raw
+			d.state = d.state.With(button, t.pressed)
+
+			if t.pressed {
+				doublePress := !t.lastPressAt.IsZero() && now.Sub(t.lastPressAt) <= timing.DoublePress
+				t.pressedAt = now
+				t.longFired = false
+				t.nextRepeat = now.Add(timing.RepeatDelay)
+
+				events = append(events, ButtonEvent{Button: button, Type: ButtonPressed, Time: now, State: d.state})
+				if doublePress {
+					events = append(events, ButtonEvent{Button: button, Type: ButtonDoublePress, Time: now, State: d.state})
+					// A third press starts a new pair
+					t.lastPressAt = time.Time{}
+				} else {
+					t.lastPressAt = now
+				}
+			} else {
+				events = append(events, ButtonEvent{
+					Button:   button,
+					Type:     ButtonReleased,
+					Duration: now.Sub(t.pressedAt),
+					Time:     now,
+					State:    d.state,
+				})
+				if t.longFired {
+					// A long press never forms half of a double press
+					t.lastPressAt = time.Time{}
+				}
+			}
+			continue
+		}
+
+		if !t.pressed {
+			continue
+		}
+
+		held := now.Sub(t.pressedAt)
+		if !t.longFired && timing.LongPress > 0 && held >= timing.LongPress {
+			t.longFired = true
+			events = append(events, ButtonEvent{Button: button, Type: ButtonLongPress, Duration: held, Time: now, State: d.state})
+		}
+
+		if timing.RepeatInterval > 0 && !now.Before(t.nextRepeat) {
+			t.nextRepeat = now.Add(timing.RepeatInterval)
+			events = append(events, ButtonEvent{Button: button, Type: ButtonRepeat, Duration: held, Time: now, State: d.state})
+		}
+	}
+
+	return events
+}
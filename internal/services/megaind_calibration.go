@@ -0,0 +1,117 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// AnalogButtons are the buttons read from analog inputs rather than opto inputs
+var AnalogButtons = []Button{ButtonUp, ButtonDown}
+
+// AnalogButtonChannel returns the analog input an Up or Down button is wired to
+func AnalogButtonChannel(button Button) (int, bool) {
+	switch button {
+	case ButtonUp:
+		return 1, true
+	case ButtonDown:
+		return 2, true
+	default:
+		return 0, false
+	}
+}
+
+// analogSampleInterval is the time between readings while sampling
+const analogSampleInterval = 10 * time.Millisecond
+
+// AnalogSamples accumulates raw readings of an analog button channel
+type AnalogSamples struct {
+	Min   float64
+	Max   float64
+	Sum   float64
+	Count int
+}
+
+// Add records a reading
+func (s *AnalogSamples) Add(value float64) {
+	if s.Count == 0 || value < s.Min {
+		s.Min = value
+	}
+	if s.Count == 0 || value > s.Max {
+		s.Max = value
+	}
+	s.Sum += value
+	s.Count++
+}
+
+// Mean returns the average reading
+func (s AnalogSamples) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+func (s AnalogSamples) String() string {
+	if s.Count == 0 {
+		return "no samples"
+	}
+	return fmt.Sprintf("%.0f-%.0f (mean %.1f, %d samples)", s.Min, s.Max, s.Mean(), s.Count)
+}
+
+// CalibrateAnalogThreshold computes the pressed window of a button from
+// readings taken while it was released and while it was held. The window
+// edge facing the idle readings sits halfway across the gap between them,
+// the opposite edge is mirrored the same distance beyond the pressed
+// readings, and the hysteresis is a quarter of that margin.
+func CalibrateAnalogThreshold(idle, pressed AnalogSamples) (AnalogThreshold, error) {
+	if idle.Count == 0 || pressed.Count == 0 {
+		return AnalogThreshold{}, fmt.Errorf("calibration needs idle and pressed samples")
+	}
+
+	var lower, upper, margin float64
+	switch {
+	case pressed.Min > idle.Max:
+		// Pressing raises the reading
+		lower = (idle.Max + pressed.Min) / 2
+		margin = pressed.Min - lower
+		upper = pressed.Max + margin
+	case pressed.Max < idle.Min:
+		// Pressing lowers the reading
+		upper = (idle.Min + pressed.Max) / 2
+		margin = upper - pressed.Max
+		lower = pressed.Min - margin
+	default:
+		return AnalogThreshold{}, fmt.Errorf("pressed readings %s overlap idle readings %s", pressed, idle)
+	}
+
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	return AnalogThreshold{
+		Lower:      round(lower),
+		Upper:      round(upper),
+		Hysteresis: round(margin / 4),
+	}, nil
+}
+
+// SampleAnalogInput reads an analog input for the given duration. Each
+// reading is passed to onReading, if set, so callers can show live values.
+func SampleAnalogInput(card interface {
+	RawAnalogInput(channel int) (uint16, error)
+}, channel int, duration time.Duration, onReading func(uint16)) (AnalogSamples, error) {
+	var samples AnalogSamples
+
+	deadline := time.Now().Add(duration)
+	for time.Now().Before(deadline) {
+		value, err := card.RawAnalogInput(channel)
+		if err != nil {
+			return samples, err
+		}
+		samples.Add(float64(value))
+		if onReading != nil {
+			onReading(value)
+		}
+		time.Sleep(analogSampleInterval)
+	}
+
+	return samples, nil
+}
@@ -7,54 +7,41 @@ import (
 
 // ExampleMegaIndUsage demonstrates how to use the MegaInd controller
 func ExampleMegaIndUsage() {
-	// Get the singleton controller instance
-	controller := GetMegaIndController()
+	// Create a controller instance
+	controller := NewMegaIndController()
 	
-	// Initialize with I2C bus 1 (typical for Raspberry Pi)
-	if err := controller.Init(1); err != nil {
+	// Initialize with the card at stack 0 on I2C bus 1 (typical for Raspberry Pi)
+	if err := controller.Init("1", 0); err != nil {
 		log.Fatalf("Failed to initialize MegaInd controller: %v", err)
 	}
 	defer controller.Dispose()
 	
 	// Start listening for button events
+	events, unsubscribe := controller.Subscribe()
+	defer unsubscribe()
 	go func() {
-		inputChannel := controller.GetInputChannel()
-		for buttonMap := range inputChannel {
-			// Check individual buttons
-			if buttonMap.Get(ButtonA) {
-				log.Println("Button A pressed!")
-				// Light up LED 0 when A is pressed
+		for event := range events {
+			log.Printf("Button event: %v", event)
+
+			switch {
+			case event.Button == ButtonA && event.Type == ButtonPressed:
+				// Light up LED 0 while A is held
 				controller.LightButton(0, 50)
-			} else {
+			case event.Button == ButtonA && event.Type == ButtonReleased:
 				controller.LightButton(0, 0) // Turn off LED 0
-			}
-			
-			if buttonMap.Get(ButtonB) {
-				log.Println("Button B pressed!")
+			case event.Button == ButtonB && event.Type == ButtonPressed:
 				// Start flashing LED 1 when B is pressed
 				controller.StartFlashing(1, 75, 500*time.Millisecond)
-			}
-			
-			if buttonMap.Get(ButtonX) {
-				log.Println("Button X pressed!")
-				// Stop flashing all LEDs when X is pressed
+			case event.Button == ButtonX && event.Type == ButtonPressed:
+				// Stop flashing when X is pressed
 				controller.StopFlashing(1)
-			}
-			
-			if buttonMap.Get(ButtonY) {
-				log.Println("Button Y pressed!")
-				// Set fan speed to 50% when Y is pressed
+			case event.Button == ButtonY && event.Type == ButtonDoublePress:
+				// Set fan speed to 50% on a double press of Y
 				controller.SetFanSpeed(50)
-			}
-			
-			if buttonMap.Get(ButtonUp) {
-				log.Println("Up button pressed!")
-				// Increase fan speed
+			case event.Button == ButtonUp && event.Type == ButtonLongPress:
+				// Full fan speed on a long press of Up
 				controller.SetFanSpeed(100)
-			}
-			
-			if buttonMap.Get(ButtonDown) {
-				log.Println("Down button pressed!")
+			case event.Button == ButtonDown && event.Type == ButtonPressed:
 				// Decrease fan speed
 				controller.SetFanSpeed(25)
 			}
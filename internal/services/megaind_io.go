@@ -0,0 +1,376 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// I/O registers beyond the buttons, LEDs and fan
+	openDrainValueRegister    = 0x00
+	openDrainSetRegister      = 0x01
+	openDrainClearRegister    = 0x02
+	analogOutputRegister1     = 0x04
+	currentInputRegister1     = 0x2C
+	optoRisingEnableRegister  = 59
+	optoFallingEnableRegister = 60
+	optoCountResetRegister    = 62
+	optoCountRegister1        = 63
+	temperatureRegister       = 79
+	supplyVoltageRegister     = 80
+	rtcRegister               = 85
+	rtcSetRegister            = 91
+	rtcCommandRegister        = 97
+
+	// rtcSetCommand latches the RTC set registers into the clock
+	rtcSetCommand = 0xAA
+
+	// Stack positions are set by the card's address jumpers
+	maxStackLevel = 7
+
+	// Channel counts
+	openDrainChannels    = 4
+	analogOutputChannels = 4
+	analogInputChannels  = 4
+	optoChannels         = 4
+	currentInputChannels = 4
+
+	// Output range
+	analogOutputMaxVolts = 10.0
+)
+
+// MegaIndCard is a single MegaInd card at one stack position on a bus.
+// Register access goes through a transport that retries failures and
+// reconnects when the card disappears.
+type MegaIndCard struct {
+	bus     *resilientBus
+	busName string
+	stack   int
+}
+
+// NewMegaIndCard wraps an opened register bus as the card at the given
+// stack position. The card takes ownership of the bus.
+func NewMegaIndCard(bus RegisterBus, busName string, stack int) *MegaIndCard {
+	name := fmt.Sprintf("stack %d on bus %q", stack, busName)
+	return &MegaIndCard{
+		bus:     newResilientBus(bus, name),
+		busName: busName,
+		stack:   stack,
+	}
+}
+
+// OpenMegaIndCard opens the card at a stack position (0-7) on the named I2C
+// bus and checks that it responds
+func OpenMegaIndCard(busName string, stack int) (*MegaIndCard, error) {
+	if stack < 0 || stack > maxStackLevel {
+		return nil, fmt.Errorf("stack level must be between 0 and %d, got: %d", maxStackLevel, stack)
+	}
+
+	bus, err := OpenI2CRegisterBus(busName, StackAddress(stack))
+	if err != nil {
+		return nil, err
+	}
+
+	card := NewMegaIndCard(bus, busName, stack)
+	if _, err := card.readByteRegister(digitalInputRegister); err != nil {
+		card.Close()
+		return nil, fmt.Errorf("no response from MegaInd stack %d at 0x%02X on bus %q: %w",
+			stack, StackAddress(stack), busName, err)
+	}
+
+	return card, nil
+}
+
+// StackAddress returns the I2C address of the card at a stack position
+func StackAddress(stack int) uint16 {
+	return deviceAddress + uint16(stack)
+}
+
+// Bus returns the name of the bus the card is on
+func (c *MegaIndCard) Bus() string {
+	return c.busName
+}
+
+// Stack returns the card's stack position
+func (c *MegaIndCard) Stack() int {
+	return c.stack
+}
+
+// Health returns how well the card is responding
+func (c *MegaIndCard) Health() MegaIndHealth {
+	return c.TransportStats().Health
+}
+
+// TransportStats returns the card's error, latency and reconnect counters
+func (c *MegaIndCard) TransportStats() TransportStats {
+	if c == nil || c.bus == nil {
+		return TransportStats{Health: HealthOffline}
+	}
+	return c.bus.Stats()
+}
+
+// Close releases the card's bus; closing more than once is harmless
+func (c *MegaIndCard) Close() error {
+	if c == nil || c.bus == nil {
+		return nil
+	}
+	return c.bus.Close()
+}
+
+// tx performs a raw register transaction on the bus
+func (c *MegaIndCard) tx(write, read []byte) error {
+	if c == nil || c.bus == nil {
+		return fmt.Errorf("MegaInd card is not attached to a bus")
+	}
+	return c.bus.Tx(write, read)
+}
+
+// readByteRegister reads a single byte from an I2C register
+func (c *MegaIndCard) readByteRegister(register int) (uint8, error) {
+	read := []byte{0}
+	write := []byte{uint8(register)}
+
+	if err := c.tx(write, read); err != nil {
+		return 0, err
+	}
+
+	return read[0], nil
+}
+
+// readWordRegister reads a 16-bit word from an I2C register
+func (c *MegaIndCard) readWordRegister(register int) (uint16, error) {
+	read := make([]byte, 2)
+	write := []byte{uint8(register)}
+
+	if err := c.tx(write, read); err != nil {
+		return 0, err
+	}
+
+	// Assuming little-endian format
+	return uint16(read[0]) | (uint16(read[1]) << 8), nil
+}
+
+// writeByteRegister writes a single byte to an I2C register
+func (c *MegaIndCard) writeByteRegister(register int, value uint8) error {
+	write := []byte{uint8(register), value}
+	return c.tx(write, nil)
+}
+
+// writeWordRegister writes a little-endian 16-bit word to an I2C register
+func (c *MegaIndCard) writeWordRegister(register int, value uint16) error {
+	write := []byte{uint8(register), uint8(value), uint8(value >> 8)}
+	return c.tx(write, nil)
+}
+
+// validateChannel checks a 1-based channel number
+func validateChannel(kind string, channel, count int) error {
+	if channel < 1 || channel > count {
+		return fmt.Errorf("%s channel must be between 1 and %d, got: %d", kind, count, channel)
+	}
+	return nil
+}
+
+// SetOpenDrainOutput switches an open-drain output (1-4) on or off. On the
+// button card, outputs 1-3 also carry the fan and LED PWM.
+func (c *MegaIndCard) SetOpenDrainOutput(channel int, on bool) error {
+	if err := validateChannel("open-drain output", channel, openDrainChannels); err != nil {
+		return err
+	}
+
+	register := openDrainClearRegister
+	if on {
+		register = openDrainSetRegister
+	}
+	if err := c.writeByteRegister(register, uint8(channel)); err != nil {
+		return fmt.Errorf("failed to set open-drain output %d: %w", channel, err)
+	}
+
+	return nil
+}
+
+// OpenDrainOutputs returns the open-drain output states as a bitmask, with
+// output 1 in bit 0
+func (c *MegaIndCard) OpenDrainOutputs() (uint8, error) {
+	value, err := c.readByteRegister(openDrainValueRegister)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read open-drain outputs: %w", err)
+	}
+	return value & 0x0F, nil
+}
+
+// SetAnalogOutput sets a 0-10V output (1-4) in volts
+func (c *MegaIndCard) SetAnalogOutput(channel int, volts float64) error {
+	if err := validateChannel("analog output", channel, analogOutputChannels); err != nil {
+		return err
+	}
+	if volts < 0 || volts > analogOutputMaxVolts {
+		return fmt.Errorf("analog output must be between 0 and %.0f V, got: %.2f", analogOutputMaxVolts, volts)
+	}
+
+	register := analogOutputRegister1 + 2*(channel-1)
+	millivolts := uint16(math.Round(volts * 1000))
+	if err := c.writeWordRegister(register, millivolts); err != nil {
+		return fmt.Errorf("failed to set analog output %d: %w", channel, err)
+	}
+
+	return nil
+}
+
+// AnalogOutput returns the voltage set on a 0-10V output (1-4)
+func (c *MegaIndCard) AnalogOutput(channel int) (float64, error) {
+	if err := validateChannel("analog output", channel, analogOutputChannels); err != nil {
+		return 0, err
+	}
+
+	millivolts, err := c.readWordRegister(analogOutputRegister1 + 2*(channel-1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read analog output %d: %w", channel, err)
+	}
+	return float64(millivolts) / 1000, nil
+}
+
+// RawAnalogInput returns the reading of a 0-10V input (1-4) in millivolts,
+// the unit AnalogThreshold compares against
+func (c *MegaIndCard) RawAnalogInput(channel int) (uint16, error) {
+	if err := validateChannel("analog input", channel, analogInputChannels); err != nil {
+		return 0, err
+	}
+
+	value, err := c.readWordRegister(analogInputRegister1 + 2*(channel-1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read analog input %d: %w", channel, err)
+	}
+	return value, nil
+}
+
+// OptoInputs returns the opto-isolated input levels as a bitmask, with
+// input 1 in bit 0. On the button card, inputs 1-4 are buttons A, B, X and Y.
+func (c *MegaIndCard) OptoInputs() (uint8, error) {
+	value, err := c.readByteRegister(digitalInputRegister)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read opto inputs: %w", err)
+	}
+	return value & 0x0F, nil
+}
+
+// EnableOptoCounter selects which edges of an opto input (1-4) are counted
+func (c *MegaIndCard) EnableOptoCounter(channel int, rising, falling bool) error {
+	if err := validateChannel("opto input", channel, optoChannels); err != nil {
+		return err
+	}
+
+	bit := uint8(1) << uint(channel-1)
+	for _, edge := range []struct {
+		register int
+		enabled  bool
+	}{
+		{optoRisingEnableRegister, rising},
+		{optoFallingEnableRegister, falling},
+	} {
+		mask, err := c.readByteRegister(edge.register)
+		if err != nil {
+			return fmt.Errorf("failed to read opto counter configuration: %w", err)
+		}
+		if edge.enabled {
+			mask |= bit
+		} else {
+			mask &^= bit
+		}
+		if err := c.writeByteRegister(edge.register, mask); err != nil {
+			return fmt.Errorf("failed to configure opto counter %d: %w", channel, err)
+		}
+	}
+
+	return nil
+}
+
+// OptoCount returns the number of edges counted on an opto input (1-4)
+func (c *MegaIndCard) OptoCount(channel int) (uint32, error) {
+	if err := validateChannel("opto input", channel, optoChannels); err != nil {
+		return 0, err
+	}
+
+	read := make([]byte, 4)
+	write := []byte{uint8(optoCountRegister1 + 4*(channel-1))}
+	if err := c.tx(write, read); err != nil {
+		return 0, fmt.Errorf("failed to read opto counter %d: %w", channel, err)
+	}
+
+	return uint32(read[0]) | uint32(read[1])<<8 | uint32(read[2])<<16 | uint32(read[3])<<24, nil
+}
+
+// ResetOptoCount clears the edge counter of an opto input (1-4)
+func (c *MegaIndCard) ResetOptoCount(channel int) error {
+	if err := validateChannel("opto input", channel, optoChannels); err != nil {
+		return err
+	}
+
+	if err := c.writeByteRegister(optoCountResetRegister, uint8(channel)); err != nil {
+		return fmt.Errorf("failed to reset opto counter %d: %w", channel, err)
+	}
+	return nil
+}
+
+// CurrentInput returns the reading of a 4-20mA input (1-4) in milliamps. A
+// reading below 4mA usually means an open loop.
+func (c *MegaIndCard) CurrentInput(channel int) (float64, error) {
+	if err := validateChannel("4-20mA input", channel, currentInputChannels); err != nil {
+		return 0, err
+	}
+
+	microamps, err := c.readWordRegister(currentInputRegister1 + 2*(channel-1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read 4-20mA input %d: %w", channel, err)
+	}
+	return float64(microamps) / 1000, nil
+}
+
+// SupplyVoltage returns the card's supply voltage in volts
+func (c *MegaIndCard) SupplyVoltage() (float64, error) {
+	millivolts, err := c.readWordRegister(supplyVoltageRegister)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read supply voltage: %w", err)
+	}
+	return float64(millivolts) / 1000, nil
+}
+
+// Temperature returns the card's onboard temperature in degrees Celsius
+func (c *MegaIndCard) Temperature() (float64, error) {
+	value, err := c.readByteRegister(temperatureRegister)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read temperature: %w", err)
+	}
+	return float64(int8(value)), nil
+}
+
+// RTC returns the time of the card's real-time clock
+func (c *MegaIndCard) RTC() (time.Time, error) {
+	read := make([]byte, 6)
+	if err := c.tx([]byte{rtcRegister}, read); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read RTC: %w", err)
+	}
+
+	return time.Date(2000+int(read[0]), time.Month(read[1]), int(read[2]),
+		int(read[3]), int(read[4]), int(read[5]), 0, time.Local), nil
+}
+
+// SetRTC sets the card's real-time clock
+func (c *MegaIndCard) SetRTC(t time.Time) error {
+	t = t.In(time.Local)
+	if t.Year() < 2000 || t.Year() > 2255 {
+		return fmt.Errorf("RTC year must be between 2000 and 2255, got: %d", t.Year())
+	}
+
+	write := []byte{
+		rtcSetRegister,
+		uint8(t.Year() - 2000), uint8(t.Month()), uint8(t.Day()),
+		uint8(t.Hour()), uint8(t.Minute()), uint8(t.Second()),
+		rtcSetCommand,
+	}
+	if err := c.tx(write, nil); err != nil {
+		return fmt.Errorf("failed to set RTC: %w", err)
+	}
+
+	return nil
+}
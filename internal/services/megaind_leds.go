@@ -0,0 +1,367 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ledFrameInterval is the frame clock of the LED compositor
+const ledFrameInterval = 20 * time.Millisecond
+
+// LEDPriority orders overlapping LED animations; higher priorities win
+type LEDPriority int
+
+const (
+	// LEDPriorityIdle is for ambient patterns such as an idle breathe
+	LEDPriorityIdle LEDPriority = 10
+	// LEDPriorityNormal is for feedback patterns such as blinking
+	LEDPriorityNormal LEDPriority = 50
+	// LEDPriorityAlert is for patterns that must override everything else
+	LEDPriorityAlert LEDPriority = 90
+)
+
+// LEDPattern computes LED brightness over time
+type LEDPattern interface {
+	// Level returns the brightness (0-100) of the index-th of count LEDs
+	// driven by the pattern, elapsed after the pattern started
+	Level(index, count int, elapsed time.Duration) int
+	// Duration returns how long the pattern runs; zero runs until stopped
+	Duration() time.Duration
+}
+
+// ledPattern implements LEDPattern with a level function
+type ledPattern struct {
+	duration time.Duration
+	level    func(index, count int, elapsed time.Duration) int
+}
+
+func (p ledPattern) Level(index, count int, elapsed time.Duration) int {
+	return p.level(index, count, elapsed)
+}
+
+func (p ledPattern) Duration() time.Duration {
+	return p.duration
+}
+
+// phase returns the position within a repeating period
+func phase(elapsed, period time.Duration) time.Duration {
+	if period <= 0 {
+		return 0
+	}
+	return elapsed % period
+}
+
+// SolidPattern holds a constant brightness
+func SolidPattern(brightness int) LEDPattern {
+	return ledPattern{level: func(int, int, time.Duration) int {
+		return brightness
+	}}
+}
+
+// BlinkPattern is on for the first half of each period and off for the rest
+func BlinkPattern(brightness int, period time.Duration) LEDPattern {
+	return ledPattern{level: func(_, _ int, elapsed time.Duration) int {
+		if phase(elapsed, period) < period/2 {
+			return brightness
+		}
+		return 0
+	}}
+}
+
+// BreathePattern fades smoothly from off to brightness and back each period
+func BreathePattern(brightness int, period time.Duration) LEDPattern {
+	return ledPattern{level: func(_, _ int, elapsed time.Duration) int {
+		if period <= 0 {
+			return brightness
+		}
+		angle := 2 * math.Pi * float64(phase(elapsed, period)) / float64(period)
+		return int(math.Round(float64(brightness) * (1 - math.Cos(angle)) / 2))
+	}}
+}
+
+// ChasePattern lights one LED at a time, moving to the next every step
+func ChasePattern(brightness int, step time.Duration) LEDPattern {
+	return ledPattern{level: func(index, count int, elapsed time.Duration) int {
+		if step <= 0 || count == 0 {
+			return brightness
+		}
+		if int(elapsed/step)%count == index {
+			return brightness
+		}
+		return 0
+	}}
+}
+
+// HeartbeatPattern gives a strong and a weaker beat at the start of each
+// period, followed by a rest
+func HeartbeatPattern(brightness int, period time.Duration) LEDPattern {
+	return ledPattern{level: func(_, _ int, elapsed time.Duration) int {
+		p := phase(elapsed, period)
+		switch {
+		case p < period/10:
+			return brightness
+		case p >= period*2/10 && p < period*3/10:
+			return brightness * 6 / 10
+		default:
+			return 0
+		}
+	}}
+}
+
+// FlashPattern flashes count times, on and off for interval each, and then
+// ends so lower layers show through again
+func FlashPattern(brightness, count int, interval time.Duration) LEDPattern {
+	return ledPattern{
+		duration: 2 * time.Duration(count) * interval,
+		level: func(_, _ int, elapsed time.Duration) int {
+			if phase(elapsed, 2*interval) < interval {
+				return brightness
+			}
+			return 0
+		},
+	}
+}
+
+// LEDAnimation places a pattern on a set of LEDs
+type LEDAnimation struct {
+	// LEDs lists the LEDs driven by the pattern, in pattern order; empty
+	// means every LED
+	LEDs     []int
+	Priority LEDPriority
+	Pattern  LEDPattern
+	// Start is when the pattern begins; zero means when it is played
+	Start time.Time
+}
+
+// LEDAnimationID identifies a playing animation
+type LEDAnimationID int
+
+// LEDFrame is the composited brightness of every LED at a point in time
+type LEDFrame struct {
+	Time   time.Time
+	Levels []int
+}
+
+// LEDOutput writes the brightness of a single LED
+type LEDOutput func(led int, brightness int) error
+
+// ledLayer is an animation playing on the compositor
+type ledLayer struct {
+	id        LEDAnimationID
+	animation LEDAnimation
+}
+
+// done reports whether a finite animation has ended at now
+func (l *ledLayer) done(now time.Time) bool {
+	duration := l.animation.Pattern.Duration()
+	return duration > 0 && now.Sub(l.animation.Start) >= duration
+}
+
+// LEDEngine composites layered LED animations on a fixed frame clock. Each
+// frame, every LED shows the highest priority animation that drives it, the
+// most recently played one winning ties, or its base level when no
+// animation does. Only changed levels are written to the output.
+type LEDEngine struct {
+	mu     sync.Mutex
+	count  int
+	output LEDOutput
+	base   []int
+	last   []int
+	layers []*ledLayer
+	nextID LEDAnimationID
+}
+
+// NewLEDEngine creates a compositor for count LEDs
+func NewLEDEngine(count int, output LEDOutput) *LEDEngine {
+	e := &LEDEngine{
+		count:  count,
+		output: output,
+		base:   make([]int, count),
+		last:   make([]int, count),
+	}
+	// Force the first frame to write every LED
+	for i := range e.last {
+		e.last[i] = -1
+	}
+	return e
+}
+
+// Count returns the number of LEDs
+func (e *LEDEngine) Count() int {
+	return e.count
+}
+
+// validateLED checks an LED index
+func (e *LEDEngine) validateLED(led int) error {
+	if led < 0 || led >= e.count {
+		return fmt.Errorf("invalid LED index: %d", led)
+	}
+	return nil
+}
+
+// validateBrightness checks a brightness value
+func validateBrightness(brightness int) error {
+	if brightness < 0 || brightness > 100 {
+		return fmt.Errorf("brightness must be between 0 and 100, got: %d", brightness)
+	}
+	return nil
+}
+
+// SetBase sets the level an LED shows when no animation drives it
+func (e *LEDEngine) SetBase(led, brightness int) error {
+	if err := e.validateLED(led); err != nil {
+		return err
+	}
+	if err := validateBrightness(brightness); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.base[led] = brightness
+	return nil
+}
+
+// Play adds an animation and returns its ID
+func (e *LEDEngine) Play(animation LEDAnimation) (LEDAnimationID, error) {
+	if animation.Pattern == nil {
+		return 0, fmt.Errorf("LED animation has no pattern")
+	}
+	for _, led := range animation.LEDs {
+		if err := e.validateLED(led); err != nil {
+			return 0, err
+		}
+	}
+	if len(animation.LEDs) == 0 {
+		animation.LEDs = make([]int, e.count)
+		for i := range animation.LEDs {
+			animation.LEDs[i] = i
+		}
+	}
+	if animation.Start.IsZero() {
+		animation.Start = time.Now()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.nextID++
+	e.layers = append(e.layers, &ledLayer{id: e.nextID, animation: animation})
+
+	// Keep layers sorted by priority; the stable sort preserves play order
+	sort.SliceStable(e.layers, func(i, j int) bool {
+		return e.layers[i].animation.Priority < e.layers[j].animation.Priority
+	})
+
+	return e.nextID, nil
+}
+
+// Stop removes an animation, reporting whether it was still playing
+func (e *LEDEngine) Stop(id LEDAnimationID) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, layer := range e.layers {
+		if layer.id == id {
+			e.layers = append(e.layers[:i], e.layers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// StopAll removes every animation
+func (e *LEDEngine) StopAll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.layers = nil
+}
+
+// Playing reports whether an animation is still playing
+func (e *LEDEngine) Playing(id LEDAnimationID) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, layer := range e.layers {
+		if layer.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Render composites the LED levels at now without writing them
+func (e *LEDEngine) Render(now time.Time) []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.render(now)
+}
+
+// render composites the LED levels at now; callers must hold e.mu
+func (e *LEDEngine) render(now time.Time) []int {
+	levels := make([]int, e.count)
+	copy(levels, e.base)
+
+	// Paint from the lowest to the highest priority so the top layer wins
+	for _, layer := range e.layers {
+		if now.Before(layer.animation.Start) || layer.done(now) {
+			continue
+		}
+		elapsed := now.Sub(layer.animation.Start)
+		count := len(layer.animation.LEDs)
+		for index, led := range layer.animation.LEDs {
+			level := layer.animation.Pattern.Level(index, count, elapsed)
+			levels[led] = min(max(level, 0), 100)
+		}
+	}
+
+	return levels
+}
+
+// Timeline renders frames from start to end at the given step
+func (e *LEDEngine) Timeline(start, end time.Time, step time.Duration) []LEDFrame {
+	if step <= 0 {
+		step = ledFrameInterval
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var frames []LEDFrame
+	for t := start; !t.After(end); t = t.Add(step) {
+		frames = append(frames, LEDFrame{Time: t, Levels: e.render(t)})
+	}
+	return frames
+}
+
+// Step renders the frame at now, drops finished animations and writes the
+// LEDs whose level changed
+func (e *LEDEngine) Step(now time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	levels := e.render(now)
+
+	layers := e.layers[:0]
+	for _, layer := range e.layers {
+		if !layer.done(now) {
+			layers = append(layers, layer)
+		}
+	}
+	e.layers = layers
+
+	for led, level := range levels {
+		if level == e.last[led] {
+			continue
+		}
+		if err := e.output(led, level); err != nil {
+			return fmt.Errorf("failed to set LED %d brightness: %w", led, err)
+		}
+		e.last[led] = level
+	}
+
+	return nil
+}
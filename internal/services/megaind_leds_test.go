@@ -0,0 +1,187 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// ledWrite is one brightness written by an LEDEngine
+type ledWrite struct {
+	led, level int
+}
+
+// newRecordingLEDEngine creates an engine that records what it writes
+func newRecordingLEDEngine(count int) (*LEDEngine, *[]ledWrite) {
+	writes := &[]ledWrite{}
+	engine := NewLEDEngine(count, func(led, brightness int) error {
+		*writes = append(*writes, ledWrite{led, brightness})
+		return nil
+	})
+	return engine, writes
+}
+
+// ledColumn returns one LED's level in every frame
+func ledColumn(frames []LEDFrame, led int) []int {
+	levels := make([]int, len(frames))
+	for i, frame := range frames {
+		levels[i] = frame.Levels[led]
+	}
+	return levels
+}
+
+func TestAlertOverridesIdleBreathe(t *testing.T) {
+	engine, _ := newRecordingLEDEngine(2)
+	start := time.Unix(0, 0)
+
+	if _, err := engine.Play(LEDAnimation{
+		Priority: LEDPriorityIdle,
+		Pattern:  BreathePattern(100, time.Second),
+		Start:    start,
+	}); err != nil {
+		t.Fatalf("Play breathe: %v", err)
+	}
+	alert, err := engine.Play(LEDAnimation{
+		LEDs:     []int{0},
+		Priority: LEDPriorityAlert,
+		Pattern:  SolidPattern(80),
+		Start:    start.Add(500 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatalf("Play alert: %v", err)
+	}
+	// A lower priority layer played later stays under the alert
+	if _, err := engine.Play(LEDAnimation{
+		LEDs:     []int{0},
+		Priority: LEDPriorityNormal,
+		Pattern:  SolidPattern(20),
+		Start:    start.Add(700 * time.Millisecond),
+	}); err != nil {
+		t.Fatalf("Play normal: %v", err)
+	}
+
+	frames := engine.Timeline(start, start.Add(time.Second), 250*time.Millisecond)
+	if got, want := ledColumn(frames, 0), []int{0, 50, 80, 80, 80}; !slices.Equal(got, want) {
+		t.Errorf("LED 0 timeline = %v, want %v", got, want)
+	}
+	if got, want := ledColumn(frames, 1), []int{0, 50, 100, 50, 0}; !slices.Equal(got, want) {
+		t.Errorf("LED 1 timeline = %v, want %v", got, want)
+	}
+
+	// Stopping the alert uncovers the next layer down
+	engine.Stop(alert)
+	if got := engine.Render(start.Add(750 * time.Millisecond))[0]; got != 20 {
+		t.Errorf("LED 0 after stopping the alert = %d, want 20", got)
+	}
+}
+
+func TestFlashPatternRevertsToBase(t *testing.T) {
+	engine, _ := newRecordingLEDEngine(1)
+	start := time.Unix(0, 0)
+
+	if err := engine.SetBase(0, 30); err != nil {
+		t.Fatalf("SetBase: %v", err)
+	}
+	id, err := engine.Play(LEDAnimation{
+		Priority: LEDPriorityNormal,
+		Pattern:  FlashPattern(100, 3, 100*time.Millisecond),
+		Start:    start,
+	})
+	if err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+
+	frames := engine.Timeline(start, start.Add(800*time.Millisecond), 50*time.Millisecond)
+	want := []int{100, 100, 0, 0, 100, 100, 0, 0, 100, 100, 0, 0, 30, 30, 30, 30, 30}
+	if got := ledColumn(frames, 0); !slices.Equal(got, want) {
+		t.Errorf("timeline = %v, want %v", got, want)
+	}
+
+	if err := engine.Step(start.Add(550 * time.Millisecond)); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if !engine.Playing(id) {
+		t.Error("flash stopped before its last flash ended")
+	}
+	if err := engine.Step(start.Add(600 * time.Millisecond)); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if engine.Playing(id) {
+		t.Error("flash still playing after 3 flashes")
+	}
+}
+
+func TestChasePatternOrder(t *testing.T) {
+	engine, _ := newRecordingLEDEngine(3)
+	start := time.Unix(0, 0)
+
+	// The chase follows the order the LEDs are listed in
+	if _, err := engine.Play(LEDAnimation{
+		LEDs:     []int{2, 0, 1},
+		Priority: LEDPriorityNormal,
+		Pattern:  ChasePattern(60, 100*time.Millisecond),
+		Start:    start,
+	}); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+
+	var lit []int
+	for _, frame := range engine.Timeline(start, start.Add(550*time.Millisecond), 100*time.Millisecond) {
+		on := -1
+		for led, level := range frame.Levels {
+			switch {
+			case level == 0:
+			case on >= 0:
+				t.Fatalf("LEDs %d and %d both lit at %v", on, led, frame.Time.Sub(start))
+			case level != 60:
+				t.Fatalf("LED %d at %d, want 60", led, level)
+			default:
+				on = led
+			}
+		}
+		lit = append(lit, on)
+	}
+	if want := []int{2, 0, 1, 2, 0, 1}; !slices.Equal(lit, want) {
+		t.Errorf("chase order = %v, want %v", lit, want)
+	}
+}
+
+func TestStepWritesOnlyChangedLevels(t *testing.T) {
+	engine, writes := newRecordingLEDEngine(2)
+	start := time.Unix(0, 0)
+
+	step := func(elapsed time.Duration, want ...ledWrite) {
+		t.Helper()
+		*writes = nil
+		if err := engine.Step(start.Add(elapsed)); err != nil {
+			t.Fatalf("Step at %v: %v", elapsed, err)
+		}
+		if !slices.Equal(*writes, want) {
+			t.Errorf("writes at %v = %v, want %v", elapsed, *writes, want)
+		}
+	}
+
+	// The first frame sets every LED
+	step(0, ledWrite{0, 0}, ledWrite{1, 0})
+	step(20 * time.Millisecond)
+
+	if err := engine.SetBase(1, 50); err != nil {
+		t.Fatalf("SetBase: %v", err)
+	}
+	step(40*time.Millisecond, ledWrite{1, 50})
+	step(60 * time.Millisecond)
+
+	if _, err := engine.Play(LEDAnimation{
+		LEDs:     []int{0},
+		Priority: LEDPriorityNormal,
+		Pattern:  BlinkPattern(100, 200*time.Millisecond),
+		Start:    start.Add(100 * time.Millisecond),
+	}); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	step(80 * time.Millisecond)
+	step(100*time.Millisecond, ledWrite{0, 100})
+	step(150 * time.Millisecond)
+	step(200*time.Millisecond, ledWrite{0, 0})
+	step(300*time.Millisecond, ledWrite{0, 100})
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RegisterWrite records a single register write made to the simulator
+type RegisterWrite struct {
+	Register uint8
+	Value    uint8
+	Time     time.Time
+}
+
+// SimulatedMegaInd is an in-memory, register-level emulation of the MegaInd
+// card. It implements RegisterBus so a MegaIndController can run against it
+// on machines without the hardware, and lets tests script inputs and inspect
+// what the controller wrote to the output registers. Writes are only
+// recorded after RecordWrites, so a long-running simulator does not grow.
+type SimulatedMegaInd struct {
+	mu        sync.Mutex
+	registers map[uint8]uint8
+	writes    []RegisterWrite
+	recording bool
+	closed    bool
+
+	// Fault injection
+	disconnected bool
+	failNext     int
+	errorRate    float64
+	latency      time.Duration
+}
+
+// simulatedRegisters lists every register byte the simulator emulates
+var simulatedRegisters = registerRanges(
+	[2]uint8{openDrainValueRegister, openDrainClearRegister},
+	[2]uint8{digitalInputRegister, digitalInputRegister},
+	[2]uint8{analogOutputRegister1, analogOutputRegister1 + 2*analogOutputChannels - 1},
+	[2]uint8{pwmFanOutputRegister, pwmLedOutputRegister1 + 1},
+	[2]uint8{analogInputRegister1, analogInputRegister1 + 2*analogInputChannels - 1},
+	[2]uint8{currentInputRegister1, currentInputRegister1 + 2*currentInputChannels - 1},
+	[2]uint8{optoRisingEnableRegister, optoFallingEnableRegister},
+	[2]uint8{optoCountResetRegister, optoCountRegister1 + 4*optoChannels - 1},
+	[2]uint8{temperatureRegister, supplyVoltageRegister + 1},
+	[2]uint8{rtcRegister, rtcCommandRegister},
+)
+
+// registerRanges expands inclusive register ranges
+func registerRanges(ranges ...[2]uint8) []uint8 {
+	var registers []uint8
+	for _, r := range ranges {
+		for reg := int(r[0]); reg <= int(r[1]); reg++ {
+			registers = append(registers, uint8(reg))
+		}
+	}
+	return registers
+}
+
+// Onboard readings reported by a fresh simulator
+const (
+	simulatedTemperature   = 35
+	simulatedSupplyVoltage = 24000
+)
+
+// simulatedPressedLevel is the analog reading produced by a pressed Up/Down
+// button, between ainLowerLimit and ainUpperLimit
+const simulatedPressedLevel = 128
+
+// NewSimulatedMegaInd creates a simulator with all buttons released
+func NewSimulatedMegaInd() *SimulatedMegaInd {
+	s := &SimulatedMegaInd{
+		registers: make(map[uint8]uint8),
+	}
+	for _, reg := range simulatedRegisters {
+		s.registers[reg] = 0
+	}
+
+	// Digital inputs are active low
+	s.registers[digitalInputRegister] = 0xFF
+
+	s.registers[temperatureRegister] = simulatedTemperature
+	s.setWord(supplyVoltageRegister, simulatedSupplyVoltage)
+	s.setRTC(time.Now())
+
+	return s
+}
+
+// Tx performs a register transaction against the emulated register file
+func (s *SimulatedMegaInd) Tx(w, r []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("simulated bus is closed")
+	}
+	if err := s.injectFault(); err != nil {
+		return err
+	}
+	if len(w) == 0 {
+		return fmt.Errorf("transaction must select a register")
+	}
+
+	start := w[0]
+	data := w[1:]
+
+	// Validate the whole transaction before touching any register
+	for i := range data {
+		if _, ok := s.registers[start+uint8(i)]; !ok {
+			return fmt.Errorf("unsupported register 0x%02X", start+uint8(i))
+		}
+	}
+	for i := range r {
+		if _, ok := s.registers[start+uint8(i)]; !ok {
+			return fmt.Errorf("unsupported register 0x%02X", start+uint8(i))
+		}
+	}
+
+	now := time.Now()
+	for i, value := range data {
+		reg := start + uint8(i)
+		s.registers[reg] = value
+		if s.recording {
+			s.writes = append(s.writes, RegisterWrite{Register: reg, Value: value, Time: now})
+		}
+		s.applyCommand(reg, value)
+	}
+
+	for i := range r {
+		r[i] = s.registers[start+uint8(i)]
+	}
+
+	return nil
+}
+
+// injectFault applies the configured faults to a transaction; callers must
+// hold s.mu
+func (s *SimulatedMegaInd) injectFault() error {
+	if s.latency > 0 {
+		time.Sleep(s.latency)
+	}
+	if s.disconnected {
+		return fmt.Errorf("simulated device is not responding")
+	}
+	if s.failNext > 0 {
+		s.failNext--
+		return fmt.Errorf("simulated transaction failure")
+	}
+	if s.errorRate > 0 && rand.Float64() < s.errorRate {
+		return fmt.Errorf("simulated bus error")
+	}
+	return nil
+}
+
+// Disconnect makes every transaction and reopen fail, as if the card had
+// been unplugged
+func (s *SimulatedMegaInd) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnected = true
+}
+
+// Reconnect undoes Disconnect
+func (s *SimulatedMegaInd) Reconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnected = false
+}
+
+// FailNext makes the next n transactions fail
+func (s *SimulatedMegaInd) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext = n
+}
+
+// SetErrorRate makes each transaction fail with the given probability (0-1)
+func (s *SimulatedMegaInd) SetErrorRate(rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errorRate = min(max(rate, 0), 1)
+}
+
+// SetLatency delays every transaction
+func (s *SimulatedMegaInd) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// Reopen reconnects to the simulated card, failing while it is disconnected
+func (s *SimulatedMegaInd) Reopen() (RegisterBus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.disconnected {
+		return nil, fmt.Errorf("simulated device not found")
+	}
+	s.closed = false
+	return s, nil
+}
+
+// Close marks the simulated bus as closed
+func (s *SimulatedMegaInd) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	return nil
+}
+
+// applyCommand emulates the side effects of writing a command register;
+// callers must hold s.mu
+func (s *SimulatedMegaInd) applyCommand(reg, value uint8) {
+	switch reg {
+	case openDrainSetRegister, openDrainClearRegister:
+		if value < 1 || value > openDrainChannels {
+			return
+		}
+		bit := uint8(1) << (value - 1)
+		if reg == openDrainSetRegister {
+			s.registers[openDrainValueRegister] |= bit
+		} else {
+			s.registers[openDrainValueRegister] &^= bit
+		}
+
+	case optoCountResetRegister:
+		if value < 1 || value > optoChannels {
+			return
+		}
+		s.setCount(int(value), 0)
+
+	case rtcCommandRegister:
+		if value == rtcSetCommand {
+			for i := uint8(0); i < 6; i++ {
+				s.registers[rtcRegister+i] = s.registers[rtcSetRegister+i]
+			}
+		}
+	}
+}
+
+// setWord stores a little-endian 16-bit value; callers must hold s.mu
+func (s *SimulatedMegaInd) setWord(reg uint8, value uint16) {
+	s.registers[reg] = uint8(value)
+	s.registers[reg+1] = uint8(value >> 8)
+}
+
+// count returns an opto edge counter; callers must hold s.mu
+func (s *SimulatedMegaInd) count(channel int) uint32 {
+	reg := optoCountRegister1 + 4*uint8(channel-1)
+	var value uint32
+	for i := uint8(0); i < 4; i++ {
+		value |= uint32(s.registers[reg+i]) << (8 * i)
+	}
+	return value
+}
+
+// setCount stores an opto edge counter; callers must hold s.mu
+func (s *SimulatedMegaInd) setCount(channel int, value uint32) {
+	reg := optoCountRegister1 + 4*uint8(channel-1)
+	for i := uint8(0); i < 4; i++ {
+		s.registers[reg+i] = uint8(value >> (8 * i))
+	}
+}
+
+// setRTC loads the clock registers; callers must hold s.mu
+func (s *SimulatedMegaInd) setRTC(t time.Time) {
+	values := []int{t.Year() - 2000, int(t.Month()), t.Day(), t.Hour(), t.Minute(), t.Second()}
+	for i, value := range values {
+		s.registers[rtcRegister+uint8(i)] = uint8(value)
+	}
+}
+
+// PressButton simulates pressing a physical button
+func (s *SimulatedMegaInd) PressButton(button Button) {
+	s.setButton(button, true)
+}
+
+// ReleaseButton simulates releasing a physical button
+func (s *SimulatedMegaInd) ReleaseButton(button Button) {
+	s.setButton(button, false)
+}
+
+// setButton drives the register that backs a button
+func (s *SimulatedMegaInd) setButton(button Button, pressed bool) {
+	switch button {
+	case ButtonA, ButtonB, ButtonX, ButtonY:
+		// Digital inputs are active low
+		s.SetOptoInput(int(button-ButtonA)+1, !pressed)
+
+	case ButtonUp, ButtonDown:
+		var value uint16
+		if pressed {
+			value = simulatedPressedLevel
+		}
+		channel := 1
+		if button == ButtonDown {
+			channel = 2
+		}
+		s.SetAnalogInput(channel, value)
+	}
+}
+
+// SetOptoInput sets the level of opto input 1-4, counting the edge if its
+// counter is enabled
+func (s *SimulatedMegaInd) SetOptoInput(channel int, level bool) error {
+	if err := validateChannel("opto input", channel, optoChannels); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bit := uint8(1) << uint(channel-1)
+	previous := s.registers[digitalInputRegister]&bit != 0
+	if previous == level {
+		return nil
+	}
+
+	edgeRegister := uint8(optoFallingEnableRegister)
+	if level {
+		s.registers[digitalInputRegister] |= bit
+		edgeRegister = optoRisingEnableRegister
+	} else {
+		s.registers[digitalInputRegister] &^= bit
+	}
+	if s.registers[edgeRegister]&bit != 0 {
+		s.setCount(channel, s.count(channel)+1)
+	}
+
+	return nil
+}
+
+// SetAnalogInput sets the raw reading of 0-10V input 1-4 in millivolts.
+// Inputs 1 and 2 carry the Up and Down buttons.
+func (s *SimulatedMegaInd) SetAnalogInput(channel int, value uint16) error {
+	if err := validateChannel("analog input", channel, analogInputChannels); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setWord(analogInputRegister1+2*uint8(channel-1), value)
+	return nil
+}
+
+// SetCurrentInput sets the reading of 4-20mA input 1-4 in milliamps
+func (s *SimulatedMegaInd) SetCurrentInput(channel int, milliamps float64) error {
+	if err := validateChannel("4-20mA input", channel, currentInputChannels); err != nil {
+		return err
+	}
+	if milliamps < 0 || milliamps > 65 {
+		return fmt.Errorf("current must be between 0 and 65 mA, got: %.2f", milliamps)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setWord(currentInputRegister1+2*uint8(channel-1), uint16(milliamps*1000))
+	return nil
+}
+
+// SetTemperature sets the onboard temperature reading in degrees Celsius
+func (s *SimulatedMegaInd) SetTemperature(celsius int) error {
+	if celsius < -40 || celsius > 125 {
+		return fmt.Errorf("temperature must be between -40 and 125 C, got: %d", celsius)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.registers[temperatureRegister] = uint8(int8(celsius))
+	return nil
+}
+
+// SetSupplyVoltage sets the supply voltage reading in volts
+func (s *SimulatedMegaInd) SetSupplyVoltage(volts float64) error {
+	if volts < 0 || volts > 65 {
+		return fmt.Errorf("supply voltage must be between 0 and 65 V, got: %.2f", volts)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setWord(supplyVoltageRegister, uint16(volts*1000))
+	return nil
+}
+
+// Register returns the current value of a register byte
+func (s *SimulatedMegaInd) Register(register uint8) uint8 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.registers[register]
+}
+
+// RecordWrites starts or stops recording register writes for Writes and
+// WritesTo. Stopping discards the writes recorded so far.
+func (s *SimulatedMegaInd) RecordWrites(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recording = enabled
+	if !enabled {
+		s.writes = nil
+	}
+}
+
+// Writes returns a copy of every register write recorded so far
+func (s *SimulatedMegaInd) Writes() []RegisterWrite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writes := make([]RegisterWrite, len(s.writes))
+	copy(writes, s.writes)
+	return writes
+}
+
+// WritesTo returns the sequence of values written to a register
+func (s *SimulatedMegaInd) WritesTo(register uint8) []uint8 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var values []uint8
+	for _, w := range s.writes {
+		if w.Register == register {
+			values = append(values, w.Value)
+		}
+	}
+	return values
+}
+
+// ClearWrites discards the recorded write history
+func (s *SimulatedMegaInd) ClearWrites() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes = nil
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// IOKind identifies the kind of card channel behind a logical I/O name
+type IOKind string
+
+const (
+	IOOpenDrain    IOKind = "open_drain"
+	IOAnalogOutput IOKind = "analog_out"
+	IOOptoInput    IOKind = "opto_in"
+	IOOptoCount    IOKind = "opto_count"
+	IOCurrentInput IOKind = "current_in"
+)
+
+// channels returns how many channels of the kind a card has
+func (k IOKind) channels() (int, bool) {
+	switch k {
+	case IOOpenDrain:
+		return openDrainChannels, true
+	case IOAnalogOutput:
+		return analogOutputChannels, true
+	case IOOptoInput, IOOptoCount:
+		return optoChannels, true
+	case IOCurrentInput:
+		return currentInputChannels, true
+	default:
+		return 0, false
+	}
+}
+
+// IOPoint binds a logical name to a channel on a named card
+type IOPoint struct {
+	Name    string
+	Card    string
+	Kind    IOKind
+	Channel int
+}
+
+// MegaIndStack is a set of MegaInd cards, possibly on several buses, whose
+// I/O is addressed by logical names
+type MegaIndStack struct {
+	mu     sync.RWMutex
+	cards  map[string]*MegaIndCard
+	order  []string
+	points map[string]IOPoint
+}
+
+// NewMegaIndStack creates an empty stack
+func NewMegaIndStack() *MegaIndStack {
+	return &MegaIndStack{
+		cards:  make(map[string]*MegaIndCard),
+		points: make(map[string]IOPoint),
+	}
+}
+
+// DiscoverMegaIndCards probes every stack position on each bus and returns
+// the cards that respond. A bus that cannot be opened is skipped; an error
+// is returned only if no bus could be searched.
+func DiscoverMegaIndCards(busNames []string) ([]*MegaIndCard, error) {
+	var cards []*MegaIndCard
+	var busErrs []error
+
+	for _, busName := range busNames {
+		// Check that the bus exists before probing every address on it
+		bus, err := OpenI2CRegisterBus(busName, StackAddress(0))
+		if err != nil {
+			busErrs = append(busErrs, err)
+			continue
+		}
+		bus.Close()
+
+		for stack := 0; stack <= maxStackLevel; stack++ {
+			card, err := OpenMegaIndCard(busName, stack)
+			if err != nil {
+				continue
+			}
+			log.Printf("Found MegaInd card at stack %d (0x%02X) on I2C bus %q", stack, StackAddress(stack), busName)
+			cards = append(cards, card)
+		}
+	}
+
+	if len(busErrs) == len(busNames) {
+		return nil, errors.Join(busErrs...)
+	}
+	return cards, nil
+}
+
+// AddCard adds a card under a logical name. The stack takes ownership of
+// the card.
+func (s *MegaIndStack) AddCard(name string, card *MegaIndCard) error {
+	if name == "" {
+		return fmt.Errorf("card name must not be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.cards[name]; exists {
+		return fmt.Errorf("duplicate card name: %q", name)
+	}
+	s.cards[name] = card
+	s.order = append(s.order, name)
+	return nil
+}
+
+// Card returns the card with the given name
+func (s *MegaIndStack) Card(name string) (*MegaIndCard, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	card, ok := s.cards[name]
+	return card, ok
+}
+
+// Cards returns the card names in the order they were added
+func (s *MegaIndStack) Cards() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.order...)
+}
+
+// Define binds a logical name to a card channel
+func (s *MegaIndStack) Define(point IOPoint) error {
+	if point.Name == "" {
+		return fmt.Errorf("I/O name must not be empty")
+	}
+	count, ok := point.Kind.channels()
+	if !ok {
+		return fmt.Errorf("unknown I/O kind %q for %q", point.Kind, point.Name)
+	}
+	if err := validateChannel(string(point.Kind), point.Channel, count); err != nil {
+		return fmt.Errorf("invalid I/O %q: %w", point.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.cards[point.Card]; !ok {
+		return fmt.Errorf("I/O %q refers to unknown card %q", point.Name, point.Card)
+	}
+	if _, exists := s.points[point.Name]; exists {
+		return fmt.Errorf("duplicate I/O name: %q", point.Name)
+	}
+	s.points[point.Name] = point
+	return nil
+}
+
+// Points returns the defined I/O points sorted by name
+func (s *MegaIndStack) Points() []IOPoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points := make([]IOPoint, 0, len(s.points))
+	for _, point := range s.points {
+		points = append(points, point)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Name < points[j].Name
+	})
+	return points
+}
+
+// resolve looks up a logical name and its card
+func (s *MegaIndStack) resolve(name string) (IOPoint, *MegaIndCard, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	point, ok := s.points[name]
+	if !ok {
+		return IOPoint{}, nil, fmt.Errorf("unknown I/O: %q", name)
+	}
+	return point, s.cards[point.Card], nil
+}
+
+// Read returns the value of a named I/O: 0 or 1 for open-drain outputs and
+// opto inputs, volts for analog outputs, the edge count for opto counters
+// and milliamps for 4-20mA inputs
+func (s *MegaIndStack) Read(name string) (float64, error) {
+	point, card, err := s.resolve(name)
+	if err != nil {
+		return 0, err
+	}
+
+	switch point.Kind {
+	case IOOpenDrain:
+		outputs, err := card.OpenDrainOutputs()
+		return bitValue(outputs, point.Channel), err
+	case IOAnalogOutput:
+		return card.AnalogOutput(point.Channel)
+	case IOOptoInput:
+		inputs, err := card.OptoInputs()
+		return bitValue(inputs, point.Channel), err
+	case IOOptoCount:
+		count, err := card.OptoCount(point.Channel)
+		return float64(count), err
+	case IOCurrentInput:
+		return card.CurrentInput(point.Channel)
+	}
+
+	return 0, fmt.Errorf("I/O %q cannot be read", name)
+}
+
+// Write sets a named output: any non-zero value switches an open-drain
+// output on, analog outputs take volts, and writing 0 to an opto counter
+// resets it
+func (s *MegaIndStack) Write(name string, value float64) error {
+	point, card, err := s.resolve(name)
+	if err != nil {
+		return err
+	}
+
+	switch point.Kind {
+	case IOOpenDrain:
+		return card.SetOpenDrainOutput(point.Channel, value != 0)
+	case IOAnalogOutput:
+		return card.SetAnalogOutput(point.Channel, value)
+	case IOOptoCount:
+		if value != 0 {
+			return fmt.Errorf("opto counter %q can only be reset to 0", name)
+		}
+		return card.ResetOptoCount(point.Channel)
+	}
+
+	return fmt.Errorf("I/O %q is read-only", name)
+}
+
+// bitValue returns a 1-based channel bit as 0 or 1
+func bitValue(mask uint8, channel int) float64 {
+	if mask&(1<<uint(channel-1)) != 0 {
+		return 1
+	}
+	return 0
+}
+
+// Close closes every card in the stack
+func (s *MegaIndStack) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, name := range s.order {
+		if err := s.cards[name].Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close card %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// newSimulatedController starts a controller on a fresh simulator
+func newSimulatedController(t *testing.T) (*MegaIndController, *SimulatedMegaInd) {
+	t.Helper()
+
+	simulator := NewSimulatedMegaInd()
+	controller := NewMegaIndController()
+	if err := controller.Attach(simulator); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	t.Cleanup(func() { controller.Dispose() })
+	return controller, simulator
+}
+
+// waitForEvent reads events until one matches, failing after a second
+func waitForEvent(t *testing.T, events <-chan ButtonEvent, button Button, eventType ButtonEventType) ButtonEvent {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("event channel closed waiting for %s %s", button, eventType)
+			}
+			if event.Button == button && event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %s %s event", button, eventType)
+		}
+	}
+}
+
+func TestSimulatedButtonPresses(t *testing.T) {
+	controller, simulator := newSimulatedController(t)
+	events, unsubscribe := controller.Subscribe()
+	defer unsubscribe()
+
+	// A is a digital input and Up an analog one
+	simulator.PressButton(ButtonA)
+	simulator.PressButton(ButtonUp)
+	waitForEvent(t, events, ButtonA, ButtonPressed)
+	waitForEvent(t, events, ButtonUp, ButtonPressed)
+
+	if state := controller.Buttons(); !state.Pressed(ButtonA) || !state.Pressed(ButtonUp) || state.Pressed(ButtonB) {
+		t.Errorf("Buttons() = %v, want A+Up", state)
+	}
+
+	simulator.ReleaseButton(ButtonUp)
+	if event := waitForEvent(t, events, ButtonUp, ButtonReleased); event.Duration <= 0 {
+		t.Errorf("release duration = %v, want positive", event.Duration)
+	}
+}
+
+func TestLightButtonWritesLEDRegisters(t *testing.T) {
+	controller, simulator := newSimulatedController(t)
+
+	// The first frame writes every LED; record only what follows it
+	if err := controller.LEDs().Step(time.Now()); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	simulator.RecordWrites(true)
+
+	if err := controller.LightButton(0, 40); err != nil {
+		t.Fatalf("LightButton(0): %v", err)
+	}
+	if err := controller.LightButton(1, 70); err != nil {
+		t.Fatalf("LightButton(1): %v", err)
+	}
+	if err := controller.LightButton(2, 10); err == nil {
+		t.Error("LightButton(2) succeeded, want an invalid LED error")
+	}
+	if err := controller.LightButton(0, 101); err == nil {
+		t.Error("LightButton(0, 101) succeeded, want a brightness error")
+	}
+
+	if got := simulator.WritesTo(pwmLedOutputRegister0); !slices.Equal(got, []uint8{40}) {
+		t.Errorf("LED 0 writes = %v, want [40]", got)
+	}
+	if got := simulator.WritesTo(pwmLedOutputRegister1); !slices.Equal(got, []uint8{70}) {
+		t.Errorf("LED 1 writes = %v, want [70]", got)
+	}
+}
+
+func TestSetFanSpeedWritesFanRegister(t *testing.T) {
+	controller, simulator := newSimulatedController(t)
+	simulator.RecordWrites(true)
+
+	for _, speed := range []int{60, 0, 100} {
+		if err := controller.SetFanSpeed(speed); err != nil {
+			t.Fatalf("SetFanSpeed(%d): %v", speed, err)
+		}
+	}
+	if err := controller.SetFanSpeed(101); err == nil {
+		t.Error("SetFanSpeed(101) succeeded, want an error")
+	}
+
+	if got := simulator.WritesTo(pwmFanOutputRegister); !slices.Equal(got, []uint8{60, 0, 100}) {
+		t.Errorf("fan writes = %v, want [60 0 100]", got)
+	}
+	if got := simulator.Register(pwmFanOutputRegister); got != 100 {
+		t.Errorf("fan register = %d, want 100", got)
+	}
+}
+
+func TestSimulatorRecordsWritesOnlyWhenAsked(t *testing.T) {
+	simulator := NewSimulatedMegaInd()
+	card := NewMegaIndCard(simulator, "simulated", 0)
+	defer card.Close()
+
+	if err := card.SetOpenDrainOutput(4, true); err != nil {
+		t.Fatalf("SetOpenDrainOutput: %v", err)
+	}
+	if writes := simulator.Writes(); len(writes) != 0 {
+		t.Errorf("recorded %d writes before RecordWrites, want none", len(writes))
+	}
+
+	simulator.RecordWrites(true)
+	if err := card.SetOpenDrainOutput(4, false); err != nil {
+		t.Fatalf("SetOpenDrainOutput: %v", err)
+	}
+	if got := simulator.WritesTo(openDrainClearRegister); !slices.Equal(got, []uint8{4}) {
+		t.Errorf("clear register writes = %v, want [4]", got)
+	}
+	outputs, err := card.OpenDrainOutputs()
+	if err != nil || outputs != 0 {
+		t.Errorf("OpenDrainOutputs() = 0x%02X, %v, want 0x00", outputs, err)
+	}
+
+	simulator.RecordWrites(false)
+	if writes := simulator.Writes(); len(writes) != 0 {
+		t.Errorf("recorded %d writes after stopping, want none", len(writes))
+	}
+}
+
+func TestDisposeClosesOnlyOwnedCards(t *testing.T) {
+	// A card attached from a stack stays open for the stack to close
+	simulator := NewSimulatedMegaInd()
+	card := NewMegaIndCard(simulator, "simulated", 0)
+	controller := NewMegaIndController()
+	if err := controller.AttachCard(card); err != nil {
+		t.Fatalf("AttachCard: %v", err)
+	}
+	controller.Dispose()
+	if _, err := card.OptoInputs(); err != nil {
+		t.Errorf("borrowed card closed by Dispose: %v", err)
+	}
+	card.Close()
+
+	// A bus handed to Attach belongs to the controller
+	simulator = NewSimulatedMegaInd()
+	controller = NewMegaIndController()
+	if err := controller.Attach(simulator); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	controller.Dispose()
+	if err := simulator.Tx([]byte{digitalInputRegister}, make([]byte, 1)); err == nil {
+		t.Error("attached bus still open after Dispose")
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrMegaIndOffline is returned without touching the bus while a card is
+// offline and its next reconnect attempt is not yet due
+var ErrMegaIndOffline = errors.New("MegaInd card is offline")
+
+// MegaIndHealth summarises how well a card is responding
+type MegaIndHealth int
+
+const (
+	// HealthOK means the last transaction succeeded
+	HealthOK MegaIndHealth = iota
+	// HealthDegraded means recent transactions failed even after retries
+	HealthDegraded
+	// HealthOffline means the card stopped responding and is being reconnected
+	HealthOffline
+)
+
+func (h MegaIndHealth) String() string {
+	switch h {
+	case HealthOK:
+		return "OK"
+	case HealthDegraded:
+		return "Degraded"
+	case HealthOffline:
+		return "Offline"
+	default:
+		return "Unknown"
+	}
+}
+
+const (
+	// Attempts per transaction and the delay before the first retry, which
+	// doubles on every further retry
+	transportAttempts = 3
+	transportBackoff  = 2 * time.Millisecond
+
+	// Consecutive failed transactions before a card is considered gone
+	offlineAfterFailures = 3
+
+	// Delay between reconnect attempts, doubling up to the maximum
+	reconnectMinDelay = 500 * time.Millisecond
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// TransportStats counts the traffic and failures of a card's transport
+type TransportStats struct {
+	Health       MegaIndHealth
+	Transactions uint64
+	Errors       uint64
+	Retries      uint64
+	// Reconnects counts recoveries from the offline state
+	Reconnects  uint64
+	LastLatency time.Duration
+	// AvgLatency is the mean latency of successful transactions
+	AvgLatency time.Duration
+	LastError  string
+}
+
+// reopener is implemented by buses that can be reopened after the device
+// has disappeared. A failed reopen leaves the bus as it was; after a
+// successful one, the old bus is closed unless it was returned again.
+type reopener interface {
+	Reopen() (RegisterBus, error)
+}
+
+// resilientBus wraps a RegisterBus with retries, exponential backoff,
+// offline detection and reconnection
+type resilientBus struct {
+	mu     sync.Mutex
+	bus    RegisterBus
+	name   string
+	closed bool
+
+	stats               TransportStats
+	latencyTotal        time.Duration
+	consecutiveFailures int
+	reconnectDelay      time.Duration
+	nextReconnect       time.Time
+}
+
+// newResilientBus wraps bus; name identifies the card in log messages
+func newResilientBus(bus RegisterBus, name string) *resilientBus {
+	return &resilientBus{
+		bus:            bus,
+		name:           name,
+		reconnectDelay: reconnectMinDelay,
+	}
+}
+
+// Tx performs a transaction, retrying failures with backoff. While the
+// card is offline, transactions fail fast until a reconnect is due.
+func (t *resilientBus) Tx(w, r []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return fmt.Errorf("bus is closed")
+	}
+
+	attempts := transportAttempts
+	if t.stats.Health == HealthOffline {
+		if time.Now().Before(t.nextReconnect) {
+			return ErrMegaIndOffline
+		}
+		if err := t.reconnect(); err != nil {
+			t.scheduleReconnect()
+			return fmt.Errorf("%w: %v", ErrMegaIndOffline, err)
+		}
+		// A single attempt decides whether the card is back
+		attempts = 1
+	}
+
+	var err error
+	backoff := transportBackoff
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			t.stats.Retries++
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		t.stats.Transactions++
+		start := time.Now()
+		if err = t.bus.Tx(w, r); err == nil {
+			t.recordSuccess(time.Since(start))
+			return nil
+		}
+		t.stats.Errors++
+	}
+
+	t.recordFailure(err)
+	return err
+}
+
+// recordSuccess updates the counters after a successful transaction
+func (t *resilientBus) recordSuccess(latency time.Duration) {
+	t.stats.LastLatency = latency
+	t.latencyTotal += latency
+	t.stats.AvgLatency = t.latencyTotal / time.Duration(t.stats.Transactions-t.stats.Errors)
+
+	t.consecutiveFailures = 0
+	t.reconnectDelay = reconnectMinDelay
+	if t.stats.Health == HealthOffline {
+		t.stats.Reconnects++
+	}
+	if t.stats.Health != HealthOK {
+		log.Printf("MegaInd %s recovered", t.name)
+		t.stats.Health = HealthOK
+	}
+}
+
+// recordFailure updates the health after a transaction failed every attempt
+func (t *resilientBus) recordFailure(err error) {
+	t.stats.LastError = err.Error()
+	t.consecutiveFailures++
+
+	switch {
+	case t.stats.Health == HealthOffline:
+		t.scheduleReconnect()
+	case t.consecutiveFailures >= offlineAfterFailures:
+		log.Printf("MegaInd %s is offline: %v", t.name, err)
+		t.stats.Health = HealthOffline
+		t.scheduleReconnect()
+	case t.stats.Health == HealthOK:
+		log.Printf("MegaInd %s is degraded: %v", t.name, err)
+		t.stats.Health = HealthDegraded
+	}
+}
+
+// scheduleReconnect sets the next reconnect time and backs off the delay
+func (t *resilientBus) scheduleReconnect() {
+	t.nextReconnect = time.Now().Add(t.reconnectDelay)
+	t.reconnectDelay = min(2*t.reconnectDelay, reconnectMaxDelay)
+}
+
+// reconnect reopens the underlying bus if it supports reopening
+func (t *resilientBus) reconnect() error {
+	r, ok := t.bus.(reopener)
+	if !ok {
+		return nil
+	}
+
+	bus, err := r.Reopen()
+	if err != nil {
+		return err
+	}
+
+	if bus != t.bus {
+		if err := t.bus.Close(); err != nil {
+			log.Printf("MegaInd %s: failed to close the old bus: %v", t.name, err)
+		}
+	}
+	t.bus = bus
+	return nil
+}
+
+// Stats returns a snapshot of the transport counters
+func (t *resilientBus) Stats() TransportStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// Close closes the underlying bus
+func (t *resilientBus) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	return t.bus.Close()
+}
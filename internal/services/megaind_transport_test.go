@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// readInputs performs one digital input read through the transport
+func readInputs(bus *resilientBus) error {
+	return bus.Tx([]byte{digitalInputRegister}, make([]byte, 1))
+}
+
+// waitForHealth polls the controller until it reports the wanted health
+func waitForHealth(t *testing.T, controller *MegaIndController, want MegaIndHealth, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if controller.Health() == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("health is %s after %v, want %s", controller.Health(), timeout, want)
+}
+
+func TestTransportRetriesTransientFailures(t *testing.T) {
+	simulator := NewSimulatedMegaInd()
+	bus := newResilientBus(simulator, "test")
+	defer bus.Close()
+
+	simulator.FailNext(transportAttempts - 1)
+	if err := readInputs(bus); err != nil {
+		t.Fatalf("Tx failed despite retries: %v", err)
+	}
+
+	stats := bus.Stats()
+	if stats.Health != HealthOK {
+		t.Errorf("health = %s, want OK", stats.Health)
+	}
+	if stats.Retries != transportAttempts-1 || stats.Errors != transportAttempts-1 || stats.Transactions != transportAttempts {
+		t.Errorf("stats = %d transactions, %d errors, %d retries; want %d, %d, %d",
+			stats.Transactions, stats.Errors, stats.Retries, transportAttempts, transportAttempts-1, transportAttempts-1)
+	}
+}
+
+func TestTransportHealthTransitions(t *testing.T) {
+	simulator := NewSimulatedMegaInd()
+	bus := newResilientBus(simulator, "test")
+	defer bus.Close()
+
+	expect := func(want MegaIndHealth) {
+		t.Helper()
+		if got := bus.Stats().Health; got != want {
+			t.Fatalf("health = %s, want %s", got, want)
+		}
+	}
+
+	// A transaction that fails every attempt degrades the card, and the
+	// next success restores it
+	simulator.FailNext(transportAttempts)
+	if err := readInputs(bus); err == nil {
+		t.Fatal("Tx succeeded, want the injected failure")
+	}
+	expect(HealthDegraded)
+	if err := readInputs(bus); err != nil {
+		t.Fatalf("Tx: %v", err)
+	}
+	expect(HealthOK)
+
+	// Unplugging takes it offline after consecutive failures
+	simulator.Disconnect()
+	for i := 1; i <= offlineAfterFailures; i++ {
+		if err := readInputs(bus); err == nil {
+			t.Fatal("Tx succeeded on a disconnected card")
+		}
+		if i < offlineAfterFailures {
+			expect(HealthDegraded)
+		}
+	}
+	expect(HealthOffline)
+
+	// While offline, transactions fail fast until a reconnect is due
+	transactions := bus.Stats().Transactions
+	if err := readInputs(bus); !errors.Is(err, ErrMegaIndOffline) {
+		t.Fatalf("Tx while offline = %v, want ErrMegaIndOffline", err)
+	}
+	if got := bus.Stats().Transactions; got != transactions {
+		t.Errorf("offline Tx reached the bus: %d transactions, want %d", got, transactions)
+	}
+
+	// A due reconnect that still fails backs off further
+	bus.nextReconnect = time.Now()
+	if err := readInputs(bus); !errors.Is(err, ErrMegaIndOffline) {
+		t.Fatalf("Tx on a failed reconnect = %v, want ErrMegaIndOffline", err)
+	}
+	if bus.reconnectDelay != 4*reconnectMinDelay {
+		t.Errorf("reconnect delay = %v, want %v", bus.reconnectDelay, 4*reconnectMinDelay)
+	}
+	expect(HealthOffline)
+
+	// Plugging the card back in recovers on the next due reconnect
+	simulator.Reconnect()
+	bus.nextReconnect = time.Now()
+	if err := readInputs(bus); err != nil {
+		t.Fatalf("Tx after reconnecting: %v", err)
+	}
+	expect(HealthOK)
+	if stats := bus.Stats(); stats.Reconnects != 1 {
+		t.Errorf("reconnects = %d, want 1", stats.Reconnects)
+	}
+	if bus.reconnectDelay != reconnectMinDelay {
+		t.Errorf("reconnect delay = %v after recovering, want %v", bus.reconnectDelay, reconnectMinDelay)
+	}
+}
+
+func TestTransportCountsUnderRandomErrors(t *testing.T) {
+	simulator := NewSimulatedMegaInd()
+	simulator.SetErrorRate(0.2)
+	bus := newResilientBus(simulator, "test")
+	defer bus.Close()
+
+	const calls = 200
+	var failures uint64
+	for range calls {
+		if err := readInputs(bus); err != nil {
+			failures++
+		}
+	}
+
+	stats := bus.Stats()
+	if stats.Retries == 0 {
+		t.Error("no retries at a 20% error rate")
+	}
+	if stats.Transactions != calls+stats.Retries {
+		t.Errorf("transactions = %d, want %d calls + %d retries", stats.Transactions, calls, stats.Retries)
+	}
+	successes := calls - failures
+	if stats.Errors != stats.Transactions-successes {
+		t.Errorf("errors = %d, want %d", stats.Errors, stats.Transactions-successes)
+	}
+}
+
+func TestControllerReportsOfflineAndReconnects(t *testing.T) {
+	controller, simulator := newSimulatedController(t)
+	waitForHealth(t, controller, HealthOK, time.Second)
+
+	simulator.Disconnect()
+	waitForHealth(t, controller, HealthOffline, time.Second)
+
+	simulator.Reconnect()
+	waitForHealth(t, controller, HealthOK, 2*reconnectMinDelay+time.Second)
+	if stats := controller.TransportStats(); stats.Reconnects != 1 {
+		t.Errorf("reconnects = %d, want 1", stats.Reconnects)
+	}
+
+	// Buttons work again after the reconnect
+	events, unsubscribe := controller.Subscribe()
+	defer unsubscribe()
+	simulator.PressButton(ButtonB)
+	waitForEvent(t, events, ButtonB, ButtonPressed)
+}
+
+// reopenCard is a card behind reopenable handles, like a card on a bus
+// adapter that can be reset
+type reopenCard struct {
+	online      bool
+	failReopens int
+	opened      []*reopenHandle
+}
+
+// reopenHandle is one open handle on a reopenCard
+type reopenHandle struct {
+	card   *reopenCard
+	closes int
+}
+
+func (h *reopenHandle) Tx(w, r []byte) error {
+	if !h.card.online {
+		return errors.New("no acknowledgement")
+	}
+	return nil
+}
+
+func (h *reopenHandle) Close() error {
+	h.closes++
+	return nil
+}
+
+func (h *reopenHandle) Reopen() (RegisterBus, error) {
+	if h.card.failReopens > 0 {
+		h.card.failReopens--
+		return nil, errors.New("no such device")
+	}
+	handle := &reopenHandle{card: h.card}
+	h.card.opened = append(h.card.opened, handle)
+	return handle, nil
+}
+
+func TestTransportKeepsBusUntilReopenSucceeds(t *testing.T) {
+	card := &reopenCard{online: true}
+	first := &reopenHandle{card: card}
+	bus := newResilientBus(first, "test")
+
+	card.online = false
+	for range offlineAfterFailures {
+		readInputs(bus)
+	}
+	if health := bus.Stats().Health; health != HealthOffline {
+		t.Fatalf("health = %s, want offline", health)
+	}
+
+	// A failed reopen keeps the old handle, and does not close it
+	card.online = true
+	card.failReopens = 1
+	bus.nextReconnect = time.Now()
+	if err := readInputs(bus); !errors.Is(err, ErrMegaIndOffline) {
+		t.Fatalf("Tx on a failed reopen = %v, want ErrMegaIndOffline", err)
+	}
+	if bus.bus != first || first.closes != 0 {
+		t.Fatalf("after a failed reopen: old handle in use %v, closed %d times; want in use, open",
+			bus.bus == first, first.closes)
+	}
+
+	// The next reopen replaces the handle and closes the old one once
+	bus.nextReconnect = time.Now()
+	if err := readInputs(bus); err != nil {
+		t.Fatalf("Tx after reopening: %v", err)
+	}
+	if len(card.opened) != 1 || bus.bus != card.opened[0] {
+		t.Fatalf("opened %d handles, want the transport on the one new handle", len(card.opened))
+	}
+	if first.closes != 1 {
+		t.Errorf("old handle closed %d times, want 1", first.closes)
+	}
+
+	bus.Close()
+	if first.closes != 1 || card.opened[0].closes != 1 {
+		t.Errorf("after Close: old handle closed %d times, new %d; want 1 each", first.closes, card.opened[0].closes)
+	}
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlaylistEntry is a track in a playlist file. Title and Duration are only
+// hints carried by some formats.
+type PlaylistEntry struct {
+	Path     string
+	Title    string
+	Duration time.Duration
+}
+
+// PlaylistFile is a playlist read from or written to M3U, PLS or XSPF
+type PlaylistFile struct {
+	Name    string
+	Entries []PlaylistEntry
+}
+
+// Paths returns the track paths in order
+func (p *PlaylistFile) Paths() []string {
+	paths := make([]string, len(p.Entries))
+	for i, entry := range p.Entries {
+		paths[i] = entry.Path
+	}
+	return paths
+}
+
+// PlaylistFormats lists the extensions of the supported playlist formats
+var PlaylistFormats = []string{".m3u", ".m3u8", ".pls", ".xspf"}
+
+// ReadPlaylistFile imports a playlist, choosing the format by extension.
+// Relative track paths are resolved against the playlist's directory.
+func ReadPlaylistFile(path string) (*PlaylistFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open playlist: %w", err)
+	}
+	defer file.Close()
+
+	playlist := &PlaylistFile{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".m3u", ".m3u8":
+		err = playlist.readM3U(file)
+	case ".pls":
+		err = playlist.readPLS(file)
+	case ".xspf":
+		err = playlist.readXSPF(file)
+	default:
+		return nil, fmt.Errorf("unsupported playlist format: %s", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playlist %s: %w", path, err)
+	}
+
+	if playlist.Name == "" {
+		playlist.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	// Keep local files only, resolved against the playlist's location
+	dir := filepath.Dir(path)
+	entries := playlist.Entries[:0]
+	for _, entry := range playlist.Entries {
+		if resolved, ok := resolvePlaylistPath(entry.Path, dir); ok {
+			entry.Path = resolved
+			entries = append(entries, entry)
+		}
+	}
+	playlist.Entries = entries
+
+	return playlist, nil
+}
+
+// resolvePlaylistPath turns a playlist location into an absolute file path;
+// remote URLs are not playable and are rejected
+func resolvePlaylistPath(location, dir string) (string, bool) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return "", false
+	}
+
+	if strings.Contains(location, "://") {
+		u, err := url.Parse(location)
+		if err != nil || u.Scheme != "file" {
+			return "", false
+		}
+		location = u.Path
+	}
+
+	// Playlists written on Windows use backslashes
+	if filepath.Separator == '/' && !strings.Contains(location, "/") {
+		location = strings.ReplaceAll(location, `\`, "/")
+	}
+	location = filepath.FromSlash(location)
+	if !filepath.IsAbs(location) {
+		location = filepath.Join(dir, location)
+	}
+	return filepath.Clean(location), true
+}
+
+// WritePlaylistFile exports a playlist, choosing the format by extension.
+// Tracks under the playlist's directory are written as relative paths.
+func WritePlaylistFile(path string, playlist *PlaylistFile) error {
+	dir := filepath.Dir(path)
+	relative := &PlaylistFile{Name: playlist.Name, Entries: make([]PlaylistEntry, len(playlist.Entries))}
+	for i, entry := range playlist.Entries {
+		if rel, err := filepath.Rel(dir, entry.Path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			entry.Path = rel
+		}
+		relative.Entries[i] = entry
+	}
+
+	var b strings.Builder
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".m3u", ".m3u8":
+		relative.writeM3U(&b)
+	case ".pls":
+		relative.writePLS(&b)
+	case ".xspf":
+		if err := relative.writeXSPF(&b); err != nil {
+			return fmt.Errorf("failed to encode playlist: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported playlist format: %s", ext)
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write playlist %s: %w", path, err)
+	}
+	return nil
+}
+
+// readM3U parses an M3U or extended M3U playlist. #EXTINF lines give the
+// length and title of the entry that follows.
+func (p *PlaylistFile) readM3U(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	var pending PlaylistEntry
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			length, title, _ := strings.Cut(info, ",")
+			// Attributes such as tvg-id may follow the length
+			length, _, _ = strings.Cut(length, " ")
+			if seconds, err := strconv.ParseFloat(length, 64); err == nil && seconds > 0 {
+				pending.Duration = time.Duration(seconds * float64(time.Second))
+			}
+			pending.Title = strings.TrimSpace(title)
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			p.Name = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#"):
+		default:
+			pending.Path = line
+			p.Entries = append(p.Entries, pending)
+			pending = PlaylistEntry{}
+		}
+	}
+	return scanner.Err()
+}
+
+// writeM3U writes an extended M3U playlist
+func (p *PlaylistFile) writeM3U(w io.Writer) {
+	fmt.Fprintln(w, "#EXTM3U")
+	if p.Name != "" {
+		fmt.Fprintf(w, "#PLAYLIST:%s\n", p.Name)
+	}
+	for _, entry := range p.Entries {
+		seconds := -1
+		if entry.Duration > 0 {
+			seconds = int(entry.Duration.Round(time.Second) / time.Second)
+		}
+		title := entry.Title
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(entry.Path), filepath.Ext(entry.Path))
+		}
+		fmt.Fprintf(w, "#EXTINF:%d,%s\n%s\n", seconds, title, entry.Path)
+	}
+}
+
+// readPLS parses a PLS playlist of numbered FileN, TitleN and LengthN keys
+func (p *PlaylistFile) readPLS(r io.Reader) error {
+	entries := make(map[int]*PlaylistEntry)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+
+		name := strings.TrimRight(key, "0123456789")
+		number, err := strconv.Atoi(key[len(name):])
+		if err != nil {
+			continue
+		}
+		entry := entries[number]
+		if entry == nil {
+			entry = &PlaylistEntry{}
+			entries[number] = entry
+		}
+
+		switch strings.ToLower(name) {
+		case "file":
+			entry.Path = value
+		case "title":
+			entry.Title = value
+		case "length":
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				entry.Duration = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	numbers := make([]int, 0, len(entries))
+	for number := range entries {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	for _, number := range numbers {
+		if entries[number].Path != "" {
+			p.Entries = append(p.Entries, *entries[number])
+		}
+	}
+	return nil
+}
+
+// writePLS writes a version 2 PLS playlist
+func (p *PlaylistFile) writePLS(w io.Writer) {
+	fmt.Fprintln(w, "[playlist]")
+	for i, entry := range p.Entries {
+		n := i + 1
+		fmt.Fprintf(w, "File%d=%s\n", n, entry.Path)
+		if entry.Title != "" {
+			fmt.Fprintf(w, "Title%d=%s\n", n, entry.Title)
+		}
+		seconds := -1
+		if entry.Duration > 0 {
+			seconds = int(entry.Duration.Round(time.Second) / time.Second)
+		}
+		fmt.Fprintf(w, "Length%d=%d\n", n, seconds)
+	}
+	fmt.Fprintf(w, "NumberOfEntries=%d\n", len(p.Entries))
+	fmt.Fprintln(w, "Version=2")
+}
+
+// xspfPlaylist is the XML form of an XSPF playlist
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+	Version string      `xml:"version,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+// xspfTrack is a track element; Duration is in milliseconds
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+	Duration int64  `xml:"duration,omitempty"`
+}
+
+// readXSPF parses an XSPF playlist
+func (p *PlaylistFile) readXSPF(r io.Reader) error {
+	var playlist xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&playlist); err != nil {
+		return err
+	}
+
+	p.Name = playlist.Title
+	for _, track := range playlist.Tracks {
+		location := track.Location
+		// Locations are URIs; relative ones are percent-encoded paths
+		if !strings.Contains(location, "://") {
+			if unescaped, err := url.PathUnescape(location); err == nil {
+				location = unescaped
+			}
+		}
+		p.Entries = append(p.Entries, PlaylistEntry{
+			Path:     location,
+			Title:    track.Title,
+			Duration: time.Duration(track.Duration) * time.Millisecond,
+		})
+	}
+	return nil
+}
+
+// writeXSPF writes an XSPF playlist with file URIs or relative locations
+func (p *PlaylistFile) writeXSPF(w io.Writer) error {
+	playlist := xspfPlaylist{Version: "1", Title: p.Name}
+	for _, entry := range p.Entries {
+		location := (&url.URL{Path: filepath.ToSlash(entry.Path)}).String()
+		if filepath.IsAbs(entry.Path) {
+			location = (&url.URL{Scheme: "file", Path: filepath.ToSlash(entry.Path)}).String()
+		}
+		playlist.Tracks = append(playlist.Tracks, xspfTrack{
+			Location: location,
+			Title:    entry.Title,
+			Duration: entry.Duration.Milliseconds(),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(playlist); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
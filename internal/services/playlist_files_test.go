@@ -0,0 +1,54 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWritePlaylistFileRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	path := filepath.Join(dir, "party.m3u8")
+	playlist := &PlaylistFile{Entries: []PlaylistEntry{
+		{Path: filepath.Join(dir, "sub", "a.mp3")},
+		// Names starting with dots are still inside the directory
+		{Path: filepath.Join(dir, "..intro.mp3")},
+		{Path: filepath.Join(dir, "...Live", "b.mp3")},
+		{Path: filepath.Join(outside, "c.mp3")},
+	}}
+	if err := WritePlaylistFile(path, playlist); err != nil {
+		t.Fatalf("WritePlaylistFile: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read playlist: %v", err)
+	}
+	var locations []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			locations = append(locations, line)
+		}
+	}
+	want := []string{
+		filepath.Join("sub", "a.mp3"),
+		"..intro.mp3",
+		filepath.Join("...Live", "b.mp3"),
+		filepath.Join(outside, "c.mp3"),
+	}
+	if !slices.Equal(locations, want) {
+		t.Errorf("written locations = %q, want %q", locations, want)
+	}
+
+	// And they read back to the same tracks
+	read, err := ReadPlaylistFile(path)
+	if err != nil {
+		t.Fatalf("ReadPlaylistFile: %v", err)
+	}
+	if got, want := read.Paths(), playlist.Paths(); !slices.Equal(got, want) {
+		t.Errorf("read back %q, want %q", got, want)
+	}
+}
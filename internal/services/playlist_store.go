@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// playlistStoreExt is the format saved playlists are kept in
+const playlistStoreExt = ".m3u8"
+
+// PlaylistStore keeps named playlists as M3U8 files in a directory, so they
+// can also be copied to and from other players
+type PlaylistStore struct {
+	dir string
+}
+
+// NewPlaylistStore creates a store in dir; the directory is created when the
+// first playlist is saved
+func NewPlaylistStore(dir string) *PlaylistStore {
+	return &PlaylistStore{dir: dir}
+}
+
+// Directory returns where the playlists are kept
+func (s *PlaylistStore) Directory() string {
+	return s.dir
+}
+
+// Names lists the saved playlists in alphabetical order
+func (s *PlaylistStore) Names() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list playlists: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), playlistStoreExt) {
+			names = append(names, strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+	return names, nil
+}
+
+// Load reads a saved playlist
+func (s *PlaylistStore) Load(name string) (*PlaylistFile, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	playlist, err := ReadPlaylistFile(path)
+	if err != nil {
+		return nil, err
+	}
+	playlist.Name = name
+	return playlist, nil
+}
+
+// Save stores a playlist under its name, replacing any playlist of that name
+func (s *PlaylistStore) Save(playlist *PlaylistFile) error {
+	path, err := s.path(playlist.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create playlist directory: %w", err)
+	}
+	return WritePlaylistFile(path, playlist)
+}
+
+// Delete removes a saved playlist
+func (s *PlaylistStore) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete playlist %q: %w", name, err)
+	}
+	return nil
+}
+
+// path returns the file a playlist is saved in; names become file names, so
+// they may not contain path separators
+func (s *PlaylistStore) path(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid playlist name: %q", name)
+	}
+	return filepath.Join(s.dir, name+playlistStoreExt), nil
+}
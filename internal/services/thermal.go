@@ -0,0 +1,445 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TemperatureSource reads a temperature in degrees Celsius
+type TemperatureSource interface {
+	Name() string
+	Temperature() (float64, error)
+}
+
+// FanOutput drives a fan at a speed between 0 and 100
+type FanOutput interface {
+	SetFanSpeed(speed int) error
+}
+
+// cardTemperatureSource reads the onboard sensor of a MegaInd card
+type cardTemperatureSource struct {
+	name string
+	card interface{ Temperature() (float64, error) }
+}
+
+// MegaIndTemperatureSource reads the onboard sensor of a MegaInd card or
+// controller
+func MegaIndTemperatureSource(name string, card interface{ Temperature() (float64, error) }) TemperatureSource {
+	return &cardTemperatureSource{name: name, card: card}
+}
+
+func (s *cardTemperatureSource) Name() string {
+	return s.name
+}
+
+func (s *cardTemperatureSource) Temperature() (float64, error) {
+	return s.card.Temperature()
+}
+
+// FileTemperatureSource reads a sysfs thermal zone such as
+// /sys/class/thermal/thermal_zone0/temp, or a 1-Wire sensor's w1_slave file
+type FileTemperatureSource struct {
+	Path string
+}
+
+func (s *FileTemperatureSource) Name() string {
+	return s.Path
+}
+
+// Temperature parses the file. Thermal zones hold millidegrees; 1-Wire
+// files end in "t=<millidegrees>" after a CRC line ending in YES.
+func (s *FileTemperatureSource) Temperature() (float64, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read temperature: %w", err)
+	}
+
+	text := strings.TrimSpace(string(data))
+	if i := strings.LastIndex(text, "t="); i >= 0 {
+		if !strings.Contains(text, "YES") {
+			return 0, fmt.Errorf("1-Wire sensor %s failed its CRC check", s.Path)
+		}
+		text = text[i+2:]
+	}
+
+	millidegrees, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse temperature in %s: %w", s.Path, err)
+	}
+	return float64(millidegrees) / 1000, nil
+}
+
+// SimulatedTemperatureSource is a temperature source set by hand
+type SimulatedTemperatureSource struct {
+	mu          sync.Mutex
+	temperature float64
+	err         error
+}
+
+// NewSimulatedTemperatureSource creates a source reading the given temperature
+func NewSimulatedTemperatureSource(celsius float64) *SimulatedTemperatureSource {
+	return &SimulatedTemperatureSource{temperature: celsius}
+}
+
+func (s *SimulatedTemperatureSource) Name() string {
+	return "simulated"
+}
+
+func (s *SimulatedTemperatureSource) Temperature() (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.temperature, s.err
+}
+
+// Set changes the reported temperature
+func (s *SimulatedTemperatureSource) Set(celsius float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.temperature = celsius
+}
+
+// Fail makes reads return err; nil restores normal reads
+func (s *SimulatedTemperatureSource) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// ThermalMode selects how temperature is mapped to fan speed
+type ThermalMode string
+
+const (
+	ThermalCurve ThermalMode = "curve"
+	ThermalPID   ThermalMode = "pid"
+)
+
+// FanCurvePoint is a point on a fan curve
+type FanCurvePoint struct {
+	Temperature float64
+	Speed       int
+}
+
+// ThermalSettings configures a ThermalController
+type ThermalSettings struct {
+	Mode ThermalMode
+	// Curve is interpolated linearly and held flat beyond its ends
+	Curve []FanCurvePoint
+
+	// PID loop towards Target, in degrees Celsius
+	Target float64
+	Kp     float64
+	Ki     float64
+	Kd     float64
+
+	// MinSpeed is the lowest speed at which the fan turns reliably; lower
+	// non-zero speeds are raised to it
+	MinSpeed int
+	// KickSpeed is applied for KickDuration when the fan starts from rest
+	KickSpeed    int
+	KickDuration time.Duration
+	// Hysteresis is how far the temperature must fall before the fan slows
+	Hysteresis float64
+	// Interval is the time between control steps
+	Interval time.Duration
+}
+
+// DefaultThermalSettings returns a quiet curve suited to an enclosure fan
+func DefaultThermalSettings() ThermalSettings {
+	return ThermalSettings{
+		Mode: ThermalCurve,
+		Curve: []FanCurvePoint{
+			{Temperature: 35, Speed: 0},
+			{Temperature: 45, Speed: 40},
+			{Temperature: 60, Speed: 100},
+		},
+		Target:       45,
+		Kp:           8,
+		Ki:           0.2,
+		MinSpeed:     25,
+		KickSpeed:    100,
+		KickDuration: time.Second,
+		Hysteresis:   2,
+		Interval:     2 * time.Second,
+	}
+}
+
+// validate checks the settings and sorts the curve
+func (s *ThermalSettings) validate() error {
+	switch s.Mode {
+	case ThermalCurve:
+		if len(s.Curve) == 0 {
+			return fmt.Errorf("fan curve must have at least one point")
+		}
+		for _, p := range s.Curve {
+			if p.Speed < 0 || p.Speed > 100 {
+				return fmt.Errorf("fan curve speed must be between 0 and 100, got: %d", p.Speed)
+			}
+		}
+		sort.Slice(s.Curve, func(i, j int) bool {
+			return s.Curve[i].Temperature < s.Curve[j].Temperature
+		})
+	case ThermalPID:
+		if s.Kp < 0 || s.Ki < 0 || s.Kd < 0 {
+			return fmt.Errorf("PID gains must not be negative")
+		}
+	default:
+		return fmt.Errorf("unknown thermal mode: %q", s.Mode)
+	}
+
+	if s.MinSpeed < 0 || s.MinSpeed > 100 {
+		return fmt.Errorf("minimum fan speed must be between 0 and 100, got: %d", s.MinSpeed)
+	}
+	if s.KickSpeed < 0 || s.KickSpeed > 100 {
+		return fmt.Errorf("kick speed must be between 0 and 100, got: %d", s.KickSpeed)
+	}
+	if s.Hysteresis < 0 {
+		return fmt.Errorf("hysteresis must not be negative, got: %.1f", s.Hysteresis)
+	}
+	if s.Interval <= 0 {
+		return fmt.Errorf("thermal control interval must be positive, got: %v", s.Interval)
+	}
+	return nil
+}
+
+// ThermalStatus is a snapshot of the thermal controller
+type ThermalStatus struct {
+	Source      string
+	Mode        ThermalMode
+	Temperature float64
+	Speed       int
+	Kicking     bool
+	// Err is the last source or fan error; the fan runs at full speed
+	// while the temperature cannot be read
+	Err     error
+	Updated time.Time
+}
+
+// failsafeSpeed is used while the temperature cannot be read
+const failsafeSpeed = 100
+
+// ThermalController drives a fan from a temperature source using a curve
+// or a PID loop
+type ThermalController struct {
+	mu       sync.Mutex
+	source   TemperatureSource
+	fan      FanOutput
+	settings ThermalSettings
+	status   ThermalStatus
+
+	// Control state
+	effective    float64
+	hasEffective bool
+	integral     float64
+	lastError    float64
+	lastStep     time.Time
+	kickUntil    time.Time
+	written      int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewThermalController creates a controller; call Start to run it
+func NewThermalController(source TemperatureSource, fan FanOutput, settings ThermalSettings) (*ThermalController, error) {
+	if err := settings.validate(); err != nil {
+		return nil, err
+	}
+
+	return &ThermalController{
+		source:   source,
+		fan:      fan,
+		settings: settings,
+		status:   ThermalStatus{Source: source.Name(), Mode: settings.Mode},
+		written:  -1,
+	}, nil
+}
+
+// Start runs the control loop until Stop is called
+func (c *ThermalController) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	go c.loop(ctx, c.done)
+}
+
+// Stop ends the control loop, leaving the fan at its last speed
+func (c *ThermalController) Stop() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.cancel = nil
+	c.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// loop steps the controller on its interval, and again when a kick ends so
+// the kick lasts its own duration rather than a whole interval
+func (c *ThermalController) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(c.settings.Interval)
+	defer ticker.Stop()
+
+	var kickEnd <-chan time.Time
+	step := func(now time.Time) {
+		c.Step(now)
+		kickEnd = nil
+		if until := c.kickEnd(); until.After(now) {
+			kickEnd = time.After(until.Sub(now))
+		}
+	}
+
+	step(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			step(now)
+		case now := <-kickEnd:
+			step(now)
+		}
+	}
+}
+
+// kickEnd returns when the latest kick ends
+func (c *ThermalController) kickEnd() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.kickUntil
+}
+
+// Status returns the latest controller state
+func (c *ThermalController) Status() ThermalStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// Step reads the temperature at now and updates the fan
+func (c *ThermalController) Step(now time.Time) ThermalStatus {
+	temperature, readErr := c.source.Temperature()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previousErr := c.status.Err
+	c.status.Updated = now
+	c.status.Err = readErr
+
+	speed := failsafeSpeed
+	if readErr == nil {
+		c.status.Temperature = temperature
+		speed = c.shape(c.target(temperature, now), now)
+	} else if previousErr == nil {
+		log.Printf("Thermal source %s failed, running fan at full speed: %v", c.source.Name(), readErr)
+	}
+	c.lastStep = now
+
+	if speed != c.written {
+		if err := c.fan.SetFanSpeed(speed); err != nil {
+			c.status.Err = err
+		} else {
+			c.written = speed
+		}
+	}
+	c.status.Speed = speed
+	c.status.Kicking = now.Before(c.kickUntil)
+
+	return c.status
+}
+
+// target maps a temperature to an unshaped fan speed; callers must hold c.mu
+func (c *ThermalController) target(temperature float64, now time.Time) float64 {
+	// Follow rising temperatures at once, falling ones only past the
+	// hysteresis band
+	switch {
+	case !c.hasEffective || temperature > c.effective:
+		c.effective = temperature
+	case temperature < c.effective-c.settings.Hysteresis:
+		c.effective = temperature + c.settings.Hysteresis
+	}
+	c.hasEffective = true
+
+	if c.settings.Mode == ThermalPID {
+		return c.pid(c.effective, now)
+	}
+	return curveSpeed(c.settings.Curve, c.effective)
+}
+
+// pid runs one PID update; callers must hold c.mu
+func (c *ThermalController) pid(temperature float64, now time.Time) float64 {
+	err := temperature - c.settings.Target
+
+	dt := c.settings.Interval.Seconds()
+	if !c.lastStep.IsZero() {
+		dt = now.Sub(c.lastStep).Seconds()
+	}
+
+	derivative := 0.0
+	if dt > 0 && !c.lastStep.IsZero() {
+		derivative = (err - c.lastError) / dt
+	}
+	c.lastError = err
+
+	// Limit the integral term to the output range to avoid windup
+	if c.settings.Ki > 0 {
+		c.integral += err * dt
+		c.integral = min(max(c.integral, 0), 100/c.settings.Ki)
+	}
+
+	return c.settings.Kp*err + c.settings.Ki*c.integral + c.settings.Kd*derivative
+}
+
+// shape applies the speed limits and the spin-up kick; callers must hold c.mu
+func (c *ThermalController) shape(raw float64, now time.Time) int {
+	speed := int(math.Round(min(raw, 100)))
+	if speed <= 0 {
+		c.kickUntil = time.Time{}
+		return 0
+	}
+	speed = max(speed, c.settings.MinSpeed)
+
+	// Kick a fan starting from rest so it overcomes static friction
+	if c.written <= 0 && c.settings.KickDuration > 0 {
+		c.kickUntil = now.Add(c.settings.KickDuration)
+	}
+	if now.Before(c.kickUntil) {
+		speed = max(speed, c.settings.KickSpeed)
+	}
+
+	return speed
+}
+
+// curveSpeed interpolates a sorted fan curve
+func curveSpeed(curve []FanCurvePoint, temperature float64) float64 {
+	if temperature <= curve[0].Temperature {
+		return float64(curve[0].Speed)
+	}
+	for i := 1; i < len(curve); i++ {
+		lo, hi := curve[i-1], curve[i]
+		if temperature <= hi.Temperature {
+			fraction := (temperature - lo.Temperature) / (hi.Temperature - lo.Temperature)
+			return float64(lo.Speed) + fraction*float64(hi.Speed-lo.Speed)
+		}
+	}
+	return float64(curve[len(curve)-1].Speed)
+}
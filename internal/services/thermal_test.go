@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// recordingFan records every speed written to it
+type recordingFan struct {
+	speeds []int
+}
+
+func (f *recordingFan) SetFanSpeed(speed int) error {
+	f.speeds = append(f.speeds, speed)
+	return nil
+}
+
+// newTestThermalController creates a controller on a simulated source with
+// no minimum speed, kick or hysteresis unless settings adds them
+func newTestThermalController(t *testing.T, settings ThermalSettings) (*ThermalController, *SimulatedTemperatureSource, *recordingFan) {
+	t.Helper()
+
+	source := NewSimulatedTemperatureSource(20)
+	fan := &recordingFan{}
+	if settings.Interval == 0 {
+		settings.Interval = time.Second
+	}
+	controller, err := NewThermalController(source, fan, settings)
+	if err != nil {
+		t.Fatalf("NewThermalController: %v", err)
+	}
+	return controller, source, fan
+}
+
+// linearCurve runs the fan from off at 30 C to full speed at 50 C
+var linearCurve = []FanCurvePoint{{Temperature: 30, Speed: 0}, {Temperature: 50, Speed: 100}}
+
+func TestThermalCurveInterpolation(t *testing.T) {
+	controller, source, _ := newTestThermalController(t, ThermalSettings{
+		Mode: ThermalCurve,
+		// Out of order on purpose; the controller sorts the curve
+		Curve: []FanCurvePoint{
+			{Temperature: 60, Speed: 100},
+			{Temperature: 35, Speed: 0},
+			{Temperature: 45, Speed: 40},
+		},
+	})
+
+	now := time.Unix(0, 0)
+	for _, step := range []struct {
+		temperature float64
+		speed       int
+	}{
+		{30, 0}, {35, 0}, {40, 20}, {45, 40}, {52.5, 70}, {60, 100}, {70, 100},
+	} {
+		source.Set(step.temperature)
+		now = now.Add(time.Second)
+		if got := controller.Step(now).Speed; got != step.speed {
+			t.Errorf("speed at %v C = %d, want %d", step.temperature, got, step.speed)
+		}
+	}
+}
+
+func TestThermalHysteresisOnFallingTemperatures(t *testing.T) {
+	controller, source, _ := newTestThermalController(t, ThermalSettings{
+		Mode:       ThermalCurve,
+		Curve:      linearCurve,
+		Hysteresis: 2,
+	})
+
+	now := time.Unix(0, 0)
+	for _, step := range []struct {
+		temperature float64
+		speed       int
+	}{
+		{40, 50},
+		// Small drops inside the band keep the speed
+		{39, 50}, {38.5, 50},
+		// Past the band the fan follows, staying the band above
+		{37, 45},
+		{38, 45},
+		// Rises are followed at once
+		{40, 50},
+	} {
+		source.Set(step.temperature)
+		now = now.Add(time.Second)
+		if got := controller.Step(now).Speed; got != step.speed {
+			t.Errorf("speed at %v C = %d, want %d", step.temperature, got, step.speed)
+		}
+	}
+}
+
+func TestThermalMinSpeedAndKick(t *testing.T) {
+	controller, source, fan := newTestThermalController(t, ThermalSettings{
+		Mode:         ThermalCurve,
+		Curve:        linearCurve,
+		MinSpeed:     25,
+		KickSpeed:    100,
+		KickDuration: time.Second,
+	})
+
+	start := time.Unix(0, 0)
+	for _, step := range []struct {
+		at          time.Duration
+		temperature float64
+		speed       int
+		kicking     bool
+	}{
+		{0, 30, 0, false},
+		// 32 C asks for 10%, raised to the minimum, and the fan starting
+		// from rest is kicked first
+		{2 * time.Second, 32, 100, true},
+		{2500 * time.Millisecond, 32, 100, true},
+		{3 * time.Second, 32, 25, false},
+		{4 * time.Second, 36, 30, false},
+		{5 * time.Second, 30, 0, false},
+		// Every start from rest kicks again
+		{6 * time.Second, 32, 100, true},
+	} {
+		source.Set(step.temperature)
+		status := controller.Step(start.Add(step.at))
+		if status.Speed != step.speed || status.Kicking != step.kicking {
+			t.Errorf("at %v and %v C: speed %d, kicking %v; want %d, %v",
+				step.at, step.temperature, status.Speed, status.Kicking, step.speed, step.kicking)
+		}
+	}
+
+	if want := []int{0, 100, 25, 30, 0, 100}; !slices.Equal(fan.speeds, want) {
+		t.Errorf("fan writes = %v, want %v", fan.speeds, want)
+	}
+}
+
+func TestThermalPIDIntegralWindupClamp(t *testing.T) {
+	controller, source, _ := newTestThermalController(t, ThermalSettings{
+		Mode:   ThermalPID,
+		Target: 45,
+		Ki:     1,
+	})
+
+	now := time.Unix(0, 0)
+	step := func(temperature float64, seconds int) int {
+		source.Set(temperature)
+		var speed int
+		for range seconds {
+			now = now.Add(time.Second)
+			speed = controller.Step(now).Speed
+		}
+		return speed
+	}
+
+	// A long overheat saturates the integral at full speed, not beyond
+	if got := step(80, 1000); got != 100 {
+		t.Fatalf("speed after a long overheat = %d, want 100", got)
+	}
+	// So a degree under target unwinds it at once
+	if got := step(44, 1); got != 99 {
+		t.Errorf("speed a second after cooling = %d, want 99", got)
+	}
+
+	// A long stretch under target cannot wind the integral below zero
+	step(20, 1000)
+	if got := step(46, 1); got != 1 {
+		t.Errorf("speed a second after warming = %d, want 1", got)
+	}
+}
+
+func TestThermalFailsafeOnReadError(t *testing.T) {
+	controller, source, fan := newTestThermalController(t, ThermalSettings{
+		Mode:  ThermalCurve,
+		Curve: linearCurve,
+	})
+
+	now := time.Unix(0, 0)
+	source.Set(30)
+	controller.Step(now)
+
+	source.Fail(errors.New("sensor unplugged"))
+	status := controller.Step(now.Add(time.Second))
+	if status.Speed != failsafeSpeed || status.Err == nil {
+		t.Errorf("on a read error: speed %d, err %v; want %d and the error", status.Speed, status.Err, failsafeSpeed)
+	}
+
+	source.Fail(nil)
+	status = controller.Step(now.Add(2 * time.Second))
+	if status.Speed != 0 || status.Err != nil {
+		t.Errorf("after recovering: speed %d, err %v; want 0, nil", status.Speed, status.Err)
+	}
+
+	if want := []int{0, failsafeSpeed, 0}; !slices.Equal(fan.speeds, want) {
+		t.Errorf("fan writes = %v, want %v", fan.speeds, want)
+	}
+}
+
+func TestThermalKickEndsBeforeTheNextInterval(t *testing.T) {
+	controller, source, fan := newTestThermalController(t, ThermalSettings{
+		Mode:         ThermalCurve,
+		Curve:        linearCurve,
+		MinSpeed:     25,
+		KickSpeed:    100,
+		KickDuration: 50 * time.Millisecond,
+		Interval:     time.Hour,
+	})
+
+	source.Set(32)
+	controller.Start()
+	deadline := time.Now().Add(time.Second)
+	for controller.Status().Kicking || controller.Status().Speed != 25 {
+		if time.Now().After(deadline) {
+			controller.Stop()
+			t.Fatalf("status %+v a second after starting, want the kick over", controller.Status())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	controller.Stop()
+
+	if want := []int{100, 25}; !slices.Equal(fan.speeds, want) {
+		t.Errorf("fan writes = %v, want %v", fan.speeds, want)
+	}
+}
@@ -0,0 +1,19 @@
+package sound
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thornzero/barkeep/internal/services"
+)
+
+// Msg asks the app to play the sound for a UI event through the audio
+// manager's sound theme
+type Msg struct {
+	Event services.UISound
+}
+
+// Emit returns a command that plays the sound for a UI event
+func Emit(event services.UISound) tea.Cmd {
+	return func() tea.Msg {
+		return Msg{Event: event}
+	}
+}